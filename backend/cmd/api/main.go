@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/oj/oj-backend/internal/config"
 	"github.com/oj/oj-backend/internal/handler"
 	"github.com/oj/oj-backend/internal/middleware"
+	"github.com/oj/oj-backend/internal/queue"
 	"github.com/oj/oj-backend/internal/repository"
 	"github.com/oj/oj-backend/internal/service"
 )
@@ -26,7 +29,18 @@ func main() {
 	rdb := config.InitRedis(cfg.RedisURL)
 
 	// 初始化 NATS
-	_, js := config.InitNATS(cfg.NATSURL)
+	nc, js := config.InitNATS(cfg.NATSURL)
+
+	// 评测任务按通道发布到 judge.tasks.<class>.<lane>，发布前 stream 需覆盖这些 subject
+	if err := queue.EnsureTaskStream(context.Background(), js); err != nil {
+		log.Fatalf("Failed to ensure task stream: %v", err)
+	}
+	// 旧版本的 consumer 和旧 subject 上积压的任务迁到当前格式
+	if n, err := queue.MigrateLegacyTasks(context.Background(), js); err != nil {
+		log.Printf("Warning: Failed to migrate legacy judge tasks: %v", err)
+	} else if n > 0 {
+		log.Printf("Migrated %d legacy judge tasks", n)
+	}
 
 	// 初始化 MinIO
 	minioClient := config.InitMinIO(cfg.MinIOEndpoint, cfg.MinIOAccessKey, cfg.MinIOSecretKey)
@@ -40,7 +54,14 @@ func main() {
 	repos := repository.NewRepositories(db)
 
 	// 初始化 Service
-	services := service.NewServices(repos, rdb, js, minioClient, cfg.JWTSecret)
+	services := service.NewServices(repos, rdb, nc, js, minioClient, cfg.JWTSecret)
+	services.Problem.SetTestDataBucket(cfg.TestDataBucket)
+	services.Submit.SetQuota(service.JudgeQuota{
+		Hourly:        time.Duration(cfg.JudgeQuotaHourly) * time.Second,
+		Daily:         time.Duration(cfg.JudgeQuotaDaily) * time.Second,
+		ContestBoost:  cfg.JudgeQuotaContestBoost,
+		PendingCharge: time.Duration(cfg.JudgeQuotaPendingCharge) * time.Second,
+	})
 
 	// 初始化 Handler
 	handlers := handler.NewHandlers(services)
@@ -50,6 +71,8 @@ func main() {
 
 	// 启动 WebSocket 广播协程
 	go wsHub.Run()
+	// 向订阅 submit:<submit_id> 的客户端推送排队位置
+	go wsHub.RunQueueFeed(services.Submit, 5*time.Second)
 
 	// Gin 路由
 	r := gin.Default()
@@ -98,9 +121,17 @@ func main() {
 			auth.GET("/submit/:submit_id", handlers.Submit.Get)
 			auth.GET("/my/submits", handlers.Submit.List)
 
+			// 自定义输入运行，不产生提交记录
+			auth.POST("/run", handlers.Run.Run)
+
 			// 比赛
 			auth.POST("/contests/:id/join", handlers.Contest.Join)
 			auth.POST("/contests/:id/submit", handlers.Submit.CreateContest)
+
+			// hack
+			auth.POST("/contests/:id/hacks", handlers.Hack.Create)
+			auth.GET("/contests/:id/hacks", handlers.Hack.List)
+			auth.GET("/contests/:id/hacks/:hack_id", handlers.Hack.Get)
 		}
 
 		// 管理员接口
@@ -112,15 +143,44 @@ func main() {
 			admin.PUT("/problems/:id", handlers.Problem.Update)
 			admin.DELETE("/problems/:id", handlers.Problem.Delete)
 			admin.POST("/problems/:id/testdata", handlers.Problem.UploadTestData)
+			admin.POST("/problems/:id/testdata/validate", handlers.Problem.ValidateTestData)
+			admin.POST("/problems/:id/testdata/generate", handlers.Problem.GenerateTestData)
+
+			// 参考解
+			admin.GET("/problems/:id/solutions", handlers.Solution.List)
+			admin.POST("/problems/:id/solutions", handlers.Solution.Create)
+			admin.DELETE("/problems/:id/solutions/:solution_id", handlers.Solution.Delete)
+			admin.POST("/problems/:id/invocations", handlers.Solution.Invoke)
+			admin.GET("/problems/:id/invocations", handlers.Solution.ListInvocations)
+			admin.GET("/problems/:id/invocations/:invocation_id", handlers.Solution.GetInvocation)
 
 			// 比赛管理
 			admin.POST("/contests", handlers.Contest.Create)
 			admin.PUT("/contests/:id", handlers.Contest.Update)
 			admin.DELETE("/contests/:id", handlers.Contest.Delete)
+			admin.POST("/contests/:id/hacks/:hack_id/tests", handlers.Hack.AddToTests)
 
 			// 用户管理
 			admin.GET("/admin/users", handlers.User.List)
 			admin.POST("/admin/users/:id/ban", handlers.User.Ban)
+
+			// 评测
+			admin.GET("/admin/judge/queue", handlers.Worker.Queue)
+			admin.POST("/admin/judge/rejudge", handlers.Submit.Rejudge)
+
+			// 评测机
+			admin.GET("/admin/workers", handlers.Worker.List)
+			admin.GET("/admin/workers/:id", handlers.Worker.Get)
+			admin.DELETE("/admin/workers/:id", handlers.Worker.Remove)
+
+			// 死信队列
+			admin.GET("/admin/dlq", handlers.DLQ.List)
+			admin.GET("/admin/dlq/audits", handlers.DLQ.ListAudits)
+			admin.GET("/admin/dlq/:seq", handlers.DLQ.Get)
+			admin.POST("/admin/dlq/replay", handlers.DLQ.ReplayBatch)
+			admin.POST("/admin/dlq/:seq/replay", handlers.DLQ.Replay)
+			admin.DELETE("/admin/dlq/:seq", handlers.DLQ.Delete)
+			admin.DELETE("/admin/dlq", handlers.DLQ.Purge)
 		}
 
 		// WebSocket
@@ -24,9 +24,13 @@ func main() {
 		&model.User{},
 		&model.Language{},
 		&model.Problem{},
+		&model.ProblemSolution{},
+		&model.ProblemInvocation{},
 		&model.Submission{},
 		&model.Contest{},
 		&model.ContestParticipant{},
+		&model.Hack{},
+		&model.DeadLetterAudit{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate: %v", err)
@@ -58,12 +62,12 @@ func initDefaultData(db *gorm.DB) {
 			CompileTimeout: 10,
 			RunCmd:         "./main",
 			RunTimeout:     2,
-			DockerImage:    "oj-compiler-cpp:latest",
 			TimeFactor:     1.0,
 			MemoryFactor:   1.0,
 			OutputLimit:    65536,
 			PidsLimit:      64,
 			Enabled:        true,
+			QueueClass:     "heavy",
 		},
 		{
 			Name:           "GNU C11",
@@ -74,12 +78,12 @@ func initDefaultData(db *gorm.DB) {
 			CompileTimeout: 10,
 			RunCmd:         "./main",
 			RunTimeout:     2,
-			DockerImage:    "oj-compiler-c:latest",
 			TimeFactor:     1.0,
 			MemoryFactor:   1.0,
 			OutputLimit:    65536,
 			PidsLimit:      64,
 			Enabled:        true,
+			QueueClass:     "heavy",
 		},
 		{
 			Name:           "Python 3",
@@ -90,7 +94,6 @@ func initDefaultData(db *gorm.DB) {
 			CompileTimeout: 0,
 			RunCmd:         "python3 main.py",
 			RunTimeout:     5,
-			DockerImage:    "oj-compiler-python:latest",
 			TimeFactor:     2.0,
 			MemoryFactor:   1.0,
 			OutputLimit:    65536,
@@ -106,7 +109,6 @@ func initDefaultData(db *gorm.DB) {
 			CompileTimeout: 10,
 			RunCmd:         "./main",
 			RunTimeout:     2,
-			DockerImage:    "oj-compiler-go:latest",
 			TimeFactor:     1.0,
 			MemoryFactor:   1.0,
 			OutputLimit:    65536,
@@ -122,12 +124,12 @@ func initDefaultData(db *gorm.DB) {
 			CompileTimeout: 10,
 			RunCmd:         "java Main",
 			RunTimeout:     3,
-			DockerImage:    "oj-compiler-java:latest",
 			TimeFactor:     1.5,
 			MemoryFactor:   1.5,
 			OutputLimit:    65536,
 			PidsLimit:      64,
 			Enabled:        true,
+			QueueClass:     "heavy",
 		},
 		{
 			Name:           "Rust 1.75",
@@ -138,7 +140,6 @@ func initDefaultData(db *gorm.DB) {
 			CompileTimeout: 15,
 			RunCmd:         "./main",
 			RunTimeout:     2,
-			DockerImage:    "oj-compiler-rust:latest",
 			TimeFactor:     1.0,
 			MemoryFactor:   1.0,
 			OutputLimit:    65536,
@@ -2,28 +2,41 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
-	// "time"
+	"time"
 
 	// "github.com/nats-io/nats.go"
 	"github.com/oj/oj-backend/internal/config"
 	"github.com/oj/oj-backend/internal/queue"
 	"github.com/oj/oj-backend/internal/repository"
+	"github.com/oj/oj-backend/internal/sandbox"
+	"github.com/oj/oj-backend/internal/service"
 	"github.com/oj/oj-backend/internal/service/judge"
 )
 
 func main() {
-	// 加载配置
-	workerID := getEnv("WORKER_ID", "judge-worker-1")
-	consumerName := getEnv("CONSUMER", "judge.tasks.light")
+	// 沙箱 init 复用本二进制，必须最先执行
+	sandbox.Init()
+
+	// 加载配置，worker ID 默认取主机名
+	hostname, _ := os.Hostname()
+	workerID := getEnv("WORKER_ID", hostname)
+	if workerID == "" {
+		workerID = "judge-worker-1"
+	}
+	// 队列类别决定消费的 subject，能力决定该类别中哪些语言可以运行
+	queueClass := getEnv("QUEUE_CLASS", queue.DefaultQueueClass)
+	capabilities := getEnvList("WORKER_CAPABILITIES")
 	concurrency := getEnvInt("CONCURRENCY", 2)
 
-	log.Printf("Starting judge worker: %s, consumer: %s, concurrency: %d",
-		workerID, consumerName, concurrency)
+	log.Printf("Starting judge worker: %s, queue: %s, capabilities: %v, concurrency: %d",
+		workerID, queueClass, capabilities, concurrency)
 
 	// 初始化数据库
 	db, err := config.InitDB(os.Getenv("DATABASE_URL"))
@@ -43,40 +56,204 @@ func main() {
 
 	// 初始化 Repository
 	repos := &repository.Repositories{
-		Submit: repository.NewSubmitRepo(db),
+		Submit:   repository.NewSubmitRepo(db),
+		Problem:  repository.NewProblemRepo(db),
+		Hack:     repository.NewHackRepo(db),
+		Solution: repository.NewSolutionRepo(db),
+		Lang:     repository.NewLanguageRepo(db),
+	}
+
+	// 初始化沙箱（namespaces + cgroups v2，需要 root）
+	runner, err := sandbox.NewRunner(sandbox.Options{
+		CgroupRoot: getEnv("SANDBOX_CGROUP_ROOT", "/sys/fs/cgroup/oj-judge"),
+		UID:        getEnvInt("SANDBOX_UID", 65534),
+		GID:        getEnvInt("SANDBOX_GID", 65534),
+	})
+	if err != nil {
+		log.Fatalf("Failed to init sandbox: %v", err)
+	}
+
+	// 初始化测试数据缓存
+	testData, err := judge.NewTestDataCache(
+		getEnv("TESTDATA_CACHE_DIR", "/var/cache/oj-testdata"),
+		int64(getEnvInt("TESTDATA_CACHE_SIZE_MB", 10240))<<20,
+		minioClient,
+		getEnv("TESTDATA_BUCKET", "oj-testdata"),
+	)
+	if err != nil {
+		log.Fatalf("Failed to init test data cache: %v", err)
 	}
 
 	// 初始化 Judge Service
-	judgeService := judge.NewJudgeService(minioClient, os.Getenv("MINIO_BUCKET"))
+	judgeService := judge.NewJudgeService(minioClient, os.Getenv("MINIO_BUCKET"), runner, testData)
+	judgeService.SetCompileMemoryLimit(getEnvInt("COMPILE_MEMORY_LIMIT", 512))
+	if err := judgeService.SetSPJCacheDir(getEnv("SPJ_CACHE_DIR", "/var/cache/oj-spj")); err != nil {
+		log.Fatalf("Failed to init spj cache: %v", err)
+	}
+	// 编译产物缓存，配置 ARTIFACT_BUCKET 时同时存到 MinIO 供其他 worker 复用
+	artifacts, err := judge.NewArtifactCache(
+		getEnv("ARTIFACT_CACHE_DIR", "/var/cache/oj-artifacts"),
+		int64(getEnvInt("ARTIFACT_CACHE_SIZE_MB", 2048))<<20,
+		minioClient,
+		os.Getenv("ARTIFACT_BUCKET"),
+	)
+	if err != nil {
+		log.Fatalf("Failed to init artifact cache: %v", err)
+	}
+	judgeService.SetArtifactCache(artifacts)
+	// 工作目录磁盘配额基于 loop 设备，默认关闭；同时清理上次崩溃遗留的工作目录
+	if err := judgeService.SetWorkspaceDir(getEnv("WORKSPACE_DIR", os.TempDir()), getEnvInt("WORKSPACE_DISK_QUOTA_MB", 0)); err != nil {
+		log.Fatalf("Failed to init workspace dir: %v", err)
+	}
+
+	// 本队列中能力满足且工具链存在的语言，随心跳上报并决定订阅哪些请求，
+	// 其余语言的任务交还队列
+	enabled := true
+	langs, err := repos.Lang.List(&enabled)
+	if err != nil {
+		log.Fatalf("Failed to load languages: %v", err)
+	}
+	classLangs, missing := judge.ClassLanguages(langs, queueClass, capabilities)
+	for slug, lack := range missing {
+		log.Printf("Warning: language %s in queue %s needs capabilities %v", slug, queueClass, lack)
+	}
+	languages, toolchains := judge.ProbeToolchains(classLangs)
+	if len(languages) == 0 {
+		log.Printf("Warning: no runnable language for queue %s", queueClass)
+	}
+	judgeService.SetToolchains(toolchains)
+
+	// 以下 request-reply 服务都只订阅本机可用的语言：
+	// 题目保存时的 SPJ 试编译请求
+	if _, err := queue.ServeSPJCompile(nc, languages, func(req *queue.SPJCompileRequest) *queue.SPJCompileReply {
+		log.Printf("Compiling spj for problem %d", req.ProblemID)
+		return judgeService.CompileSPJ(&req.SPJ)
+	}); err != nil {
+		log.Fatalf("Failed to subscribe spj compile: %v", err)
+	}
+
+	// 测试数据上传后的输入校验
+	if _, err := queue.ServeValidate(nc, languages, func(req *queue.ValidateRequest) *queue.ValidateReply {
+		log.Printf("Validating test data for problem %d", req.Problem.ID)
+		return judgeService.ValidateTestData(req)
+	}); err != nil {
+		log.Fatalf("Failed to subscribe test data validation: %v", err)
+	}
+
+	// 自定义输入运行（request-reply），与评测队列分开计算并发
+	if _, err := queue.ServeRun(nc, languages, getEnvInt("RUN_CONCURRENCY", 1), judgeService.RunCode); err != nil {
+		log.Fatalf("Failed to subscribe run requests: %v", err)
+	}
+
+	// hack 任务走独立的 stream，结果由 worker 直接落库
+	if _, err := queue.ConsumeHacks(context.Background(), js, func(task *queue.HackTask) error {
+		log.Printf("Processing hack %d", task.HackID)
+		result, err := judgeService.ProcessHack(task)
+		if err != nil {
+			return err
+		}
+		return repos.Hack.SaveResult(result)
+	}); err != nil {
+		log.Fatalf("Failed to consume hacks: %v", err)
+	}
+
+	// 由生成器和标程生产测试数据，成功后与上传一样换上新数据并在后台校验
+	problems := service.NewProblemService(repos.Problem, repos.Lang, minioClient, nc, js)
+	if _, err := queue.ConsumeGenerate(context.Background(), js, func(task *queue.GenerateTask) error {
+		log.Printf("Generating test data for problem %d", task.ProblemID)
+		result, err := judgeService.GenerateTestData(task)
+		if err != nil {
+			return err
+		}
+		if result.Status == queue.GenerateStatusDone {
+			if err := problems.ApplyGeneratedTestData(task.ProblemID, result.Location, result.Hash); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		return repos.Problem.UpdateTestDataGen(task.ProblemID, string(data))
+	}); err != nil {
+		log.Fatalf("Failed to consume generate tasks: %v", err)
+	}
+
+	// 在完整测试集上运行参考解
+	if _, err := queue.ConsumeInvoke(context.Background(), js, func(task *queue.InvokeTask) error {
+		log.Printf("Invoking %d solutions for problem %d", len(task.Solutions), task.Problem.ID)
+		result, err := judgeService.InvokeSolutions(task)
+		if err != nil {
+			return err
+		}
+		return repos.Solution.SaveInvocationResult(result)
+	}); err != nil {
+		log.Fatalf("Failed to consume invoke tasks: %v", err)
+	}
+
+	// 重试耗尽的评测任务进入死信队列
+	if err := queue.EnsureDLQStream(context.Background(), js); err != nil {
+		log.Fatalf("Failed to init dlq stream: %v", err)
+	}
 
 	// 创建消费者
-	consumer := queue.NewConsumer(js, consumerName, workerID)
+	consumer := queue.NewConsumer(js, queueClass, workerID)
 
 	// 创建 Worker Pool
 	pool := judge.NewWorkerPool(concurrency, judgeService, repos.Submit, js)
 
+	pool.SetWorkerID(workerID)
+
+	pool.SetLanguages(languages)
+
 	// 启动 Worker Pool
 	pool.Start()
 
-	// 启动消费
+	// 心跳：上报槽位占用、可用语言和工具链版本
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	heartbeatDone := make(chan struct{})
+	kv, err := queue.WorkerBucket(heartbeatCtx, js)
+	if err != nil {
+		log.Fatalf("Failed to init worker bucket: %v", err)
+	}
+	go func() {
+		defer close(heartbeatDone)
+		pool.Heartbeat(heartbeatCtx, kv, queue.WorkerInfo{
+			ID:           workerID,
+			Hostname:     hostname,
+			Consumer:     consumer.Name(),
+			QueueClass:   queueClass,
+			Capabilities: capabilities,
+			Concurrency:  concurrency,
+			Languages:    languages,
+			Toolchains:   toolchains,
+			StartedAt:    time.Now(),
+		})
+	}()
+
+	// 启动消费：每条消息在结果落库后才确认，空闲 worker 接手后才拉取下一条
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	err = consumer.Consume(ctx, func(task *queue.JudgeTask) error {
-		pool.Submit(task)
-		return nil
-	})
-	if err != nil {
-		log.Fatalf("Failed to consume: %v", err)
-	}
+	consumeDone := make(chan struct{})
+	go func() {
+		defer close(consumeDone)
+		if err := consumer.Consume(ctx, pool.Submit); err != nil {
+			log.Fatalf("Failed to consume: %v", err)
+		}
+	}()
 
-	// 优雅退出
+	// 优雅退出：先停止拉取，再等正在评测的任务完成，未开始的任务交还队列
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	<-sigChan
 	log.Println("Shutting down...")
+	cancel()
 	pool.Stop()
+	<-consumeDone
+	stopHeartbeat()
+	<-heartbeatDone
 	nc.Drain()
 }
 
@@ -87,6 +264,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList 逗号分隔的列表，忽略空项
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func getEnvInt(key string, def int) int {
 	v := os.Getenv(key)
 	if v == "" {
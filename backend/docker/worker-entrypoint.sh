@@ -0,0 +1,11 @@
+#!/bin/sh
+# cgroup v2 不允许有进程的节点向子节点开放控制器。容器自己的 cgroup 根节点里
+# 有 worker 进程，先把容器内已有进程移到 init 子节点，评测根 cgroup 才能开启 memory/pids/cpu。
+set -e
+
+if [ -f /sys/fs/cgroup/cgroup.controllers ]; then
+	mkdir -p /sys/fs/cgroup/init
+	xargs -rn1 < /sys/fs/cgroup/cgroup.procs > /sys/fs/cgroup/init/cgroup.procs || :
+fi
+
+exec "$@"
@@ -14,6 +14,7 @@ require (
 	github.com/nats-io/nats.go v1.31.0
 	github.com/redis/go-redis/v9 v9.4.0
 	golang.org/x/crypto v0.39.0
+	golang.org/x/sync v0.15.0
 	gorm.io/driver/postgres v1.5.4
 	gorm.io/gorm v1.25.5
 )
@@ -56,7 +57,6 @@ require (
 	github.com/ugorji/go/codec v1.3.0 // indirect
 	golang.org/x/arch v0.18.0 // indirect
 	golang.org/x/net v0.41.0 // indirect
-	golang.org/x/sync v0.15.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
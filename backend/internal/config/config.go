@@ -40,6 +40,7 @@ type Config struct {
 	MinIOAccessKey string
 	MinIOSecretKey string
 	MinIOBucket    string
+	TestDataBucket string
 
 	// JWT
 	JWTSecret string
@@ -47,6 +48,12 @@ type Config struct {
 	// Judge
 	JudgeTimeout   int
 	JudgeMaxMemory int64
+
+	// 评测时长配额（秒），0 表示不限制
+	JudgeQuotaHourly        int
+	JudgeQuotaDaily         int
+	JudgeQuotaContestBoost  int // 比赛进行中参赛者的配额倍数
+	JudgeQuotaPendingCharge int // 未评测完的提交预先计入的时长
 }
 
 // Load 加载配置
@@ -61,9 +68,15 @@ func Load() *Config {
 		MinIOAccessKey: getEnv("MINIO_ACCESS_KEY", "minioadmin"),
 		MinIOSecretKey: getEnv("MINIO_SECRET_KEY", "minioadmin"),
 		MinIOBucket:    getEnv("MINIO_BUCKET", "oj"),
+		TestDataBucket: getEnv("TESTDATA_BUCKET", "oj-testdata"),
 		JWTSecret:      getEnv("JWT_SECRET", "your-jwt-secret-change-in-production"),
 		JudgeTimeout:   getEnvInt("JUDGE_TIMEOUT", 30),
 		JudgeMaxMemory: getEnvInt64("JUDGE_MAX_MEMORY", 512),
+
+		JudgeQuotaHourly:        getEnvInt("JUDGE_QUOTA_HOURLY", 0),
+		JudgeQuotaDaily:         getEnvInt("JUDGE_QUOTA_DAILY", 0),
+		JudgeQuotaContestBoost:  getEnvInt("JUDGE_QUOTA_CONTEST_BOOST", 4),
+		JudgeQuotaPendingCharge: getEnvInt("JUDGE_QUOTA_PENDING_CHARGE", 10),
 	}
 }
 
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/service"
+)
+
+// DeadLetterHandler 死信队列管理（管理员）
+type DeadLetterHandler struct {
+	service *service.DeadLetterService
+}
+
+func NewDeadLetterHandler(s *service.DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{service: s}
+}
+
+func (h *DeadLetterHandler) List(c *gin.Context) {
+	letters, total, err := h.service.List(getInt(c, "page", 1), getInt(c, "page_size", 20))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  letters,
+			"total": total,
+		},
+	})
+}
+
+func (h *DeadLetterHandler) Get(c *gin.Context) {
+	seq, err := strconv.ParseUint(c.Param("seq"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid seq"})
+		return
+	}
+
+	letter, err := h.service.Get(seq)
+	if err != nil {
+		status := deadLetterErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": letter,
+	})
+}
+
+func (h *DeadLetterHandler) Replay(c *gin.Context) {
+	seq, err := strconv.ParseUint(c.Param("seq"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid seq"})
+		return
+	}
+
+	if err := h.service.Replay(c.GetInt64("user_id"), seq); err != nil {
+		status := deadLetterErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0})
+}
+
+// ReplayBatch 批量重放，Body: {"seqs": [1, 2]} 或 {"all": true}
+func (h *DeadLetterHandler) ReplayBatch(c *gin.Context) {
+	var req struct {
+		Seqs []uint64 `json:"seqs"`
+		All  bool     `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if !req.All && len(req.Seqs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "seqs or all is required"})
+		return
+	}
+
+	replayed, failures, err := h.service.ReplayBatch(c.GetInt64("user_id"), req.Seqs, req.All)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"replayed": replayed,
+			"failed":   failures,
+		},
+	})
+}
+
+func (h *DeadLetterHandler) Delete(c *gin.Context) {
+	seq, err := strconv.ParseUint(c.Param("seq"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid seq"})
+		return
+	}
+
+	if err := h.service.Delete(c.GetInt64("user_id"), seq); err != nil {
+		status := deadLetterErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0})
+}
+
+func (h *DeadLetterHandler) Purge(c *gin.Context) {
+	purged, err := h.service.Purge(c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{"purged": purged},
+	})
+}
+
+func (h *DeadLetterHandler) ListAudits(c *gin.Context) {
+	audits, total, err := h.service.ListAudits(c.Query("submit_id"), getInt(c, "page", 1), getInt(c, "page_size", 20))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  audits,
+			"total": total,
+		},
+	})
+}
+
+func deadLetterErrorStatus(err error) int {
+	if errors.Is(err, queue.ErrDeadLetterNotFound) || errors.Is(err, service.ErrSubmissionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
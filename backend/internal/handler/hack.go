@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oj/oj-backend/internal/repository"
+	"github.com/oj/oj-backend/internal/service"
+)
+
+type HackHandler struct {
+	service *service.HackService
+}
+
+func NewHackHandler(s *service.HackService) *HackHandler {
+	return &HackHandler{service: s}
+}
+
+func (h *HackHandler) Create(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	contestID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid contest id"})
+		return
+	}
+
+	var req service.HackParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	hack, err := h.service.Create(userID, contestID, req)
+	if err != nil {
+		status := hackErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"id":     hack.ID,
+			"status": hack.Status,
+		},
+	})
+}
+
+func (h *HackHandler) List(c *gin.Context) {
+	contestID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid contest id"})
+		return
+	}
+
+	hacks, total, err := h.service.List(repository.ListHackParams{
+		ContestID:    contestID,
+		HackerID:     getInt64(c, "hacker_id", 0),
+		TargetUserID: getInt64(c, "target_user_id", 0),
+		Page:         getInt(c, "page", 1),
+		PageSize:     getInt(c, "page_size", 20),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  hacks,
+			"total": total,
+		},
+	})
+}
+
+func (h *HackHandler) Get(c *gin.Context) {
+	contestID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid contest id"})
+		return
+	}
+	hackID, err := strconv.ParseInt(c.Param("hack_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid hack id"})
+		return
+	}
+
+	hack, err := h.service.Get(contestID, hackID, c.GetInt64("user_id"), c.GetString("role") == "admin")
+	if err != nil {
+		status := hackErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": hack,
+	})
+}
+
+// AddToTests 把成功的 hack 加入系统测试（管理员）
+func (h *HackHandler) AddToTests(c *gin.Context) {
+	contestID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid contest id"})
+		return
+	}
+	hackID, err := strconv.ParseInt(c.Param("hack_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid hack id"})
+		return
+	}
+
+	if err := h.service.AddToTests(contestID, hackID); err != nil {
+		status := hackErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0})
+}
+
+// hackErrorStatus 不满足 hack 条件返回 403，请求本身的问题返回 400，找不到返回 404
+func hackErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrHackNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidHack), errors.Is(err, service.ErrNoTestData):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrHackNotFound), errors.Is(err, service.ErrContestNotFound),
+		errors.Is(err, service.ErrProblemNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -4,20 +4,30 @@ import "github.com/oj/oj-backend/internal/service"
 
 // Handlers 所有 Handler 的集合
 type Handlers struct {
-	User    *UserHandler
-	Problem *ProblemHandler
-	Submit  *SubmitHandler
-	Contest *ContestHandler
-	Lang    *LanguageHandler
+	User     *UserHandler
+	Problem  *ProblemHandler
+	Submit   *SubmitHandler
+	Contest  *ContestHandler
+	Lang     *LanguageHandler
+	Run      *RunHandler
+	Hack     *HackHandler
+	Solution *SolutionHandler
+	Worker   *WorkerHandler
+	DLQ      *DeadLetterHandler
 }
 
 // NewHandlers 创建 Handler 集合
 func NewHandlers(services *service.Services) *Handlers {
 	return &Handlers{
-		User:    NewUserHandler(services.User),
-		Problem: NewProblemHandler(services.Problem),
-		Submit:  NewSubmitHandler(services.Submit),
-		Contest: NewContestHandler(services.Contest),
-		Lang:    NewLanguageHandler(services.Lang),
+		User:     NewUserHandler(services.User, services.Submit),
+		Problem:  NewProblemHandler(services.Problem),
+		Submit:   NewSubmitHandler(services.Submit),
+		Contest:  NewContestHandler(services.Contest),
+		Lang:     NewLanguageHandler(services.Lang),
+		Run:      NewRunHandler(services.Run),
+		Hack:     NewHackHandler(services.Hack),
+		Solution: NewSolutionHandler(services.Solution),
+		Worker:   NewWorkerHandler(services.Worker),
+		DLQ:      NewDeadLetterHandler(services.DLQ),
 	}
 }
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -75,12 +76,16 @@ func (h *ProblemHandler) Create(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Create(problem.ToModel(), userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+	p := problem.ToModel()
+	if err := h.service.Create(p, userID); err != nil {
+		c.JSON(problemErrorStatus(err), gin.H{"code": problemErrorStatus(err), "message": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"code": 0})
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{"id": p.ID, "spj_compile_out": p.SpjCompileOut},
+	})
 }
 
 func (h *ProblemHandler) Update(c *gin.Context) {
@@ -98,12 +103,16 @@ func (h *ProblemHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Update(id, problem.ToModel(), userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+	p := problem.ToModel()
+	if err := h.service.Update(id, p, userID); err != nil {
+		c.JSON(problemErrorStatus(err), gin.H{"code": problemErrorStatus(err), "message": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"code": 0})
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{"id": p.ID, "spj_compile_out": p.SpjCompileOut},
+	})
 }
 
 func (h *ProblemHandler) Delete(c *gin.Context) {
@@ -121,6 +130,98 @@ func (h *ProblemHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 0})
 }
 
+// problemErrorStatus 题目配置错误返回 400，题目不存在返回 404，其余为 500
+func problemErrorStatus(err error) int {
+	if errors.Is(err, service.ErrProblemNotFound) {
+		return http.StatusNotFound
+	}
+	for _, target := range []error{
+		service.ErrInvalidChecker,
+		service.ErrInvalidSPJ,
+		service.ErrInvalidInteractor,
+		service.ErrInvalidSubtasks,
+		service.ErrInvalidJudgeMode,
+		service.ErrInvalidValidator,
+		service.ErrInvalidStd,
+		service.ErrInvalidGenerators,
+		service.ErrInvalidTestData,
+		service.ErrNoTestData,
+	} {
+		if errors.Is(err, target) {
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// UploadTestData 上传测试数据压缩包，配置了校验器时在后台检查各输入文件
 func (h *ProblemHandler) UploadTestData(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "not implemented"})
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid id"})
+		return
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "file is required"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	upload, err := h.service.UploadTestData(id, f)
+	if err != nil {
+		c.JSON(problemErrorStatus(err), gin.H{"code": problemErrorStatus(err), "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": upload,
+	})
+}
+
+// ValidateTestData 用校验器在后台重新检查当前测试数据
+func (h *ProblemHandler) ValidateTestData(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid id"})
+		return
+	}
+
+	check, err := h.service.ValidateTestData(id)
+	if err != nil {
+		c.JSON(problemErrorStatus(err), gin.H{"code": problemErrorStatus(err), "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": check,
+	})
+}
+
+// GenerateTestData 用生成器和标程重新生成测试数据，异步执行
+func (h *ProblemHandler) GenerateTestData(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid id"})
+		return
+	}
+
+	result, err := h.service.GenerateTestData(id)
+	if err != nil {
+		c.JSON(problemErrorStatus(err), gin.H{"code": problemErrorStatus(err), "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": result,
+	})
 }
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oj/oj-backend/internal/service"
+)
+
+type RunHandler struct {
+	service *service.RunService
+}
+
+func NewRunHandler(s *service.RunService) *RunHandler {
+	return &RunHandler{service: s}
+}
+
+// Run 用自定义输入运行代码，同步返回输出
+func (h *RunHandler) Run(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var params service.RunParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	reply, err := h.service.Run(userID, params)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrInvalidRun):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrProblemNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrRunUnavailable):
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": reply,
+	})
+}
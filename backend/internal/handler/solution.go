@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oj/oj-backend/internal/service"
+)
+
+// SolutionHandler 参考解管理和运行（管理员）
+type SolutionHandler struct {
+	service *service.SolutionService
+}
+
+func NewSolutionHandler(s *service.SolutionService) *SolutionHandler {
+	return &SolutionHandler{service: s}
+}
+
+func (h *SolutionHandler) Create(c *gin.Context) {
+	problemID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid id"})
+		return
+	}
+
+	var params service.SolutionParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	solution, err := h.service.Create(problemID, c.GetInt64("user_id"), params)
+	if err != nil {
+		status := solutionErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": solution,
+	})
+}
+
+func (h *SolutionHandler) List(c *gin.Context) {
+	problemID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid id"})
+		return
+	}
+
+	solutions, err := h.service.List(problemID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": solutions,
+	})
+}
+
+func (h *SolutionHandler) Delete(c *gin.Context) {
+	problemID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid id"})
+		return
+	}
+	solutionID, err := strconv.ParseInt(c.Param("solution_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid solution id"})
+		return
+	}
+
+	if err := h.service.Delete(problemID, solutionID); err != nil {
+		status := solutionErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0})
+}
+
+// Invoke 在完整测试集上运行全部参考解，异步执行
+func (h *SolutionHandler) Invoke(c *gin.Context) {
+	problemID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid id"})
+		return
+	}
+
+	invocation, err := h.service.Invoke(problemID, c.GetInt64("user_id"))
+	if err != nil {
+		status := solutionErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"id":     invocation.ID,
+			"status": invocation.Status,
+		},
+	})
+}
+
+func (h *SolutionHandler) ListInvocations(c *gin.Context) {
+	problemID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid id"})
+		return
+	}
+
+	invocations, err := h.service.ListInvocations(problemID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": invocations,
+	})
+}
+
+func (h *SolutionHandler) GetInvocation(c *gin.Context) {
+	problemID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid id"})
+		return
+	}
+	invocationID, err := strconv.ParseInt(c.Param("invocation_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "invalid invocation id"})
+		return
+	}
+
+	invocation, err := h.service.GetInvocation(problemID, invocationID)
+	if err != nil {
+		status := solutionErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": invocation,
+	})
+}
+
+// solutionErrorStatus 请求本身的问题返回 400，找不到返回 404，其余为 500
+func solutionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidSolution), errors.Is(err, service.ErrNoTestData):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrSolutionNotFound), errors.Is(err, service.ErrInvocationNotFound),
+		errors.Is(err, service.ErrProblemNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -21,6 +22,7 @@ type SubmitRequest struct {
 	LanguageID     int64  `json:"language_id" binding:"required"`
 	Code           string `json:"code" binding:"required"`
 	IdempotencyKey string `json:"idempotency_key"`
+	SampleOnly     bool   `json:"sample_only"`
 }
 
 func (h *SubmitHandler) Create(c *gin.Context) {
@@ -37,9 +39,12 @@ func (h *SubmitHandler) Create(c *gin.Context) {
 		LanguageID:     req.LanguageID,
 		Code:           req.Code,
 		IdempotencyKey: req.IdempotencyKey,
+		SampleOnly:     req.SampleOnly,
+		IsAdmin:        c.GetString("role") == "admin",
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		status := submitErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
 		return
 	}
 
@@ -52,10 +57,11 @@ func (h *SubmitHandler) Create(c *gin.Context) {
 	})
 }
 
+// Get 提交详情，排队中时附带排队位置和预计开始时间
 func (h *SubmitHandler) Get(c *gin.Context) {
 	submitID := c.Param("submit_id")
 
-	submission, err := h.service.GetBySubmitID(submitID)
+	submission, err := h.service.GetDetail(submitID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
 		return
@@ -71,10 +77,12 @@ func (h *SubmitHandler) List(c *gin.Context) {
 	userID := c.GetInt64("user_id")
 	problemID := getInt64Ptr(c, "problem_id")
 	status := getStringPtr(c, "status")
+	verdict := getStringPtr(c, "verdict")
+	includeSample := c.Query("include_sample") == "true"
 	page := getInt(c, "page", 1)
 	pageSize := getInt(c, "page_size", 20)
 
-	submissions, total, err := h.service.ListByUser(userID, problemID, status, page, pageSize)
+	submissions, total, err := h.service.ListByUser(userID, problemID, status, verdict, includeSample, page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
 		return
@@ -94,6 +102,7 @@ type ContestSubmitRequest struct {
 	ProblemLetter string `json:"problem_letter" binding:"required"`
 	LanguageID    int64  `json:"language_id" binding:"required"`
 	Code          string `json:"code" binding:"required"`
+	SampleOnly    bool   `json:"sample_only"`
 }
 
 func (h *SubmitHandler) CreateContest(c *gin.Context) {
@@ -114,9 +123,12 @@ func (h *SubmitHandler) CreateContest(c *gin.Context) {
 		ProblemID:  req.ProblemID,
 		LanguageID: req.LanguageID,
 		Code:       req.Code,
+		SampleOnly: req.SampleOnly,
+		IsAdmin:    c.GetString("role") == "admin",
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		status := submitErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
 		return
 	}
 
@@ -128,3 +140,40 @@ func (h *SubmitHandler) CreateContest(c *gin.Context) {
 		},
 	})
 }
+
+// Rejudge 重新评测指定的提交或题目下的全部提交
+func (h *SubmitHandler) Rejudge(c *gin.Context) {
+	var req service.RejudgeParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	rejudged, err := h.service.Rejudge(req)
+	if err != nil {
+		status := submitErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error(), "data": gin.H{"submit_ids": rejudged}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"count":      len(rejudged),
+			"submit_ids": rejudged,
+		},
+	})
+}
+
+// submitErrorStatus 请求本身的问题返回 400，找不到提交或题目返回 404，配额用完返回 429，其余为 500
+func submitErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrNoSamples), errors.Is(err, service.ErrInvalidRejudge):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrSubmissionNotFound), errors.Is(err, service.ErrProblemNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
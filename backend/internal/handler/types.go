@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"encoding/json"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -22,25 +23,59 @@ type ProblemInput struct {
 	MemoryLimit  int      `json:"memory_limit"`
 	IsSPJ        bool     `json:"is_spj"`
 	IsPublic     bool     `json:"is_public"`
+
+	Checker        string  `json:"checker"`
+	CheckerEpsilon float64 `json:"checker_epsilon"`
+	SpjLang        string  `json:"spj_lang"`
+	SpjCode        string  `json:"spj_code"`
+	IsInteractive  bool    `json:"is_interactive"`
+	InteractorLang string  `json:"interactor_lang"`
+	InteractorCode string  `json:"interactor_code"`
+	JudgeMode      string  `json:"judge_mode"`
+	ValidatorLang  string  `json:"validator_lang"`
+	ValidatorCode  string  `json:"validator_code"`
+	StdLang        string  `json:"std_lang"`
+	StdCode        string  `json:"std_code"`
+	GenScript      string  `json:"gen_script"`
+
+	// TestCases 子任务配置，见 judge.TestCaseConfig
+	TestCases json.RawMessage `json:"test_cases"`
+	// Generators 测试数据生成器，见 judge.GeneratorConfig
+	Generators json.RawMessage `json:"generators"`
 }
 
 func (p *ProblemInput) ToModel() *model.Problem {
 	return &model.Problem{
-		Title:        p.Title,
-		Slug:         p.Slug,
-		Difficulty:   p.Difficulty,
-		Tags:         p.Tags,
-		Source:       p.Source,
-		Description:  p.Description,
-		InputFormat:  p.InputFormat,
-		OutputFormat: p.OutputFormat,
-		SampleIO:     p.SampleIO,
-		Hint:         p.Hint,
-		TimeLimit:    p.TimeLimit,
-		MemoryLimit:  p.MemoryLimit,
-		IsSPJ:        p.IsSPJ,
-		IsPublic:     p.IsPublic,
-		Visible:      true,
+		Title:          p.Title,
+		Slug:           p.Slug,
+		Difficulty:     p.Difficulty,
+		Tags:           p.Tags,
+		Source:         p.Source,
+		Description:    p.Description,
+		InputFormat:    p.InputFormat,
+		OutputFormat:   p.OutputFormat,
+		SampleIO:       p.SampleIO,
+		Hint:           p.Hint,
+		TimeLimit:      p.TimeLimit,
+		MemoryLimit:    p.MemoryLimit,
+		IsSPJ:          p.IsSPJ,
+		IsPublic:       p.IsPublic,
+		Checker:        p.Checker,
+		CheckerEpsilon: p.CheckerEpsilon,
+		SpjLang:        p.SpjLang,
+		SpjCode:        p.SpjCode,
+		IsInteractive:  p.IsInteractive,
+		InteractorLang: p.InteractorLang,
+		InteractorCode: p.InteractorCode,
+		JudgeMode:      p.JudgeMode,
+		ValidatorLang:  p.ValidatorLang,
+		ValidatorCode:  p.ValidatorCode,
+		StdLang:        p.StdLang,
+		StdCode:        p.StdCode,
+		Generators:     string(p.Generators),
+		GenScript:      p.GenScript,
+		TestCases:      string(p.TestCases),
+		Visible:        true,
 	}
 }
 
@@ -28,11 +28,12 @@ func (h *UserHandler) clearAuthCookie(c *gin.Context) {
 const CookieTokenName = "token"
 
 type UserHandler struct {
-	service *service.UserService
+	service       *service.UserService
+	submitService *service.SubmitService
 }
 
-func NewUserHandler(s *service.UserService) *UserHandler {
-	return &UserHandler{service: s}
+func NewUserHandler(s *service.UserService, submitService *service.SubmitService) *UserHandler {
+	return &UserHandler{service: s, submitService: submitService}
 }
 
 // 统一错误响应
@@ -161,6 +162,12 @@ func (h *UserHandler) Info(c *gin.Context) {
 		return
 	}
 
+	quota, err := h.submitService.QuotaUsage(userID, user.Role == "admin")
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "internal error")
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"data": gin.H{
@@ -173,6 +180,7 @@ func (h *UserHandler) Info(c *gin.Context) {
 			"rating":       user.Rating,
 			"submit_count": user.SubmitCount,
 			"accept_count": user.AcceptCount,
+			"judge_quota":  quota,
 			"created_at":   user.CreatedAt,
 			"last_login_at": user.LastLoginAt,
 		},
@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
+	"github.com/oj/oj-backend/internal/service"
 )
 
 var upgrader = websocket.Upgrader{
@@ -102,14 +104,33 @@ func marshalMessage(msg *WSMessage) []byte {
 }
 
 func (h *WSHub) Broadcast(topic string, message interface{}) {
+	h.Send(topic, topic, message)
+}
+
+// Send 向 topic 的订阅者发送 msgType 类型的消息
+func (h *WSHub) Send(topic, msgType string, message interface{}) {
 	data, _ := json.Marshal(message)
 	h.broadcast <- &WSMessage{
-		Type:  topic,
+		Type:  msgType,
 		Topic: topic,
 		Data:  data,
 	}
 }
 
+// topics 当前有订阅者的、以 prefix 开头的 topic
+func (h *WSHub) topics(prefix string) []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	var topics []string
+	for topic, clients := range h.rooms {
+		if len(clients) > 0 && strings.HasPrefix(topic, prefix) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
 func (h *WSHub) Subscribe(client *WSClient, topic string) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
@@ -265,6 +286,34 @@ func (h *WSHub) SendStatusUpdate(submitID, status string, score int) {
 	})
 }
 
+// RunQueueFeed 每隔 interval 向订阅了 submit:<submit_id> 的客户端推送排队位置，
+// 排队中每次都推送，离开排队后只在状态变化时推送一次
+func (h *WSHub) RunQueueFeed(submitService *service.SubmitService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastStatus := map[string]string{}
+	for range ticker.C {
+		active := map[string]string{}
+		for _, topic := range h.topics("submit:") {
+			detail, err := submitService.GetDetail(strings.TrimPrefix(topic, "submit:"))
+			if err != nil {
+				continue
+			}
+			active[topic] = detail.Status
+			if detail.Queue == nil && lastStatus[topic] == detail.Status {
+				continue
+			}
+			h.Send(topic, "queue_position", gin.H{
+				"submit_id": detail.SubmitID,
+				"status":    detail.Status,
+				"queue":     detail.Queue,
+			})
+		}
+		lastStatus = active
+	}
+}
+
 // SendRankUpdate 发送榜单更新
 func (h *WSHub) SendRankUpdate(contestID int64, rankData interface{}) {
 	topic := "contest:" + string(rune(contestID))
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/service"
+)
+
+// WorkerHandler 评测机状态（管理员）
+type WorkerHandler struct {
+	service *service.WorkerService
+}
+
+func NewWorkerHandler(s *service.WorkerService) *WorkerHandler {
+	return &WorkerHandler{service: s}
+}
+
+func (h *WorkerHandler) List(c *gin.Context) {
+	workers, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": workers,
+	})
+}
+
+func (h *WorkerHandler) Get(c *gin.Context) {
+	worker, err := h.service.Get(c.Param("id"))
+	if err != nil {
+		status := workerErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": worker,
+	})
+}
+
+// Remove 清除失联 worker 的心跳记录
+func (h *WorkerHandler) Remove(c *gin.Context) {
+	if err := h.service.Remove(c.Param("id")); err != nil {
+		status := workerErrorStatus(err)
+		c.JSON(status, gin.H{"code": status, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0})
+}
+
+// Queue 各通道的积压
+func (h *WorkerHandler) Queue(c *gin.Context) {
+	depths, err := h.service.QueueDepth()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": depths,
+	})
+}
+
+func workerErrorStatus(err error) int {
+	if errors.Is(err, queue.ErrWorkerNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -53,13 +53,14 @@ type Language struct {
 	CompileTimeout int            `gorm:"default:10" json:"compile_timeout"`
 	RunCmd         string         `gorm:"type:text;not null" json:"run_cmd"`
 	RunTimeout     int            `gorm:"default:2" json:"run_timeout"`
-	DockerImage    string         `gorm:"size:200" json:"docker_image"`
 	TimeFactor     float64        `gorm:"default:1.0" json:"time_factor"`
 	MemoryFactor   float64        `gorm:"default:1.0" json:"memory_factor"`
 	OutputLimit    int            `gorm:"default:65536" json:"output_limit"`
 	PidsLimit      int            `gorm:"default:64" json:"pids_limit"`
 	Enabled        bool           `gorm:"default:true" json:"enabled"`
 	IsSPJ          bool           `gorm:"default:false" json:"is_spj"`
+	QueueClass     string         `gorm:"size:20;default:light" json:"queue_class"` // 评测队列，任务发布到 judge.tasks.<queue_class>.<lane>.u<user_id>
+	Capabilities   StringArray    `gorm:"type:text" json:"capabilities"`            // worker 需具备的能力（WORKER_CAPABILITIES）
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
@@ -67,37 +68,79 @@ type Language struct {
 
 // Problem 题目模型
 type Problem struct {
-	ID            int64          `gorm:"primaryKey" json:"id"`
-	Title         string         `gorm:"size:200" json:"title"`
-	Slug          string         `gorm:"uniqueIndex;size:100" json:"slug"`
-	Difficulty    int            `gorm:"default:3" json:"difficulty"`
-	Tags          StringArray    `gorm:"type:text" json:"tags"` // PostgreSQL array
-	Source        string         `gorm:"size:200" json:"source"`
-	Description   string         `gorm:"type:text" json:"description"`
-	InputFormat   string         `gorm:"type:text" json:"input_format"`
-	OutputFormat  string         `gorm:"type:text" json:"output_format"`
-	SampleIO      string         `gorm:"type:jsonb" json:"sample_io"`
-	Hint          string         `gorm:"type:text" json:"hint"`
-	TimeLimit     int            `gorm:"default:1000" json:"time_limit"`
-	MemoryLimit   int            `gorm:"default:256" json:"memory_limit"`
-	StackLimit    int            `gorm:"default:64" json:"stack_limit"`
-	IsSPJ         bool           `gorm:"default:false" json:"is_spj"`
-	SpjLang       string         `gorm:"size:30" json:"spj_lang"`
-	SpjCode       string         `gorm:"type:text" json:"spj_code"`
-	SpjCompileOut string         `gorm:"size:500" json:"spj_compile_out"`
-	TestCases     string         `gorm:"type:jsonb" json:"test_cases"`
-	TestDataZip   string         `gorm:"size:500" json:"test_data_zip"`
-	TestDataHash  string         `gorm:"size:64" json:"test_data_hash"`
-	SubmitCount   int            `gorm:"default:0" json:"submit_count"`
-	AcceptCount   int            `gorm:"default:0" json:"accept_count"`
-	AcceptRate    float64        `gorm:"default:0" json:"accept_rate"`
-	IsPublic      bool           `gorm:"default:false" json:"is_public"`
-	Visible       bool           `gorm:"default:true" json:"visible"`
-	CreatedBy     *int64         `json:"created_by"`
-	UpdatedBy     *int64         `json:"updated_by"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             int64          `gorm:"primaryKey" json:"id"`
+	Title          string         `gorm:"size:200" json:"title"`
+	Slug           string         `gorm:"uniqueIndex;size:100" json:"slug"`
+	Difficulty     int            `gorm:"default:3" json:"difficulty"`
+	Tags           StringArray    `gorm:"type:text" json:"tags"` // PostgreSQL array
+	Source         string         `gorm:"size:200" json:"source"`
+	Description    string         `gorm:"type:text" json:"description"`
+	InputFormat    string         `gorm:"type:text" json:"input_format"`
+	OutputFormat   string         `gorm:"type:text" json:"output_format"`
+	SampleIO       string         `gorm:"type:jsonb" json:"sample_io"`
+	Hint           string         `gorm:"type:text" json:"hint"`
+	TimeLimit      int            `gorm:"default:1000" json:"time_limit"`
+	MemoryLimit    int            `gorm:"default:256" json:"memory_limit"`
+	StackLimit     int            `gorm:"default:64" json:"stack_limit"`
+	IsSPJ          bool           `gorm:"default:false" json:"is_spj"`
+	SpjLang        string         `gorm:"size:30" json:"spj_lang"`
+	SpjCode        string         `gorm:"type:text" json:"spj_code"`
+	SpjCompileOut  string         `gorm:"size:500" json:"spj_compile_out"`
+	Checker        string         `gorm:"size:20;default:token" json:"checker"` // exact/token/token_icase/float
+	IsInteractive  bool           `gorm:"default:false" json:"is_interactive"`
+	InteractorLang string         `gorm:"size:30" json:"interactor_lang"`
+	InteractorCode string         `gorm:"type:text" json:"interactor_code"`
+	CheckerEpsilon float64        `gorm:"default:0" json:"checker_epsilon"`
+	JudgeMode      string         `gorm:"size:10" json:"judge_mode"` // acm/ioi，空为跟随比赛赛制
+	ValidatorLang  string         `gorm:"size:30" json:"validator_lang"`
+	ValidatorCode  string         `gorm:"type:text" json:"validator_code"` // 输入校验器，testlib 风格
+	StdLang        string         `gorm:"size:30" json:"std_lang"`
+	StdCode        string         `gorm:"type:text" json:"std_code"` // 标程，用于生成答案
+	TestCases      string         `gorm:"type:jsonb" json:"test_cases"`
+	TestDataZip    string         `gorm:"size:500" json:"test_data_zip"`
+	TestDataHash   string         `gorm:"size:64" json:"test_data_hash"`
+	TestDataCheck  string         `gorm:"type:text" json:"test_data_check"` // 校验器对各输入文件的检查结果
+	Generators     string         `gorm:"type:text" json:"generators"`      // 测试数据生成器，见 judge.GeneratorConfig
+	GenScript      string         `gorm:"type:text" json:"gen_script"`      // 生成脚本，每行形如 "gen 10 5 > 3"
+	TestDataGen    string         `gorm:"type:text" json:"test_data_gen"`   // 最近一次生成测试数据的结果
+	SubmitCount    int            `gorm:"default:0" json:"submit_count"`
+	AcceptCount    int            `gorm:"default:0" json:"accept_count"`
+	AcceptRate     float64        `gorm:"default:0" json:"accept_rate"`
+	IsPublic       bool           `gorm:"default:false" json:"is_public"`
+	Visible        bool           `gorm:"default:true" json:"visible"`
+	CreatedBy      *int64         `json:"created_by"`
+	UpdatedBy      *int64         `json:"updated_by"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// ProblemSolution 出题人登记的参考解，Expected 为预期结论，有多个时命中任一即可
+type ProblemSolution struct {
+	ID        int64          `gorm:"primaryKey" json:"id"`
+	ProblemID int64          `gorm:"index" json:"problem_id"`
+	Name      string         `gorm:"size:100" json:"name"`
+	Lang      string         `gorm:"size:30" json:"lang"`
+	Code      string         `gorm:"type:text" json:"code"`
+	Expected  StringArray    `gorm:"type:text" json:"expected"` // AC/WA/TLE/MLE/RE/OLE/PE/PARTIAL
+	CreatedBy *int64         `json:"created_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// ProblemInvocation 一次在完整测试集上运行全部参考解的记录
+type ProblemInvocation struct {
+	ID                 int64     `gorm:"primaryKey" json:"id"`
+	ProblemID          int64     `gorm:"index" json:"problem_id"`
+	TestDataHash       string    `gorm:"size:64" json:"test_data_hash"`
+	Status             string    `gorm:"size:20;default:PENDING" json:"status"`
+	Result             string    `gorm:"type:jsonb" json:"result"` // queue.InvokeResult
+	Mismatches         int       `gorm:"default:0" json:"mismatches"`
+	SuggestedTimeLimit int       `gorm:"default:0" json:"suggested_time_limit"`
+	CreatedBy          *int64    `json:"created_by"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 // Submission 提交记录模型
@@ -118,10 +161,14 @@ type Submission struct {
 	StartTime      *time.Time     `json:"start_time"`
 	FinishTime     *time.Time     `json:"finish_time"`
 	IsContest      bool           `gorm:"default:false" json:"is_contest"`
+	IsSample       bool           `gorm:"default:false;index" json:"is_sample"` // 只评测样例，不计入统计和罚时
 	ContestRank    *int           `json:"contest_rank"`
 	FrozenScore    string         `gorm:"type:jsonb" json:"frozen_score"`
 	IdempotencyKey string         `gorm:"uniqueIndex;size:100" json:"idempotency_key"`
 	RetryCount     int            `gorm:"default:0" json:"retry_count"`
+	JudgeErrors    string         `gorm:"type:text" json:"-"`                  // 历次评测失败记录，JSON 数组
+	QueueSubject   string         `gorm:"size:100;index" json:"queue_subject"` // 最近一次发布的评测任务 subject
+	QueuedAt       *time.Time     `json:"queued_at"`                           // 最近一次入队时间，重判和死信重放会刷新
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
@@ -148,6 +195,7 @@ type Contest struct {
 	Password       string         `gorm:"size:100" json:"password"`
 	IsPublic       bool           `gorm:"default:true" json:"is_public"`
 	Status         string         `gorm:"size:20;default:upcoming" json:"status"`
+	AllowHack      bool           `gorm:"default:false" json:"allow_hack"`
 	CreatedBy      *int64         `json:"created_by"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
@@ -169,6 +217,38 @@ type ContestParticipant struct {
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// Hack 比赛中对他人已通过提交的 hack
+type Hack struct {
+	ID             int64          `gorm:"primaryKey" json:"id"`
+	ContestID      int64          `gorm:"index" json:"contest_id"`
+	ProblemID      int64          `gorm:"index" json:"problem_id"`
+	HackerID       int64          `gorm:"index" json:"hacker_id"`
+	TargetSubmitID string         `gorm:"index;size:36" json:"target_submit_id"`
+	TargetUserID   int64          `gorm:"index" json:"target_user_id"`
+	Input          string         `gorm:"type:text" json:"input,omitempty"`
+	Answer         string         `gorm:"type:text" json:"-"` // 标程输出
+	Status         string         `gorm:"size:20;default:PENDING;index" json:"status"`
+	Verdict        string         `gorm:"size:20" json:"verdict"` // SUCCESS/FAILED/INVALID/ERROR
+	TargetVerdict  string         `gorm:"size:20" json:"target_verdict"`
+	Message        string         `gorm:"size:500" json:"message"`
+	Points         int            `gorm:"default:0" json:"points"`
+	AddedToTests   bool           `gorm:"default:false" json:"added_to_tests"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// DeadLetterAudit 管理员对死信队列的操作记录
+type DeadLetterAudit struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	AdminID   int64     `gorm:"index" json:"admin_id"`
+	Action    string    `gorm:"size:20" json:"action"` // REPLAY/DELETE/PURGE
+	Seq       int64     `json:"seq"`                   // 死信序号，PURGE 为 0
+	SubmitID  string    `gorm:"index;size:36" json:"submit_id"`
+	Detail    string    `gorm:"size:500" json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // StringArray PostgreSQL text[] 兼容类型
 type StringArray []string
 
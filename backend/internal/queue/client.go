@@ -18,10 +18,32 @@ type JudgeTask struct {
 	Problem        Problem   `json:"problem"`
 	Language       Language  `json:"language"`
 	Code           string    `json:"code"`
+	CodeHash       string    `json:"code_hash"` // 代码 sha256，编译产物缓存的键
 	Contest        *Contest  `json:"contest"`
 	User           User      `json:"user"`
 	RetryCount     int       `json:"retry_count"`
 	CreatedAt      time.Time `json:"created_at"`
+
+	// Errors 历次评测失败的记录，进入死信队列时一并保存
+	Errors []TaskError `json:"errors,omitempty"`
+
+	// SampleOnly 只评测公开样例，测试数据即 Samples
+	SampleOnly bool     `json:"sample_only,omitempty"`
+	Samples    []Sample `json:"samples,omitempty"`
+}
+
+// TaskError 一次评测失败的记录
+type TaskError struct {
+	Attempt  int       `json:"attempt"`
+	WorkerID string    `json:"worker_id"`
+	Error    string    `json:"error"`
+	Time     time.Time `json:"time"`
+}
+
+// Sample 题面中的公开样例
+type Sample struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
 }
 
 // Problem 题目信息
@@ -34,6 +56,30 @@ type Problem struct {
 	IsSPJ        bool   `json:"is_spj"`
 	TestDataZip  string `json:"test_data_zip"`
 	TestDataHash string `json:"test_data_hash"`
+
+	Checker        string  `json:"checker"`         // exact/token/token_icase/float，空为 token
+	CheckerEpsilon float64 `json:"checker_epsilon"` // float 比对的绝对/相对误差
+	SPJ            *SPJ    `json:"spj,omitempty"`   // IsSPJ 时使用，替代内置比对
+
+	IsInteractive bool `json:"is_interactive"`
+	Interactor    *SPJ `json:"interactor,omitempty"` // 交互题的交互器
+
+	Subtasks []Subtask `json:"subtasks,omitempty"` // 为空时按测试点平分 100 分
+
+	JudgeMode string `json:"judge_mode,omitempty"` // acm / ioi，空时跟随比赛赛制
+
+	// 校验器和标程，hack 等需要构造数据的任务才携带
+	Validator *SPJ `json:"validator,omitempty"`
+	Std       *SPJ `json:"std,omitempty"`
+}
+
+// Subtask 子任务：一组测试点及其分值
+type Subtask struct {
+	ID        int      `json:"id"`
+	Score     int      `json:"score"`
+	Policy    string   `json:"policy"`               // min / sum / all，空为 min
+	Cases     []string `json:"cases"`                // 测试点名，即 .in 文件去掉扩展名
+	DependsOn []int    `json:"depends_on,omitempty"` // 依赖的子任务必须全部通过，否则本组跳过
 }
 
 // Language 语言信息
@@ -45,7 +91,6 @@ type Language struct {
 	CompileTimeout int     `json:"compile_timeout"`
 	RunCmd         string  `json:"run_cmd"`
 	RunTimeout     int     `json:"run_timeout"`
-	DockerImage    string  `json:"docker_image"`
 	TimeFactor     float64 `json:"time_factor"`
 	MemoryFactor   float64 `json:"memory_factor"`
 	OutputLimit    int     `json:"output_limit"`
@@ -62,36 +107,82 @@ type User struct {
 type Contest struct {
 	ID             int64  `json:"id"`
 	Type           string `json:"type"`
+	RuleType       string `json:"rule_type"` // ACM/IOI，决定测试点是否遇错即停
 	PenaltyMinutes int    `json:"penalty_minutes"`
 	FrozenMinutes  int    `json:"frozen_minutes"`
 	IsVirtual      bool   `json:"is_virtual"`
 }
 
+// 评测生命周期状态
+const (
+	StatusPending   = "PENDING"
+	StatusCompiling = "COMPILING"
+	StatusRunning   = "RUNNING"
+	StatusDone      = "DONE"
+)
+
+// 最终结论，评测完成（StatusDone）后才有
+const (
+	VerdictAC      = "AC"
+	VerdictWA      = "WA"
+	VerdictTLE     = "TLE"
+	VerdictMLE     = "MLE"
+	VerdictRE      = "RE"
+	VerdictOLE     = "OLE"
+	VerdictCE      = "CE"
+	VerdictPE      = "PE"
+	VerdictSE      = "SE"
+	VerdictPartial = "PARTIAL" // 未通过的测试点都是部分得分
+)
+
 // JudgeResult 评测结果
 type JudgeResult struct {
-	Status       string     `json:"status"` // PENDING/RUNNING/FINISHED/SYSTEM_ERROR/DLQ
-	Score        int        `json:"score"`
-	AcceptedTest int        `json:"accepted_test"`
-	TotalTest    int        `json:"total_test"`
-	TimeMs       int        `json:"time_ms"`
-	MemoryKB     int        `json:"memory_kb"`
-	Cases        []TestCase `json:"cases"`
-	Error        string     `json:"error"`
-	RetryCount   int        `json:"retry_count"`
-	WorkerID     string     `json:"worker_id,omitempty"`
-	StartTime    *time.Time `json:"start_time,omitempty"`
-	FinishTime   *time.Time `json:"finish_time,omitempty"`
+	Status       string          `json:"status"`                 // 生命周期：PENDING/COMPILING/RUNNING/DONE
+	Verdict      string          `json:"verdict,omitempty"`      // 最终结论，见 Verdict* 常量
+	FirstFailed  int             `json:"first_failed,omitempty"` // 第一个未通过的测试点 ID
+	Score        int             `json:"score"`
+	AcceptedTest int             `json:"accepted_test"`
+	TotalTest    int             `json:"total_test"`
+	TimeMs       int             `json:"time_ms"`
+	MemoryKB     int             `json:"memory_kb"`
+	Cases        []TestCase      `json:"cases"`
+	Subtasks     []SubtaskResult `json:"subtasks,omitempty"`
+	HackedBy     int64           `json:"hacked_by,omitempty"` // 被成功 hack 时的 hack ID
+	Error        string          `json:"error"`
+	RetryCount   int             `json:"retry_count"`
+	WorkerID     string          `json:"worker_id,omitempty"`
+	StartTime    *time.Time      `json:"start_time,omitempty"`
+	FinishTime   *time.Time      `json:"finish_time,omitempty"`
+
+	// 编译信息单独落到 submissions.compile_info / compile_log_url
+	CompileInfo   string `json:"-"`
+	CompileLogURL string `json:"-"`
 }
 
 // TestCase 单个测试点结果
 type TestCase struct {
 	ID         int    `json:"id"`
-	Status     string `json:"status"` // AC/WA/TLE/MLE/RE/CE
+	Status     string `json:"status"` // AC/WA/PE/PC/TLE/MLE/OLE/RE/CE/SE/SKIPPED
 	TimeMs     int    `json:"time_ms"`
 	MemoryKB   int    `json:"memory_kb"`
 	Score      int    `json:"score"`
 	InputFile  string `json:"input_file,omitempty"`
 	OutputFile string `json:"output_file,omitempty"`
+	Message    string `json:"message,omitempty"` // 比对器给出的说明，如 WA 的首个差异
+
+	// 仅样例评测返回：输入、期望输出和选手输出
+	Input    string `json:"input,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// SubtaskResult 子任务得分
+type SubtaskResult struct {
+	ID        int    `json:"id"`
+	Status    string `json:"status"` // AC / 首个未通过测试点的状态 / SKIPPED
+	Score     int    `json:"score"`
+	FullScore int    `json:"full_score"`
+	Cases     []int  `json:"cases"` // 测试点 ID
 }
 
 // Client NATS 客户端
@@ -3,123 +3,323 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 )
 
-// Consumer NATS 消费者
+const (
+	// judgeAckWait 评测消息的确认超时，处理期间靠 InProgress 续期，worker 崩溃后约一分钟即可重新投递
+	judgeAckWait = time.Minute
+	// inProgressInterval 发送 InProgress 的间隔
+	inProgressInterval = judgeAckWait / 4
+	// idlePoll 所有通道都为空时的轮询间隔，决定空闲时的拉取延迟和停止消费的响应时间
+	idlePoll = 250 * time.Millisecond
+	// userConsumerIdle 用户的 consumer 无人拉取多久后由服务端删除，远长于最长的重试间隔
+	userConsumerIdle = time.Hour
+
+	// MaxDeliver 评测消息最多评测的次数（首次 + 3 次重试），用完后进入死信队列。
+	// 次数由 worker 按 Delivery.Attempt 判断，consumer 不设服务端上限：服务端达到上限后不再重投，
+	// 死信发布失败后 Nak 的消息、最后一次评测时 worker 崩溃的消息都会卡在 stream 中
+	MaxDeliver = 4
+
+	// attemptsHeader 停机交还的任务重新发布时带上此前已失败的次数，
+	// 新消息的投递次数从 1 开始，停机不占用重试次数
+	attemptsHeader = "Oj-Attempts"
+	// releaseTimeout 停机交还任务时重新发布的超时
+	releaseTimeout = 5 * time.Second
+)
+
+// Consumer NATS 消费者。每个用户在每个通道上有自己的 subject 和 durable consumer
+// （judge_tasks_<class>_<lane>_u<userID>），同一队列类别的 worker 共用。每次拉取前查询 stream 上
+// 有任务的 subject，只向这些用户的 consumer 拉取：按 laneSchedule 在通道间、按用户 ID 轮询在用户间，
+// 同一通道内每个有任务的用户轮流评测一条，与各自积压的提交数无关。
 type Consumer struct {
 	js           jetstream.JetStream
+	class        string
 	consumerName string
 	workerID     string
 	stream       string
+	// last 各通道上次取到任务的用户
+	last map[string]int64
+	// consumers 已创建的用户 consumer，按 subject 索引
+	consumers map[string]jetstream.Consumer
 }
 
-// NewConsumer 创建消费者
-func NewConsumer(js jetstream.JetStream, consumerName, workerID string) *Consumer {
+// NewConsumer 创建消费 class 队列的消费者
+func NewConsumer(js jetstream.JetStream, class, workerID string) *Consumer {
+	if class == "" {
+		class = DefaultQueueClass
+	}
 	return &Consumer{
 		js:           js,
-		consumerName: consumerName,
+		class:        class,
+		consumerName: "judge_tasks_" + class,
 		workerID:     workerID,
-		stream:       "OJ",
+		stream:       taskStream,
+		last:         make(map[string]int64, len(Lanes)),
+		consumers:    make(map[string]jetstream.Consumer),
 	}
 }
 
-// Consume 消费任务
-func (c *Consumer) Consume(ctx context.Context, handler func(*JudgeTask) error) error {
-	// 确保 stream 存在
-	if err := c.ensureStream(ctx); err != nil {
-		return fmt.Errorf("failed to ensure stream: %w", err)
+// Name durable consumer 名称前缀，各用户的 consumer 为 <Name>_<lane>_u<userID>
+func (c *Consumer) Name() string {
+	return c.consumerName
+}
+
+// Delivery 一条评测任务消息。处理方在结果落库后调用 Ack，失败时调用 NakWithDelay，
+// 停机时未开始的任务调用 Release；在此之前定期发送 InProgress，长时间评测不会因 AckWait 超时被重复投递。
+type Delivery struct {
+	Task *JudgeTask
+
+	msg  jetstream.Msg
+	js   publisher
+	base int // 重新发布前已失败的次数
+	stop chan struct{}
+	once sync.Once
+}
+
+// publisher 重新发布交还的任务，jetstream.JetStream 实现了它
+type publisher interface {
+	PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+func newDelivery(task *JudgeTask, msg jetstream.Msg, js publisher) *Delivery {
+	d := &Delivery{Task: task, msg: msg, js: js, stop: make(chan struct{})}
+	if h := msg.Headers(); h != nil {
+		d.base, _ = strconv.Atoi(h.Get(attemptsHeader))
+		d.base = max(d.base, 0)
 	}
+	go d.keepAlive()
+	return d
+}
 
-	// 确保 consumer 存在
-	if err := c.ensureConsumer(ctx); err != nil {
-		return fmt.Errorf("failed to ensure consumer: %w", err)
+func (d *Delivery) keepAlive() {
+	ticker := time.NewTicker(inProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if err := d.msg.InProgress(); err != nil {
+				log.Printf("Failed to send in-progress for task %s: %v", d.Task.SubmitID, err)
+			}
+		}
 	}
+}
+
+func (d *Delivery) finish() {
+	d.once.Do(func() { close(d.stop) })
+}
 
-	cons, err := c.js.Consumer(ctx, c.stream, c.consumerName)
+// NumDelivered 本条消息已投递的次数（含本次），包括 Nak 和 AckWait 超时造成的重投
+func (d *Delivery) NumDelivered() int {
+	meta, err := d.msg.Metadata()
 	if err != nil {
-		return fmt.Errorf("failed to get consumer: %w", err)
+		return 1
 	}
+	return int(meta.NumDelivered)
+}
 
-	log.Printf("Consumer %s started, listening on subject %s", c.consumerName, c.consumerName+"*")
+// Attempt 本次是第几次评测，停机交还造成的重新投递不计入
+func (d *Delivery) Attempt() int {
+	return d.base + d.NumDelivered()
+}
+
+// LastDelivery 是否已是最后一次评测，再失败不会重投
+func (d *Delivery) LastDelivery() bool {
+	return d.Attempt() >= MaxDeliver
+}
+
+// Subject 消息的原始 subject
+func (d *Delivery) Subject() string {
+	return d.msg.Subject()
+}
+
+// Ack 确认消息，评测结果已落库后调用
+func (d *Delivery) Ack() error {
+	d.finish()
+	return d.msg.Ack()
+}
 
-	_, err = cons.Consume(func(msg jetstream.Msg) {
+// Release 停机时交还尚未开始评测的任务：以相同 subject 重新发布并确认原消息。
+// 直接 Nak 或等 AckWait 超时都会让投递次数加一、占用重试次数；
+// 新消息带上此前已失败的次数，投递次数重新计算。发布失败时退回 Nak，宁可占用一次重试也不丢任务。
+func (d *Delivery) Release() error {
+	d.finish()
+	msg := nats.NewMsg(d.msg.Subject())
+	msg.Data = d.msg.Data()
+	msg.Header.Set(attemptsHeader, strconv.Itoa(d.Attempt()-1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+	if _, err := d.js.PublishMsg(ctx, msg); err != nil {
+		if nakErr := d.msg.Nak(); nakErr != nil {
+			return fmt.Errorf("republish: %v, nak: %w", err, nakErr)
+		}
+		return fmt.Errorf("republish: %w", err)
+	}
+	return d.msg.Ack()
+}
+
+// NakWithDelay 放回队列，delay 后重新投递
+func (d *Delivery) NakWithDelay(delay time.Duration) error {
+	d.finish()
+	return d.msg.NakWithDelay(delay)
+}
+
+// Consume 逐条拉取任务交给 handler，直到 ctx 取消。handler 负责调用 Ack/Nak，
+// 返回后才拉取下一条，因此 handler 应在有空闲槽位时才返回，避免在本地囤积消息。
+func (c *Consumer) Consume(ctx context.Context, handler func(*Delivery)) error {
+	// 确保 stream 存在且 subject 配置最新
+	if err := EnsureTaskStream(ctx, c.js); err != nil {
+		return err
+	}
+
+	stream, err := c.js.Stream(ctx, c.stream)
+	if err != nil {
+		return fmt.Errorf("failed to get task stream: %w", err)
+	}
+
+	log.Printf("Consumer %s started, listening on subject judge.tasks.%s.>", c.consumerName, c.class)
+
+	for tick := 0; ctx.Err() == nil; tick++ {
+		msg := c.fetch(ctx, stream, laneOrder(tick))
+		if msg == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(idlePoll):
+			}
+			continue
+		}
 		var task JudgeTask
 		if err := json.Unmarshal(msg.Data(), &task); err != nil {
 			log.Printf("Failed to unmarshal task: %v", err)
-			msg.Nak()
-			return
+			msg.Term()
+			continue
 		}
 
-		log.Printf("Received task: %s", task.SubmitID)
+		// 重试次数以 JetStream 的实际投递次数为准，扣除停机交还造成的重投
+		d := newDelivery(&task, msg, c.js)
+		task.RetryCount = d.Attempt() - 1
 
-		if err := handler(&task); err != nil {
-			log.Printf("Failed to process task %s: %v", task.SubmitID, err)
-			msg.NakWithDelay(time.Minute)
-			return
+		// 停止消费期间拉到的消息交还队列
+		if ctx.Err() != nil {
+			if err := d.Release(); err != nil {
+				log.Printf("Failed to release task %s: %v", task.SubmitID, err)
+			}
+			break
 		}
 
-		msg.Ack()
-	})
+		log.Printf("Received task: %s from %s (delivery %d)", task.SubmitID, msg.Subject(), task.RetryCount+1)
+		handler(d)
+	}
 
-	return err
+	log.Printf("Consumer %s stopped", c.consumerName)
+	return nil
 }
 
-func (c *Consumer) ensureStream(ctx context.Context) error {
-	_, err := c.js.Stream(ctx, c.stream)
-	if err == nil {
+// fetch 按 order 依次尝试各通道，通道内从上次取到任务的用户之后开始轮询有任务的用户，
+// 返回第一条拉到的消息，全部为空时返回 nil
+func (c *Consumer) fetch(ctx context.Context, stream jetstream.Stream, order []string) jetstream.Msg {
+	users, err := c.pendingUsers(ctx, stream)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to list pending tasks of %s: %v", c.class, err)
+		}
 		return nil
 	}
+	for _, lane := range order {
+		for _, user := range userOrder(users[lane], c.last[lane]) {
+			msg, err := c.fetchUser(ctx, lane, user)
+			if err != nil {
+				log.Printf("Failed to fetch task from %s: %v", TaskSubject(c.class, lane, user), err)
+				continue
+			}
+			if msg != nil {
+				c.last[lane] = user
+				return msg
+			}
+		}
+	}
+	return nil
+}
 
-	// Stream 不存在，创建它
-	_, err = c.js.CreateStream(ctx, jetstream.StreamConfig{
-		Name:      c.stream,
-		Subjects:  []string{"judge.tasks.*"},
-		Retention: jetstream.WorkQueuePolicy,
-		MaxBytes:  100 * 1024 * 1024, // 100MB
-		MaxAge:    24 * time.Hour,
-		Storage:   jetstream.MemoryStorage,
-	})
+// pendingUsers 各通道在 stream 上还有任务的用户。已投递未确认和等待重试的任务也计入，
+// 这些用户的拉取可能为空；不再有任务的用户从 consumer 缓存中移除。
+func (c *Consumer) pendingUsers(ctx context.Context, stream jetstream.Stream) (map[string][]int64, error) {
+	info, err := stream.Info(ctx, jetstream.WithSubjectFilter(laneSubject(c.class, "*")+".*"))
 	if err != nil {
-		return fmt.Errorf("failed to create stream: %w", err)
+		return nil, err
 	}
-
-	log.Printf("Created stream: %s", c.stream)
-	return nil
+	users := make(map[string][]int64, len(Lanes))
+	for subject, n := range info.State.Subjects {
+		class, lane, user, ok := ParseTaskSubject(subject)
+		if !ok || class != c.class || n == 0 {
+			continue
+		}
+		users[lane] = append(users[lane], user)
+	}
+	for subject := range c.consumers {
+		if info.State.Subjects[subject] == 0 {
+			delete(c.consumers, subject)
+		}
+	}
+	return users, nil
 }
 
-func (c *Consumer) ensureConsumer(ctx context.Context) error {
-	// 检查 consumer 是否存在
-	cons, err := c.js.Consumer(ctx, c.stream, c.consumerName)
+// fetchUser 从用户在 lane 通道上的 consumer 拉取一条消息，没有可投递的消息时返回 nil
+func (c *Consumer) fetchUser(ctx context.Context, lane string, userID int64) (jetstream.Msg, error) {
+	subject := TaskSubject(c.class, lane, userID)
+	cons, ok := c.consumers[subject]
+	if !ok {
+		var err error
+		if cons, err = c.ensureConsumer(ctx, lane, userID); err != nil {
+			return nil, err
+		}
+		c.consumers[subject] = cons
+	}
+	batch, err := cons.FetchNoWait(1)
 	if err == nil {
-		// Consumer 存在，获取信息确认
-		_, err = cons.Info(ctx)
-		if err == nil {
-			return nil
+		if msg := <-batch.Messages(); msg != nil {
+			return msg, nil
 		}
+		err = batch.Error()
+		if errors.Is(err, nats.ErrTimeout) || errors.Is(err, jetstream.ErrNoMessages) {
+			err = nil
+		}
+	}
+	if err != nil {
+		// consumer 可能已因长时间空闲被服务端删除，下次重新创建
+		delete(c.consumers, subject)
+		return nil, err
 	}
+	return nil, nil
+}
 
-	// Consumer 不存在，创建它
-	// 将 consumer name 中的下划线转换为点，生成 filter subject
-	filterSubject := "judge.tasks." + strings.TrimPrefix(c.consumerName, "judge_tasks_")
-	_, err = c.js.CreateConsumer(ctx, c.stream, jetstream.ConsumerConfig{
-		Name:          c.consumerName,
-		Durable:       c.consumerName,
-		FilterSubject: filterSubject,
-		AckPolicy:     jetstream.AckExplicitPolicy,
-		AckWait:       30 * time.Minute,
-		MaxDeliver:    4,
-		BackOff:       []time.Duration{1 * time.Second, 5 * time.Second, 30 * time.Second, 2 * time.Minute},
+// ensureConsumer 创建或更新用户在 lane 通道上的 consumer，已有的 consumer 也会更新为当前的 AckWait
+func (c *Consumer) ensureConsumer(ctx context.Context, lane string, userID int64) (jetstream.Consumer, error) {
+	name := fmt.Sprintf("%s_%s_u%d", c.consumerName, lane, userID)
+	// 不设置 BackOff：BackOff 会按投递次数覆盖 AckWait，让 InProgress 续期失效；
+	// 失败重试的间隔由 worker 通过 NakWithDelay 指定
+	cons, err := c.js.CreateOrUpdateConsumer(ctx, c.stream, jetstream.ConsumerConfig{
+		Name:              name,
+		Durable:           name,
+		FilterSubject:     TaskSubject(c.class, lane, userID),
+		AckPolicy:         jetstream.AckExplicitPolicy,
+		AckWait:           judgeAckWait,
+		MaxDeliver:        -1, // 不限制，见 MaxDeliver
+		InactiveThreshold: userConsumerIdle,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create consumer: %w", err)
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
 	}
-
-	log.Printf("Created consumer: %s", c.consumerName)
-	return nil
+	return cons, nil
 }
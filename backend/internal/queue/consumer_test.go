@@ -0,0 +1,301 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// fakeMsg 记录确认方式的 jetstream.Msg
+type fakeMsg struct {
+	jetstream.Msg
+	subject   string
+	data      []byte
+	header    nats.Header
+	delivered uint64
+
+	mu    sync.Mutex
+	acked bool
+	naked bool
+}
+
+func (m *fakeMsg) Subject() string      { return m.subject }
+func (m *fakeMsg) Data() []byte         { return m.data }
+func (m *fakeMsg) Headers() nats.Header { return m.header }
+func (m *fakeMsg) InProgress() error    { return nil }
+func (m *fakeMsg) Term() error          { return nil }
+func (m *fakeMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return &jetstream.MsgMetadata{NumDelivered: m.delivered}, nil
+}
+
+func (m *fakeMsg) Ack() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acked = true
+	return nil
+}
+
+func (m *fakeMsg) Nak() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.naked = true
+	return nil
+}
+
+func (m *fakeMsg) NakWithDelay(time.Duration) error { return m.Nak() }
+
+func (m *fakeMsg) state() (acked, naked bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.acked, m.naked
+}
+
+type fakeBatch struct {
+	msgs chan jetstream.Msg
+}
+
+func (b *fakeBatch) Messages() <-chan jetstream.Msg { return b.msgs }
+func (b *fakeBatch) Error() error                   { return nil }
+
+// fakeStream 按 subject 排队的消息，充当 stream 和各用户的 consumer
+type fakeStream struct {
+	jetstream.JetStream
+
+	mu        sync.Mutex
+	pending   map[string][]*fakeMsg
+	published []*nats.Msg
+	consumers map[string]string // 名称 -> filter subject
+	deleted   []string
+}
+
+func (s *fakeStream) push(m *fakeMsg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[string][]*fakeMsg)
+	}
+	s.pending[m.subject] = append(s.pending[m.subject], m)
+}
+
+func (s *fakeStream) CreateOrUpdateStream(ctx context.Context, cfg jetstream.StreamConfig) (jetstream.Stream, error) {
+	return nil, nil
+}
+
+func (s *fakeStream) CreateOrUpdateConsumer(ctx context.Context, stream string, cfg jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.consumers == nil {
+		s.consumers = make(map[string]string)
+	}
+	s.consumers[cfg.Name] = cfg.FilterSubject
+	return &fakeConsumer{stream: s, subject: cfg.FilterSubject}, nil
+}
+
+func (s *fakeStream) DeleteConsumer(ctx context.Context, stream, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.consumers, name)
+	s.deleted = append(s.deleted, name)
+	return nil
+}
+
+func (s *fakeStream) Stream(ctx context.Context, name string) (jetstream.Stream, error) {
+	return &fakeTaskStream{js: s}, nil
+}
+
+// fakeTaskStream 列出 fakeStream 上的 consumer 和有消息的 subject
+type fakeTaskStream struct {
+	jetstream.Stream
+	js *fakeStream
+}
+
+func (s *fakeTaskStream) Info(ctx context.Context, opts ...jetstream.StreamInfoOpt) (*jetstream.StreamInfo, error) {
+	s.js.mu.Lock()
+	defer s.js.mu.Unlock()
+	subjects := make(map[string]uint64, len(s.js.pending))
+	for subject, q := range s.js.pending {
+		if len(q) > 0 {
+			subjects[subject] = uint64(len(q))
+		}
+	}
+	return &jetstream.StreamInfo{State: jetstream.StreamState{Subjects: subjects}}, nil
+}
+
+func (s *fakeTaskStream) ListConsumers(ctx context.Context) jetstream.ConsumerInfoLister {
+	s.js.mu.Lock()
+	defer s.js.mu.Unlock()
+	infos := make(chan *jetstream.ConsumerInfo, len(s.js.consumers))
+	for name, filter := range s.js.consumers {
+		infos <- &jetstream.ConsumerInfo{Name: name, Config: jetstream.ConsumerConfig{Name: name, FilterSubject: filter}}
+	}
+	close(infos)
+	return &fakeLister{infos: infos}
+}
+
+type fakeLister struct {
+	infos chan *jetstream.ConsumerInfo
+}
+
+func (l *fakeLister) Info() <-chan *jetstream.ConsumerInfo { return l.infos }
+func (l *fakeLister) Err() error                           { return nil }
+
+func (s *fakeStream) PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published = append(s.published, msg)
+	return &jetstream.PubAck{}, nil
+}
+
+type fakeConsumer struct {
+	jetstream.Consumer
+	stream  *fakeStream
+	subject string
+}
+
+// FetchNoWait 按 subject 顺序取出与 filter 匹配的消息
+func (c *fakeConsumer) FetchNoWait(n int) (jetstream.MessageBatch, error) {
+	c.stream.mu.Lock()
+	defer c.stream.mu.Unlock()
+	subjects := make([]string, 0, len(c.stream.pending))
+	for subject := range c.stream.pending {
+		if subjectMatches(c.subject, subject) {
+			subjects = append(subjects, subject)
+		}
+	}
+	sort.Strings(subjects)
+	batch := &fakeBatch{msgs: make(chan jetstream.Msg, n)}
+	for _, subject := range subjects {
+		q := c.stream.pending[subject]
+		for len(q) > 0 && len(batch.msgs) < n {
+			batch.msgs <- q[0]
+			q = q[1:]
+		}
+		c.stream.pending[subject] = q
+	}
+	close(batch.msgs)
+	return batch, nil
+}
+
+func taskMsg(t *testing.T, submitID string, userID int64, delivered uint64, header nats.Header) *fakeMsg {
+	t.Helper()
+	data, err := json.Marshal(&JudgeTask{SubmitID: submitID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeMsg{
+		subject:   TaskSubject(DefaultQueueClass, LaneContest, userID),
+		data:      data,
+		header:    header,
+		delivered: delivered,
+	}
+}
+
+// 停机时正在评测的任务照常完成并确认，之后拉到的任务交还队列，二者都不占用重试次数
+func TestConsumeShutdownWhileHandling(t *testing.T) {
+	js := &fakeStream{}
+	running := taskMsg(t, "running", 1, 2, nil)
+	queued := taskMsg(t, "queued", 2, 1, nil)
+	js.push(running)
+	js.push(queued)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan *Delivery)
+	finish := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- NewConsumer(js, "", "w1").Consume(ctx, func(d *Delivery) {
+			started <- d
+			<-finish
+			d.Ack()
+		})
+	}()
+
+	d := <-started
+	if d.Task.SubmitID != "running" || d.Task.RetryCount != 1 {
+		t.Fatalf("got task %s retry %d, want running retry 1", d.Task.SubmitID, d.Task.RetryCount)
+	}
+	cancel()
+	// 停止后 handler 仍在运行，Consume 等它返回
+	select {
+	case <-done:
+		t.Fatal("Consume returned while handler was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(finish)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if acked, naked := running.state(); !acked || naked {
+		t.Errorf("running task acked=%v naked=%v, want acked only", acked, naked)
+	}
+	if _, naked := queued.state(); naked {
+		t.Error("queued task was naked, which would consume a retry")
+	}
+}
+
+// 同一通道内有任务的用户轮流取一条，积压多的用户不会挤占其他用户
+func TestConsumeRoundRobinUsers(t *testing.T) {
+	js := &fakeStream{}
+	for i := 0; i < 4; i++ {
+		js.push(taskMsg(t, "heavy", 1, 1, nil))
+	}
+	js.push(taskMsg(t, "b", 2, 1, nil))
+	js.push(taskMsg(t, "c", 3, 1, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got []string
+	err := NewConsumer(js, "", "w1").Consume(ctx, func(d *Delivery) {
+		got = append(got, d.Task.SubmitID)
+		d.Ack()
+		if len(got) == 6 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"heavy", "b", "c", "heavy", "heavy", "heavy"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("handled %v, want %v", got, want)
+	}
+}
+
+func TestDeliveryRelease(t *testing.T) {
+	js := &fakeStream{}
+	msg := taskMsg(t, "s1", 1, 2, nil) // 第一次失败后的重投
+	d := newDelivery(&JudgeTask{SubmitID: "s1"}, msg, js)
+	if err := d.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if acked, naked := msg.state(); !acked || naked {
+		t.Fatalf("released message acked=%v naked=%v, want acked only", acked, naked)
+	}
+	if len(js.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(js.published))
+	}
+	pub := js.published[0]
+	if pub.Subject != msg.subject || string(pub.Data) != string(msg.data) {
+		t.Errorf("republished %s %s, want original subject and data", pub.Subject, pub.Data)
+	}
+
+	// 重新发布的消息从第 1 次投递开始，此前失败的 1 次仍然计入
+	again := newDelivery(&JudgeTask{SubmitID: "s1"}, &fakeMsg{header: pub.Header, delivered: 1}, js)
+	if again.Attempt() != 2 {
+		t.Errorf("attempt = %d, want 2", again.Attempt())
+	}
+	last := newDelivery(&JudgeTask{SubmitID: "s1"}, &fakeMsg{header: nats.Header{attemptsHeader: []string{"3"}}, delivered: 1}, js)
+	if !last.LastDelivery() {
+		t.Error("delivery after 3 failures should be the last one")
+	}
+	again.finish()
+	last.finish()
+}
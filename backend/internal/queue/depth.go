@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// LaneDepth 一个队列类别下一个通道的积压，由该通道各用户 subject 的消息数和 consumer 的状态汇总
+type LaneDepth struct {
+	QueueClass  string `json:"queue_class"`
+	Lane        string `json:"lane"`
+	Pending     uint64 `json:"pending"`     // 尚未投递
+	InFlight    int    `json:"in_flight"`   // 已投递、等待确认（评测中或等待重试）
+	Redelivered int    `json:"redelivered"` // 已投递不止一次、仍未确认
+	Waiting     int    `json:"waiting"`     // 正在等待任务的拉取请求
+	Users       int    `json:"users"`       // 有任务的用户数
+}
+
+// laneRank 通道的优先级排序位置，未知通道排在最后
+func laneRank(lane string) int {
+	for i, l := range Lanes {
+		if l == lane {
+			return i
+		}
+	}
+	return len(Lanes)
+}
+
+// LaneDepths 按队列类别和通道汇总评测任务 stream 上的积压，旧格式的 subject 和 consumer 不计入
+func LaneDepths(ctx context.Context, js jetstream.JetStream) ([]LaneDepth, error) {
+	stream, err := js.Stream(ctx, taskStream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task stream: %w", err)
+	}
+
+	depths := map[[2]string]*LaneDepth{}
+	depth := func(class, lane string) *LaneDepth {
+		key := [2]string{class, lane}
+		d, ok := depths[key]
+		if !ok {
+			d = &LaneDepth{QueueClass: class, Lane: lane}
+			depths[key] = d
+		}
+		return d
+	}
+
+	// stream 上的消息数包括已投递未确认的，扣除 consumer 的 NumAckPending 后为尚未投递的
+	info, err := stream.Info(ctx, jetstream.WithSubjectFilter("judge.tasks.>"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task stream info: %w", err)
+	}
+	total := map[[2]string]uint64{}
+	for subject, n := range info.State.Subjects {
+		class, lane, _, ok := ParseTaskSubject(subject)
+		if !ok || n == 0 {
+			continue
+		}
+		depth(class, lane).Users++
+		total[[2]string{class, lane}] += n
+	}
+
+	lister := stream.ListConsumers(ctx)
+	for info := range lister.Info() {
+		class, lane, _, ok := ParseTaskSubject(info.Config.FilterSubject)
+		if !ok {
+			continue
+		}
+		d := depth(class, lane)
+		d.InFlight += info.NumAckPending
+		d.Redelivered += info.NumRedelivered
+		d.Waiting += info.NumWaiting
+	}
+	if err := lister.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list consumers: %w", err)
+	}
+	for key, d := range depths {
+		if n := total[key]; n > uint64(d.InFlight) {
+			d.Pending = n - uint64(d.InFlight)
+		}
+	}
+
+	result := make([]LaneDepth, 0, len(depths))
+	for _, d := range depths {
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].QueueClass != result[j].QueueClass {
+			return result[i].QueueClass < result[j].QueueClass
+		}
+		return laneRank(result[i].Lane) < laneRank(result[j].Lane)
+	})
+	return result, nil
+}
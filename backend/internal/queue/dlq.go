@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// DLQSubject 重试耗尽的评测任务
+	DLQSubject = "judge.dlq"
+
+	// 死信不用 WorkQueue，消息留在 stream 中供查看，重放或清除时再删除
+	dlqStream = "OJ_DLQ"
+	dlqMaxAge = 14 * 24 * time.Hour
+)
+
+// ErrDeadLetterNotFound 死信不存在或已被重放/删除
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter 死信：原任务（含 RetryCount 和 Errors）及其原始 subject
+type DeadLetter struct {
+	Seq      uint64    `json:"seq"` // stream 序号，读取时填充
+	Subject  string    `json:"subject"`
+	Task     JudgeTask `json:"task"`
+	WorkerID string    `json:"worker_id"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// EnsureDLQStream 确保死信 stream 存在
+func EnsureDLQStream(ctx context.Context, js jetstream.JetStream) error {
+	if _, err := js.Stream(ctx, dlqStream); err == nil {
+		return nil
+	}
+	_, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:      dlqStream,
+		Subjects:  []string{DLQSubject},
+		Retention: jetstream.LimitsPolicy,
+		MaxAge:    dlqMaxAge,
+		Storage:   jetstream.FileStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create dlq stream: %w", err)
+	}
+	return nil
+}
+
+func dlq(ctx context.Context, js jetstream.JetStream) (jetstream.Stream, error) {
+	if err := EnsureDLQStream(ctx, js); err != nil {
+		return nil, err
+	}
+	return js.Stream(ctx, dlqStream)
+}
+
+// GetDeadLetter 按序号读取死信
+func GetDeadLetter(ctx context.Context, js jetstream.JetStream, seq uint64) (*DeadLetter, error) {
+	stream, err := dlq(ctx, js)
+	if err != nil {
+		return nil, err
+	}
+	return getDeadLetter(ctx, stream, seq)
+}
+
+func getDeadLetter(ctx context.Context, stream jetstream.Stream, seq uint64) (*DeadLetter, error) {
+	msg, err := stream.GetMsg(ctx, seq)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			return nil, ErrDeadLetterNotFound
+		}
+		return nil, err
+	}
+	var dl DeadLetter
+	if err := json.Unmarshal(msg.Data, &dl); err != nil {
+		return nil, fmt.Errorf("invalid dead letter %d: %w", seq, err)
+	}
+	dl.Seq = msg.Sequence
+	return &dl, nil
+}
+
+// ListDeadLetters 从新到旧分页列出死信，返回当前死信总数
+func ListDeadLetters(ctx context.Context, js jetstream.JetStream, offset, limit int) ([]DeadLetter, int, error) {
+	stream, err := dlq(ctx, js)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	letters := []DeadLetter{}
+	total := int(info.State.Msgs)
+	if total == 0 {
+		return letters, 0, nil
+	}
+	skipped := 0
+	for seq := info.State.LastSeq; seq >= info.State.FirstSeq && seq > 0 && len(letters) < limit; seq-- {
+		dl, err := getDeadLetter(ctx, stream, seq)
+		if errors.Is(err, ErrDeadLetterNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		letters = append(letters, *dl)
+	}
+	return letters, total, nil
+}
+
+// DeadLetterSeqs 当前所有死信的序号，从旧到新
+func DeadLetterSeqs(ctx context.Context, js jetstream.JetStream) ([]uint64, error) {
+	stream, err := dlq(ctx, js)
+	if err != nil {
+		return nil, err
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seqs := make([]uint64, 0, info.State.Msgs)
+	if info.State.Msgs == 0 {
+		return seqs, nil
+	}
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq; seq++ {
+		if _, err := stream.GetMsg(ctx, seq); err != nil {
+			if errors.Is(err, jetstream.ErrMsgNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		seqs = append(seqs, seq)
+	}
+	return seqs, nil
+}
+
+// DeleteDeadLetter 删除一条死信
+func DeleteDeadLetter(ctx context.Context, js jetstream.JetStream, seq uint64) error {
+	stream, err := dlq(ctx, js)
+	if err != nil {
+		return err
+	}
+	if err := stream.DeleteMsg(ctx, seq); err != nil {
+		if errors.Is(err, jetstream.ErrMsgNotFound) || errors.Is(err, jetstream.ErrMsgDeleteUnsuccessful) {
+			return ErrDeadLetterNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// PurgeDeadLetters 清空死信，返回清除的条数
+func PurgeDeadLetters(ctx context.Context, js jetstream.JetStream) (uint64, error) {
+	stream, err := dlq(ctx, js)
+	if err != nil {
+		return 0, err
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := stream.Purge(ctx); err != nil {
+		return 0, err
+	}
+	return info.State.Msgs, nil
+}
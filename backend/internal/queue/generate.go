@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// GenerateSubject 由生成器和标程生产测试数据的任务
+	GenerateSubject = "judge.prepare.generate"
+
+	// 出题相关的耗时任务共用一个 stream，不与评测排队
+	prepareStream   = "OJ_PREPARE"
+	prepareSubjects = "judge.prepare.>"
+)
+
+// 测试数据生产状态
+const (
+	GenerateStatusPending = "PENDING"
+	GenerateStatusDone    = "DONE"
+	GenerateStatusFailed  = "FAILED"
+)
+
+// Generator 测试数据生成器，脚本中按名字引用
+type Generator struct {
+	Name string `json:"name"`
+	SPJ
+}
+
+// GenerateTask 按脚本运行生成器得到输入，再用标程得到答案，打包上传
+type GenerateTask struct {
+	ProblemID  int64       `json:"problem_id"`
+	Generators []Generator `json:"generators"`
+	Script     string      `json:"script"`
+	Std        SPJ         `json:"std"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// GenerateResult 测试数据生产结果，存于 Problem.TestDataGen
+type GenerateResult struct {
+	Status     string     `json:"status"`
+	Message    string     `json:"message,omitempty"`
+	Tests      int        `json:"tests,omitempty"`
+	Location   string     `json:"location,omitempty"`
+	Hash       string     `json:"hash,omitempty"`
+	FinishTime *time.Time `json:"finish_time,omitempty"`
+}
+
+// ensurePrepareStream 确保出题任务的 stream 存在
+func ensurePrepareStream(ctx context.Context, js jetstream.JetStream) error {
+	if _, err := js.Stream(ctx, prepareStream); err == nil {
+		return nil
+	}
+	_, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:      prepareStream,
+		Subjects:  []string{prepareSubjects},
+		Retention: jetstream.WorkQueuePolicy,
+		MaxAge:    24 * time.Hour,
+		Storage:   jetstream.FileStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create prepare stream: %w", err)
+	}
+	return nil
+}
+
+// PublishGenerate 发布测试数据生产任务
+func PublishGenerate(ctx context.Context, js jetstream.JetStream, task *GenerateTask) error {
+	if err := ensurePrepareStream(ctx, js); err != nil {
+		return err
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = js.Publish(ctx, GenerateSubject, data)
+	return err
+}
+
+// ConsumeGenerate 消费测试数据生产任务，handler 返回错误时延迟重投
+func ConsumeGenerate(ctx context.Context, js jetstream.JetStream, handler func(*GenerateTask) error) (jetstream.ConsumeContext, error) {
+	if err := ensurePrepareStream(ctx, js); err != nil {
+		return nil, err
+	}
+	cons, err := js.CreateOrUpdateConsumer(ctx, prepareStream, jetstream.ConsumerConfig{
+		Durable:       "judge_prepare_generate",
+		FilterSubject: GenerateSubject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       30 * time.Minute,
+		MaxDeliver:    3,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create generate consumer: %w", err)
+	}
+
+	return cons.Consume(func(msg jetstream.Msg) {
+		var task GenerateTask
+		if err := json.Unmarshal(msg.Data(), &task); err != nil {
+			log.Printf("Failed to unmarshal generate task: %v", err)
+			msg.Term()
+			return
+		}
+		if err := handler(&task); err != nil {
+			log.Printf("Failed to generate test data for problem %d: %v", task.ProblemID, err)
+			msg.NakWithDelay(time.Minute)
+			return
+		}
+		msg.Ack()
+	})
+}
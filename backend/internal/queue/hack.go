@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// HackSubject hack 任务，与评测任务分开的 stream，不与评测排队
+	HackSubject = "judge.hacks"
+
+	hackStream   = "OJ_HACKS"
+	hackConsumer = "judge_hacks"
+)
+
+// hack 结论
+const (
+	HackSuccess = "SUCCESS" // 目标提交在该输入上未通过
+	HackFailed  = "FAILED"  // 目标提交通过
+	HackInvalid = "INVALID" // 输入未通过校验器
+	HackError   = "ERROR"   // 标程失败或题目未配置校验器/标程
+)
+
+// HackTask 用参赛者构造的输入测试他人已通过的提交
+type HackTask struct {
+	HackID    int64     `json:"hack_id"`
+	ContestID int64     `json:"contest_id"`
+	Problem   Problem   `json:"problem"` // 需带 Validator 和 Std
+	Language  Language  `json:"language"`
+	Code      string    `json:"code"`
+	CodeHash  string    `json:"code_hash"`
+	Input     string    `json:"input"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HackResult hack 结果
+type HackResult struct {
+	HackID        int64  `json:"hack_id"`
+	Verdict       string `json:"verdict"`        // 见 Hack* 常量
+	TargetVerdict string `json:"target_verdict"` // 目标提交在该输入上的结论
+	Message       string `json:"message"`
+	Answer        string `json:"answer"` // 标程输出，加入系统测试时作为答案
+	Points        int    `json:"points"` // hacker 的得分变化
+}
+
+// EnsureHackStream 确保 hack 任务的 stream 存在
+func EnsureHackStream(ctx context.Context, js jetstream.JetStream) error {
+	if _, err := js.Stream(ctx, hackStream); err == nil {
+		return nil
+	}
+	_, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:      hackStream,
+		Subjects:  []string{HackSubject},
+		Retention: jetstream.WorkQueuePolicy,
+		MaxAge:    24 * time.Hour,
+		Storage:   jetstream.FileStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create hack stream: %w", err)
+	}
+	return nil
+}
+
+// PublishHack 发布 hack 任务
+func PublishHack(ctx context.Context, js jetstream.JetStream, task *HackTask) error {
+	if err := EnsureHackStream(ctx, js); err != nil {
+		return err
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = js.Publish(ctx, HackSubject, data)
+	return err
+}
+
+// ConsumeHacks 消费 hack 任务，handler 返回错误时延迟重投
+func ConsumeHacks(ctx context.Context, js jetstream.JetStream, handler func(*HackTask) error) (jetstream.ConsumeContext, error) {
+	if err := EnsureHackStream(ctx, js); err != nil {
+		return nil, err
+	}
+	cons, err := js.CreateOrUpdateConsumer(ctx, hackStream, jetstream.ConsumerConfig{
+		Durable:       hackConsumer,
+		FilterSubject: HackSubject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       5 * time.Minute,
+		MaxDeliver:    4,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hack consumer: %w", err)
+	}
+
+	return cons.Consume(func(msg jetstream.Msg) {
+		var task HackTask
+		if err := json.Unmarshal(msg.Data(), &task); err != nil {
+			log.Printf("Failed to unmarshal hack task: %v", err)
+			msg.Term()
+			return
+		}
+		if err := handler(&task); err != nil {
+			log.Printf("Failed to process hack %d: %v", task.HackID, err)
+			msg.NakWithDelay(30 * time.Second)
+			return
+		}
+		msg.Ack()
+	})
+}
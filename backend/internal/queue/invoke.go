@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// InvokeSubject 在完整测试集上运行题目全部参考解的任务
+const InvokeSubject = "judge.prepare.invoke"
+
+// 参考解运行状态
+const (
+	InvokeStatusPending = "PENDING"
+	InvokeStatusDone    = "DONE"
+	InvokeStatusFailed  = "FAILED"
+)
+
+// InvokeSolution 参考解及其预期结论，Expected 有多个时命中任一即可
+type InvokeSolution struct {
+	ID       int64    `json:"id"`
+	Name     string   `json:"name"`
+	Language Language `json:"language"`
+	Code     string   `json:"code"`
+	CodeHash string   `json:"code_hash"`
+	Expected []string `json:"expected"`
+}
+
+// InvokeTask 参考解运行任务
+type InvokeTask struct {
+	InvocationID int64            `json:"invocation_id"`
+	Problem      Problem          `json:"problem"`
+	Solutions    []InvokeSolution `json:"solutions"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+// SolutionRun 单个参考解在各测试点上的结果
+type SolutionRun struct {
+	SolutionID  int64      `json:"solution_id"`
+	Name        string     `json:"name"`
+	Expected    []string   `json:"expected"`
+	Verdict     string     `json:"verdict"`
+	Matched     bool       `json:"matched"`
+	TimeMs      int        `json:"time_ms"`   // 各测试点最大值
+	MemoryKB    int        `json:"memory_kb"` // 各测试点最大值
+	CompileInfo string     `json:"compile_info,omitempty"`
+	Cases       []TestCase `json:"cases"`
+}
+
+// InvokeResult 参考解运行结果：结论矩阵、与预期不符的数量和建议的时间限制
+type InvokeResult struct {
+	InvocationID       int64         `json:"invocation_id"`
+	Status             string        `json:"status"`
+	Message            string        `json:"message,omitempty"`
+	Runs               []SolutionRun `json:"runs,omitempty"`
+	Mismatches         int           `json:"mismatches"`
+	SuggestedTimeLimit int           `json:"suggested_time_limit"` // ms，没有通过的标准解时为 0
+	Warnings           []string      `json:"warnings,omitempty"`
+	FinishTime         *time.Time    `json:"finish_time,omitempty"`
+}
+
+// PublishInvoke 发布参考解运行任务
+func PublishInvoke(ctx context.Context, js jetstream.JetStream, task *InvokeTask) error {
+	if err := ensurePrepareStream(ctx, js); err != nil {
+		return err
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = js.Publish(ctx, InvokeSubject, data)
+	return err
+}
+
+// ConsumeInvoke 消费参考解运行任务，handler 返回错误时延迟重投
+func ConsumeInvoke(ctx context.Context, js jetstream.JetStream, handler func(*InvokeTask) error) (jetstream.ConsumeContext, error) {
+	if err := ensurePrepareStream(ctx, js); err != nil {
+		return nil, err
+	}
+	cons, err := js.CreateOrUpdateConsumer(ctx, prepareStream, jetstream.ConsumerConfig{
+		Durable:       "judge_prepare_invoke",
+		FilterSubject: InvokeSubject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       30 * time.Minute,
+		MaxDeliver:    3,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoke consumer: %w", err)
+	}
+
+	return cons.Consume(func(msg jetstream.Msg) {
+		var task InvokeTask
+		if err := json.Unmarshal(msg.Data(), &task); err != nil {
+			log.Printf("Failed to unmarshal invoke task: %v", err)
+			msg.Term()
+			return
+		}
+		if err := handler(&task); err != nil {
+			log.Printf("Failed to invoke solutions for invocation %d: %v", task.InvocationID, err)
+			msg.NakWithDelay(time.Minute)
+			return
+		}
+		msg.Ack()
+	})
+}
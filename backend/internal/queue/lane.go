@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// taskStream 评测任务 stream，subject 为 judge.tasks.<class>.<lane>.u<userID>
+	taskStream = "OJ"
+	// DefaultQueueClass 未配置队列类别的语言进入 light 队列
+	DefaultQueueClass = "light"
+)
+
+// 评测优先级通道，按优先级从高到低
+const (
+	LaneContest  = "contest"
+	LanePractice = "practice"
+	LaneRejudge  = "rejudge"
+)
+
+// Lanes 所有通道，按优先级从高到低
+var Lanes = []string{LaneContest, LanePractice, LaneRejudge}
+
+// laneSchedule 每 10 次拉取中各通道优先的次数为 contest 6、practice 3、rejudge 1。
+// 轮到的通道为空时按优先级取其他通道，因此空闲时任何通道都能立即被消费，
+// 繁忙时高优先级通道占大头，低优先级通道也有保底份额不会饿死。
+var laneSchedule = []string{
+	LaneContest, LanePractice, LaneContest, LaneContest, LanePractice,
+	LaneContest, LaneRejudge, LaneContest, LanePractice, LaneContest,
+}
+
+// laneOrder 第 tick 次拉取时各通道的尝试顺序：轮到的通道在前，其余按优先级
+func laneOrder(tick int) []string {
+	first := laneSchedule[tick%len(laneSchedule)]
+	order := make([]string, 0, len(Lanes))
+	order = append(order, first)
+	for _, lane := range Lanes {
+		if lane != first {
+			order = append(order, lane)
+		}
+	}
+	return order
+}
+
+// userOrder 通道内各用户的尝试顺序：按用户 ID 升序，从 last 之后的第一个用户开始循环。
+// 每轮每个有任务的用户只取一条，提交多的用户不会挤占其他用户的份额。
+func userOrder(users []int64, last int64) []int64 {
+	sorted := append([]int64(nil), users...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	start := sort.Search(len(sorted), func(i int) bool { return sorted[i] > last })
+	order := make([]int64, 0, len(sorted))
+	order = append(order, sorted[start:]...)
+	return append(order, sorted[:start]...)
+}
+
+// laneSubject 队列类别和通道对应的 subject 前缀（不含用户）
+func laneSubject(class, lane string) string {
+	if class == "" {
+		class = DefaultQueueClass
+	}
+	if lane == "" {
+		lane = LanePractice
+	}
+	return "judge.tasks." + class + "." + lane
+}
+
+// ParseTaskSubject 拆分 judge.tasks.<class>.<lane>.u<userID>，旧格式的 subject 返回 ok=false
+func ParseTaskSubject(subject string) (class, lane string, userID int64, ok bool) {
+	tokens := strings.Split(subject, ".")
+	if len(tokens) != 5 || tokens[0] != "judge" || tokens[1] != "tasks" || !strings.HasPrefix(tokens[4], "u") {
+		return "", "", 0, false
+	}
+	userID, err := strconv.ParseInt(tokens[4][1:], 10, 64)
+	if err != nil {
+		return "", "", 0, false
+	}
+	return tokens[2], tokens[3], userID, true
+}
+
+// TaskSubject 用户提交的评测任务 subject，每个用户在每个通道上有自己的 subject
+func TaskSubject(class, lane string, userID int64) string {
+	return fmt.Sprintf("%s.u%d", laneSubject(class, lane), userID)
+}
+
+// ReplaySubject 重放死信时使用的 subject，按任务的用户重新生成。补全旧版本写入的 subject：
+// judge.tasks.<class> 按 practice 处理，没有用户或按 user_id % 8 分片的换成用户自己的 subject。
+func ReplaySubject(subject string, userID int64) string {
+	tokens := strings.Split(subject, ".")
+	if len(tokens) < 3 {
+		return subject
+	}
+	return TaskSubject(tokens[2], SubjectLane(subject), userID)
+}
+
+// SubjectLane 任务 subject 所在的通道，旧版本没有通道的 judge.tasks.<class> 按 practice 处理
+func SubjectLane(subject string) string {
+	tokens := strings.Split(subject, ".")
+	if len(tokens) < 4 || tokens[3] == "" {
+		return LanePractice
+	}
+	return tokens[3]
+}
+
+// EnsureTaskStream 创建评测任务 stream，已存在时更新为当前的 subject 配置
+func EnsureTaskStream(ctx context.Context, js jetstream.JetStream) error {
+	_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      taskStream,
+		Subjects:  []string{"judge.tasks.>"},
+		Retention: jetstream.WorkQueuePolicy,
+		MaxBytes:  100 * 1024 * 1024, // 100MB
+		MaxAge:    24 * time.Hour,
+		Storage:   jetstream.MemoryStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to ensure task stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLaneOrder(t *testing.T) {
+	counts := map[string]int{}
+	for tick := 0; tick < len(laneSchedule); tick++ {
+		order := laneOrder(tick)
+		if len(order) != len(Lanes) {
+			t.Fatalf("tick %d: order %v", tick, order)
+		}
+		counts[order[0]]++
+	}
+	want := map[string]int{LaneContest: 6, LanePractice: 3, LaneRejudge: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("first lane counts = %v, want %v", counts, want)
+	}
+
+	// 轮到 rejudge 时其余通道仍按优先级排在后面
+	if got := laneOrder(6); !reflect.DeepEqual(got, []string{LaneRejudge, LaneContest, LanePractice}) {
+		t.Fatalf("laneOrder(6) = %v", got)
+	}
+}
+
+func TestUserOrder(t *testing.T) {
+	users := []int64{42, 7, 3, 19}
+	if got := userOrder(users, 7); !reflect.DeepEqual(got, []int64{19, 42, 3, 7}) {
+		t.Fatalf("userOrder(7) = %v", got)
+	}
+	// 上次的用户已没有任务时从下一个更大的用户开始
+	if got := userOrder(users, 20); !reflect.DeepEqual(got, []int64{42, 3, 7, 19}) {
+		t.Fatalf("userOrder(20) = %v", got)
+	}
+	if got := userOrder(users, 42); !reflect.DeepEqual(got, []int64{3, 7, 19, 42}) {
+		t.Fatalf("userOrder(42) = %v", got)
+	}
+}
+
+func TestTaskSubject(t *testing.T) {
+	if got := TaskSubject("heavy", LaneContest, 10); got != "judge.tasks.heavy.contest.u10" {
+		t.Fatalf("got %s", got)
+	}
+	if got := TaskSubject("", "", 3); got != "judge.tasks.light.practice.u3" {
+		t.Fatalf("got %s", got)
+	}
+	if got := ReplaySubject("judge.tasks.light", 9); got != "judge.tasks.light.practice.u9" {
+		t.Fatalf("got %s", got)
+	}
+	if got := ReplaySubject("judge.tasks.light.rejudge", 9); got != "judge.tasks.light.rejudge.u9" {
+		t.Fatalf("got %s", got)
+	}
+	// 按 user_id % 8 分片的旧 subject 换成用户自己的
+	if got := ReplaySubject("judge.tasks.heavy.contest.5", 13); got != "judge.tasks.heavy.contest.u13" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestSubjectLane(t *testing.T) {
+	tests := map[string]string{
+		"judge.tasks.heavy.rejudge.u42": LaneRejudge,
+		"judge.tasks.light.contest.3":   LaneContest,
+		"judge.tasks.light.contest":     LaneContest,
+		"judge.tasks.light":             LanePractice,
+	}
+	for subject, want := range tests {
+		if got := SubjectLane(subject); got != want {
+			t.Errorf("SubjectLane(%s) = %s, want %s", subject, got, want)
+		}
+	}
+}
+
+func TestParseTaskSubject(t *testing.T) {
+	class, lane, user, ok := ParseTaskSubject("judge.tasks.heavy.rejudge.u42")
+	if !ok || class != "heavy" || lane != LaneRejudge || user != 42 {
+		t.Fatalf("got %s %s %d %v", class, lane, user, ok)
+	}
+	if _, _, _, ok := ParseTaskSubject("judge.tasks.light.practice"); ok {
+		t.Fatal("subject without user should not parse")
+	}
+	if _, _, _, ok := ParseTaskSubject("judge.tasks.light.practice.3"); ok {
+		t.Fatal("sharded subject should not parse")
+	}
+}
@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// migrateConsumer 迁移旧 subject 上积压任务时使用的临时 consumer
+	migrateConsumer = "judge_tasks_migrate"
+	// migrateBatch 迁移时每次拉取的消息数
+	migrateBatch = 100
+)
+
+// legacyTaskFilters 旧版本发布评测任务使用的 subject：judge.tasks.<class>（没有通道）、
+// judge.tasks.<class>.<lane>（没有用户）和 judge.tasks.<class>.<lane>.<user_id % 8>（按用户分片）。
+// 当前的 consumer 不消费它们，不迁移会一直积压到过期。
+var legacyTaskFilters = []string{
+	"judge.tasks.*", "judge.tasks.*.*",
+	"judge.tasks.*.*.0", "judge.tasks.*.*.1", "judge.tasks.*.*.2", "judge.tasks.*.*.3",
+	"judge.tasks.*.*.4", "judge.tasks.*.*.5", "judge.tasks.*.*.6", "judge.tasks.*.*.7",
+}
+
+// MigrateLegacyTasks 把旧版本遗留在评测任务 stream 上的 consumer 和任务迁到当前格式，返回迁移的任务数。
+// 先删除 filter subject 不是当前格式的 consumer（judge_tasks_<class>、judge_tasks_<class>_<lane>、
+// judge_tasks_<class>_<lane>_<shard>），它们未确认的消息随之回到 stream；
+// 再逐个消费旧 subject，按任务的用户重新发布到用户在当前通道上的 subject。
+// 旧 consumer 被删除后旧版本 worker 无法继续拉取，升级时应先停掉旧版本 worker。
+// 多个进程同时迁移时共用同一个临时 consumer，每条任务只会被重新发布一次。
+func MigrateLegacyTasks(ctx context.Context, js jetstream.JetStream) (int, error) {
+	if err := deleteLegacyConsumers(ctx, js); err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, filter := range legacyTaskFilters {
+		n, err := migrateSubject(ctx, js, filter)
+		moved += n
+		if err != nil {
+			return moved, fmt.Errorf("failed to migrate %s: %w", filter, err)
+		}
+	}
+	return moved, nil
+}
+
+// legacyConsumer 是否为旧版本创建、需要删除的 consumer
+func legacyConsumer(info *jetstream.ConsumerInfo) bool {
+	if info.Name == migrateConsumer {
+		return false
+	}
+	_, _, _, ok := ParseTaskSubject(info.Config.FilterSubject)
+	return !ok
+}
+
+// deleteLegacyConsumers 删除评测任务 stream 上的旧 consumer
+func deleteLegacyConsumers(ctx context.Context, js jetstream.JetStream) error {
+	stream, err := js.Stream(ctx, taskStream)
+	if err != nil {
+		return fmt.Errorf("failed to get task stream: %w", err)
+	}
+	var legacy []string
+	lister := stream.ListConsumers(ctx)
+	for info := range lister.Info() {
+		if legacyConsumer(info) {
+			legacy = append(legacy, info.Name)
+		}
+	}
+	if err := lister.Err(); err != nil {
+		return fmt.Errorf("failed to list consumers: %w", err)
+	}
+	for _, name := range legacy {
+		if err := js.DeleteConsumer(ctx, taskStream, name); err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+			return fmt.Errorf("failed to delete consumer %s: %w", name, err)
+		}
+		log.Printf("Deleted legacy consumer %s", name)
+	}
+	return nil
+}
+
+// migrateSubject 消费 filter 上的全部任务并重新发布到当前格式的 subject。
+// WorkQueue stream 上同一 subject 只能有一个 consumer，因此须在删除旧 consumer 之后调用。
+func migrateSubject(ctx context.Context, js jetstream.JetStream, filter string) (int, error) {
+	cons, err := js.CreateOrUpdateConsumer(ctx, taskStream, jetstream.ConsumerConfig{
+		Name:          migrateConsumer,
+		Durable:       migrateConsumer,
+		FilterSubject: filter,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       judgeAckWait,
+		// 迁移中途退出时由服务端清理，未确认的消息回到 stream 等下次迁移
+		InactiveThreshold: time.Minute,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer func() {
+		if err := js.DeleteConsumer(context.Background(), taskStream, migrateConsumer); err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+			log.Printf("Failed to delete consumer %s: %v", migrateConsumer, err)
+		}
+	}()
+
+	moved := 0
+	for {
+		batch, err := cons.FetchNoWait(migrateBatch)
+		if err != nil {
+			return moved, err
+		}
+		fetched := 0
+		for msg := range batch.Messages() {
+			fetched++
+			if err := republishTask(ctx, js, msg); err != nil {
+				return moved, err
+			}
+			moved++
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) && !errors.Is(err, jetstream.ErrNoMessages) {
+			return moved, err
+		}
+		if fetched == 0 {
+			return moved, nil
+		}
+	}
+}
+
+// republishTask 把旧 subject 上的一条任务重新发布到用户自己的 subject 并确认原消息，
+// 无法解析的消息直接丢弃
+func republishTask(ctx context.Context, js publisher, msg jetstream.Msg) error {
+	var task JudgeTask
+	if err := json.Unmarshal(msg.Data(), &task); err != nil {
+		log.Printf("Dropping unparsable task on %s: %v", msg.Subject(), err)
+		return msg.Term()
+	}
+	out := nats.NewMsg(ReplaySubject(msg.Subject(), task.User.ID))
+	out.Data = msg.Data()
+	for k, v := range msg.Headers() {
+		out.Header[k] = v
+	}
+	if _, err := js.PublishMsg(ctx, out); err != nil {
+		if nakErr := msg.Nak(); nakErr != nil {
+			return fmt.Errorf("republish: %v, nak: %w", err, nakErr)
+		}
+		return fmt.Errorf("republish: %w", err)
+	}
+	return msg.Ack()
+}
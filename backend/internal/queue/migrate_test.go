@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func legacyMsg(t *testing.T, subject string, userID int64) *fakeMsg {
+	t.Helper()
+	data, err := json.Marshal(&JudgeTask{SubmitID: subject, User: User{ID: userID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeMsg{subject: subject, data: data, header: nats.Header{}, delivered: 1}
+}
+
+func TestMigrateLegacyTasks(t *testing.T) {
+	js := &fakeStream{consumers: map[string]string{
+		"judge_tasks_light":            "judge.tasks.light",
+		"judge_tasks_heavy_contest":    "judge.tasks.heavy.contest",
+		"judge_tasks_light_rejudge_3":  "judge.tasks.light.rejudge.3",
+		"judge_tasks_light_rejudge_u3": "judge.tasks.light.rejudge.u3",
+	}}
+	noLane := legacyMsg(t, "judge.tasks.light", 9)
+	noUser := legacyMsg(t, "judge.tasks.heavy.contest", 10)
+	sharded := legacyMsg(t, "judge.tasks.light.rejudge.3", 11)
+	current := legacyMsg(t, "judge.tasks.light.rejudge.u3", 3)
+	for _, m := range []*fakeMsg{noLane, noUser, sharded, current} {
+		js.push(m)
+	}
+
+	n, err := MigrateLegacyTasks(context.Background(), js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("migrated %d tasks, want 3", n)
+	}
+
+	// 只删除旧 consumer，临时 consumer 用完也删除
+	if _, ok := js.consumers["judge_tasks_light_rejudge_u3"]; !ok || len(js.consumers) != 1 {
+		t.Fatalf("consumers after migration = %v", js.consumers)
+	}
+	var published []string
+	for _, m := range js.published {
+		published = append(published, m.Subject)
+	}
+	sort.Strings(published)
+	want := []string{"judge.tasks.heavy.contest.u10", "judge.tasks.light.practice.u9", "judge.tasks.light.rejudge.u11"}
+	if !reflect.DeepEqual(published, want) {
+		t.Fatalf("published %v, want %v", published, want)
+	}
+	for _, m := range []*fakeMsg{noLane, noUser, sharded} {
+		if acked, _ := m.state(); !acked {
+			t.Errorf("legacy task on %s not acked", m.subject)
+		}
+	}
+	if acked, _ := current.state(); acked {
+		t.Error("task on current subject should not be migrated")
+	}
+}
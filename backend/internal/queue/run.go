@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// RunSubjectPrefix 自定义输入运行请求（request-reply），完整 subject 为 judge.run.{language_slug}。
+// 与评测任务的 JetStream 队列分开，不占用评测并发。
+const RunSubjectPrefix = "judge.run."
+
+// RunRequest 用自定义输入运行代码，不产生提交记录
+type RunRequest struct {
+	RunID       string   `json:"run_id"`
+	UserID      int64    `json:"user_id"`
+	Language    Language `json:"language"`
+	Code        string   `json:"code"`
+	CodeHash    string   `json:"code_hash"`
+	Stdin       string   `json:"stdin"`
+	TimeLimit   int      `json:"time_limit"`   // ms
+	MemoryLimit int      `json:"memory_limit"` // MB
+}
+
+// RunReply 运行结果，Status 为 OK/CE/TLE/MLE/OLE/RE/SE
+type RunReply struct {
+	Status      string `json:"status"`
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr"`
+	Truncated   bool   `json:"truncated,omitempty"` // 输出超过上限被截断
+	ExitCode    int    `json:"exit_code"`
+	TimeMs      int    `json:"time_ms"`
+	MemoryKB    int    `json:"memory_kb"`
+	CompileInfo string `json:"compile_info,omitempty"`
+	Error       string `json:"error,omitempty"` // worker 自身故障
+}
+
+// RequestRun 请求运行代码并等待结果。请求发往 judge.run.{language_slug}，
+// 只有探测到该语言工具链的 worker 订阅了它；没有这样的 worker 时返回 nats.ErrNoResponders。
+func RequestRun(nc *nats.Conn, req *RunRequest, timeout time.Duration) (*RunReply, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := nc.Request(RunSubjectPrefix+req.Language.Slug, data, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("run request: %w", err)
+	}
+
+	var reply RunReply
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		return nil, fmt.Errorf("invalid run reply: %w", err)
+	}
+	if reply.Error != "" {
+		return nil, fmt.Errorf("run: %s", reply.Error)
+	}
+	return &reply, nil
+}
+
+// ServeRun worker 端处理 slugs 中各语言的运行请求，最多同时处理 concurrency 个，
+// 同一语言的多个 worker 之间负载均衡
+func ServeRun(nc *nats.Conn, slugs []string, concurrency int, handler func(*RunRequest) *RunReply) ([]*nats.Subscription, error) {
+	return serveRun(nc, slugs, concurrency, handler)
+}
+
+func serveRun(nc subscriber, slugs []string, concurrency int, handler func(*RunRequest) *RunReply) ([]*nats.Subscription, error) {
+	sem := make(chan struct{}, max(concurrency, 1))
+	return serveLanguages(nc, RunSubjectPrefix, slugs, func(msg *nats.Msg) {
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+
+			var req RunRequest
+			reply := &RunReply{}
+			if err := json.Unmarshal(msg.Data, &req); err != nil {
+				reply.Error = "invalid request: " + err.Error()
+			} else {
+				reply = handler(&req)
+			}
+
+			data, err := json.Marshal(reply)
+			if err != nil {
+				log.Printf("Failed to marshal run reply: %v", err)
+				return
+			}
+			if err := msg.Respond(data); err != nil {
+				log.Printf("Failed to respond run request: %v", err)
+			}
+		}()
+	})
+}
+
+// subscriber 注册 request-reply 处理函数，*nats.Conn 实现了它
+type subscriber interface {
+	QueueSubscribe(subj, queue string, cb nats.MsgHandler) (*nats.Subscription, error)
+}
+
+// serveLanguages 为每个语言订阅 prefix+slug，worker 只会收到自己能处理的语言的请求
+func serveLanguages(nc subscriber, prefix string, slugs []string, cb nats.MsgHandler) ([]*nats.Subscription, error) {
+	subs := make([]*nats.Subscription, 0, len(slugs))
+	for _, slug := range slugs {
+		sub, err := nc.QueueSubscribe(prefix+slug, workerQueueGroup, cb)
+		if err != nil {
+			for _, s := range subs {
+				s.Unsubscribe()
+			}
+			return nil, fmt.Errorf("subscribe %s%s: %w", prefix, slug, err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
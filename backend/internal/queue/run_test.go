@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// fakeBus 按 NATS 的 subject 通配规则把消息投递给订阅者
+type fakeBus struct {
+	mu   sync.Mutex
+	subs map[string]nats.MsgHandler
+}
+
+func (b *fakeBus) QueueSubscribe(subj, queue string, cb nats.MsgHandler) (*nats.Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[string]nats.MsgHandler)
+	}
+	b.subs[subj] = cb
+	return nil, nil
+}
+
+// publish 返回收到消息的订阅数
+func (b *fakeBus) publish(subject string, data []byte) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for pattern, cb := range b.subs {
+		if subjectMatches(pattern, subject) {
+			cb(&nats.Msg{Subject: subject, Data: data})
+			n++
+		}
+	}
+	return n
+}
+
+func subjectMatches(pattern, subject string) bool {
+	p, s := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, tok := range p {
+		if tok == ">" {
+			return len(s) > i
+		}
+		if i >= len(s) || (tok != "*" && tok != s[i]) {
+			return false
+		}
+	}
+	return len(p) == len(s)
+}
+
+func TestServeRunOnlySupportedLanguages(t *testing.T) {
+	bus := &fakeBus{}
+	handled := make(chan string, 4)
+	if _, err := serveRun(bus, []string{"cpp", "python3"}, 2, func(req *RunRequest) *RunReply {
+		handled <- req.Language.Slug
+		return &RunReply{Status: "OK"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, slug := range []string{"java", "cpp", "rust", "python3"} {
+		data, _ := json.Marshal(&RunRequest{Language: Language{Slug: slug}})
+		got := bus.publish(RunSubjectPrefix+slug, data)
+		want := 0
+		if slug == "cpp" || slug == "python3" {
+			want = 1
+		}
+		if got != want {
+			t.Errorf("%s delivered to %d subscriptions, want %d", slug, got, want)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case slug := <-handled:
+			seen[slug] = true
+		case <-time.After(time.Second):
+			t.Fatal("run request not handled")
+		}
+	}
+	select {
+	case slug := <-handled:
+		t.Fatalf("unexpected run request for %s", slug)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !seen["cpp"] || !seen["python3"] {
+		t.Fatalf("handled %v", seen)
+	}
+}
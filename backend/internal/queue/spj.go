@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// SPJCompileSubjectPrefix 题目保存时请求 worker 试编译 SPJ（request-reply），
+	// 完整 subject 为 judge.spj.compile.{language_slug}
+	SPJCompileSubjectPrefix = "judge.spj.compile."
+
+	workerQueueGroup = "judge-workers"
+)
+
+// SPJ 出题人提供的评测程序：特判或交互器
+type SPJ struct {
+	Language Language `json:"language"`
+	Code     string   `json:"code"`
+}
+
+// SPJCompileRequest SPJ 试编译请求
+type SPJCompileRequest struct {
+	ProblemID int64 `json:"problem_id"`
+	SPJ       SPJ   `json:"spj"`
+}
+
+// SPJCompileReply SPJ 试编译结果
+type SPJCompileReply struct {
+	Success     bool   `json:"success"`
+	CompileInfo string `json:"compile_info"`
+	Error       string `json:"error,omitempty"` // worker 自身故障
+}
+
+// RequestSPJCompile 请求支持 SPJ 语言的任一 worker 编译 SPJ 并等待结果，
+// 没有这样的 worker 时返回 nats.ErrNoResponders
+func RequestSPJCompile(nc *nats.Conn, req *SPJCompileRequest, timeout time.Duration) (*SPJCompileReply, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := nc.Request(SPJCompileSubjectPrefix+req.SPJ.Language.Slug, data, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("spj compile request: %w", err)
+	}
+
+	var reply SPJCompileReply
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		return nil, fmt.Errorf("invalid spj compile reply: %w", err)
+	}
+	if reply.Error != "" {
+		return nil, fmt.Errorf("spj compile: %s", reply.Error)
+	}
+	return &reply, nil
+}
+
+// ServeSPJCompile worker 端处理 slugs 中各语言的 SPJ 试编译请求，同一语言的多个 worker 之间负载均衡
+func ServeSPJCompile(nc *nats.Conn, slugs []string, handler func(*SPJCompileRequest) *SPJCompileReply) ([]*nats.Subscription, error) {
+	return serveLanguages(nc, SPJCompileSubjectPrefix, slugs, func(msg *nats.Msg) {
+		var req SPJCompileRequest
+		reply := &SPJCompileReply{}
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			reply.Error = "invalid request: " + err.Error()
+		} else {
+			reply = handler(&req)
+		}
+
+		data, err := json.Marshal(reply)
+		if err != nil {
+			log.Printf("Failed to marshal spj compile reply: %v", err)
+			return
+		}
+		if err := msg.Respond(data); err != nil {
+			log.Printf("Failed to respond spj compile request: %v", err)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ValidateSubjectPrefix 请求 worker 用校验器检查题目全部输入文件（request-reply），
+// 完整 subject 为 judge.testdata.validate.{校验器 language_slug}
+const ValidateSubjectPrefix = "judge.testdata.validate."
+
+// ValidateRequest 测试数据校验请求，Problem 需带 Validator 和测试数据位置
+type ValidateRequest struct {
+	Problem Problem `json:"problem"`
+}
+
+// FileValidation 单个输入文件的校验结果
+type FileValidation struct {
+	File    string `json:"file"`
+	Valid   bool   `json:"valid"`
+	Message string `json:"message,omitempty"`
+}
+
+// ValidateReply 测试数据校验结果
+type ValidateReply struct {
+	Files       []FileValidation `json:"files"`
+	CompileInfo string           `json:"compile_info,omitempty"` // 校验器编译失败时的信息
+	Error       string           `json:"error,omitempty"`        // worker 自身故障
+}
+
+// RequestValidate 请求支持校验器语言的任一 worker 校验测试数据并等待结果，
+// 没有这样的 worker 时返回 nats.ErrNoResponders
+func RequestValidate(nc *nats.Conn, req *ValidateRequest, timeout time.Duration) (*ValidateReply, error) {
+	if req.Problem.Validator == nil {
+		return nil, fmt.Errorf("problem %d has no validator", req.Problem.ID)
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := nc.Request(ValidateSubjectPrefix+req.Problem.Validator.Language.Slug, data, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("validate request: %w", err)
+	}
+
+	var reply ValidateReply
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		return nil, fmt.Errorf("invalid validate reply: %w", err)
+	}
+	if reply.Error != "" {
+		return nil, fmt.Errorf("validate: %s", reply.Error)
+	}
+	return &reply, nil
+}
+
+// ServeValidate worker 端处理 slugs 中各语言校验器的测试数据校验请求，同一语言的多个 worker 之间负载均衡
+func ServeValidate(nc *nats.Conn, slugs []string, handler func(*ValidateRequest) *ValidateReply) ([]*nats.Subscription, error) {
+	return serveLanguages(nc, ValidateSubjectPrefix, slugs, func(msg *nats.Msg) {
+		var req ValidateRequest
+		reply := &ValidateReply{}
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			reply.Error = "invalid request: " + err.Error()
+		} else {
+			reply = handler(&req)
+		}
+
+		data, err := json.Marshal(reply)
+		if err != nil {
+			log.Printf("Failed to marshal validate reply: %v", err)
+			return
+		}
+		if err := msg.Respond(data); err != nil {
+			log.Printf("Failed to respond validate request: %v", err)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// workerBucket worker 心跳所在的 KV bucket，key 为 worker ID
+	workerBucket = "OJ_WORKERS"
+
+	// HeartbeatInterval worker 上报心跳的间隔
+	HeartbeatInterval = 10 * time.Second
+	// HeartbeatStaleAfter 超过该时间没有心跳的 worker 视为失联
+	HeartbeatStaleAfter = 3 * HeartbeatInterval
+	// 失联的 worker 保留一段时间供排查，之后由 KV 自动过期
+	workerTTL = 10 * time.Minute
+)
+
+// ErrWorkerNotFound worker 不存在或心跳已过期
+var ErrWorkerNotFound = errors.New("worker not found")
+
+// WorkerSlot worker 的一个评测槽位，空闲时 SubmitID 为空
+type WorkerSlot struct {
+	Index     int        `json:"index"`
+	SubmitID  string     `json:"submit_id,omitempty"`
+	ProblemID int64      `json:"problem_id,omitempty"`
+	Language  string     `json:"language,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// WorkerInfo worker 心跳内容
+type WorkerInfo struct {
+	ID           string            `json:"id"`
+	Hostname     string            `json:"hostname"`
+	Consumer     string            `json:"consumer"`
+	QueueClass   string            `json:"queue_class"`
+	Capabilities []string          `json:"capabilities"`
+	Concurrency  int               `json:"concurrency"`
+	Busy         int               `json:"busy"`
+	Slots        []WorkerSlot      `json:"slots"`
+	Languages    []string          `json:"languages"`
+	Toolchains   map[string]string `json:"toolchains"` // 语言 slug -> 编译器/解释器版本
+	StartedAt    time.Time         `json:"started_at"`
+	UptimeSec    int64             `json:"uptime_sec"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// Stale 是否已超过 HeartbeatStaleAfter 没有心跳
+func (w *WorkerInfo) Stale(now time.Time) bool {
+	return now.Sub(w.UpdatedAt) > HeartbeatStaleAfter
+}
+
+// invalidKeyChars KV key 只允许这些字符
+var invalidKeyChars = regexp.MustCompile(`[^-_=a-zA-Z0-9]`)
+
+// workerKey worker ID 转成合法的 KV key
+func workerKey(id string) string {
+	return invalidKeyChars.ReplaceAllString(id, "_")
+}
+
+// WorkerBucket 获取 worker 心跳 bucket，不存在时创建
+func WorkerBucket(ctx context.Context, js jetstream.JetStream) (jetstream.KeyValue, error) {
+	kv, err := js.KeyValue(ctx, workerBucket)
+	if err == nil {
+		return kv, nil
+	}
+	if !errors.Is(err, jetstream.ErrBucketNotFound) {
+		return nil, fmt.Errorf("failed to get worker bucket: %w", err)
+	}
+	kv, err = js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:  workerBucket,
+		TTL:     workerTTL,
+		Storage: jetstream.MemoryStorage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worker bucket: %w", err)
+	}
+	return kv, nil
+}
+
+// PutHeartbeat 上报心跳
+func PutHeartbeat(ctx context.Context, kv jetstream.KeyValue, info *WorkerInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	_, err = kv.Put(ctx, workerKey(info.ID), data)
+	return err
+}
+
+// GetWorker 获取单个 worker 的最近一次心跳
+func GetWorker(ctx context.Context, kv jetstream.KeyValue, id string) (*WorkerInfo, error) {
+	entry, err := kv.Get(ctx, workerKey(id))
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return nil, ErrWorkerNotFound
+		}
+		return nil, err
+	}
+	var info WorkerInfo
+	if err := json.Unmarshal(entry.Value(), &info); err != nil {
+		return nil, fmt.Errorf("invalid heartbeat for worker %s: %w", id, err)
+	}
+	return &info, nil
+}
+
+// ListWorkers 列出所有未过期的 worker，按 ID 排序
+func ListWorkers(ctx context.Context, kv jetstream.KeyValue) ([]WorkerInfo, error) {
+	keys, err := kv.Keys(ctx)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoKeysFound) {
+			return []WorkerInfo{}, nil
+		}
+		return nil, err
+	}
+	workers := make([]WorkerInfo, 0, len(keys))
+	for _, key := range keys {
+		info, err := GetWorker(ctx, kv, key)
+		if errors.Is(err, ErrWorkerNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		workers = append(workers, *info)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers, nil
+}
+
+// RemoveWorker 删除 worker 的心跳记录
+func RemoveWorker(ctx context.Context, kv jetstream.KeyValue, id string) error {
+	if _, err := GetWorker(ctx, kv, id); err != nil {
+		return err
+	}
+	return kv.Delete(ctx, workerKey(id))
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"github.com/oj/oj-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// 死信操作
+const (
+	DeadLetterReplay = "REPLAY"
+	DeadLetterDelete = "DELETE"
+	DeadLetterPurge  = "PURGE"
+)
+
+type DeadLetterAuditRepo struct {
+	db *gorm.DB
+}
+
+func NewDeadLetterAuditRepo(db *gorm.DB) *DeadLetterAuditRepo {
+	return &DeadLetterAuditRepo{db: db}
+}
+
+func (r *DeadLetterAuditRepo) Create(audit *model.DeadLetterAudit) error {
+	return r.db.Create(audit).Error
+}
+
+// List 从新到旧列出操作记录，submitID 非空时只看该提交
+func (r *DeadLetterAuditRepo) List(submitID string, page, pageSize int) ([]model.DeadLetterAudit, int64, error) {
+	var audits []model.DeadLetterAudit
+	var total int64
+
+	query := r.db.Model(&model.DeadLetterAudit{})
+	if submitID != "" {
+		query = query.Where("submit_id = ?", submitID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&audits).Error
+	return audits, total, err
+}
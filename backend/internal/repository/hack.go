@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// hack 生命周期状态
+const (
+	HackStatusPending = "PENDING"
+	HackStatusDone    = "DONE"
+)
+
+type HackRepo struct {
+	db *gorm.DB
+}
+
+func NewHackRepo(db *gorm.DB) *HackRepo {
+	return &HackRepo{db: db}
+}
+
+func (r *HackRepo) Create(hack *model.Hack) error {
+	return r.db.Create(hack).Error
+}
+
+func (r *HackRepo) GetByID(id int64) (*model.Hack, error) {
+	var hack model.Hack
+	err := r.db.First(&hack, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &hack, nil
+}
+
+type ListHackParams struct {
+	ContestID    int64
+	HackerID     int64
+	TargetUserID int64
+	Page         int
+	PageSize     int
+}
+
+// List 按比赛列出 hack，不带输入和答案
+func (r *HackRepo) List(params ListHackParams) ([]model.Hack, int64, error) {
+	var hacks []model.Hack
+	var total int64
+
+	query := r.db.Model(&model.Hack{}).Where("contest_id = ?", params.ContestID)
+	if params.HackerID > 0 {
+		query = query.Where("hacker_id = ?", params.HackerID)
+	}
+	if params.TargetUserID > 0 {
+		query = query.Where("target_user_id = ?", params.TargetUserID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (params.Page - 1) * params.PageSize
+	err := query.Omit("input", "answer").
+		Order("id DESC").
+		Offset(offset).Limit(params.PageSize).
+		Find(&hacks).Error
+	return hacks, total, err
+}
+
+// MarkAddedToTests 标记 hack 输入已加入系统测试
+func (r *HackRepo) MarkAddedToTests(id int64) error {
+	return r.db.Model(&model.Hack{}).Where("id = ?", id).Update("added_to_tests", true).Error
+}
+
+// SaveResult 保存 hack 结果。成功的 hack 把目标提交改判为对应结论并清零得分，
+// hacker 的比赛得分按 Points 调整，目标选手扣除该题失去的得分后重新排名；重复投递的结果只生效一次。
+// 目标提交行加锁，并发的多个成功 hack 只有第一个得分。
+func (r *HackRepo) SaveResult(result *queue.HackResult) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var hack model.Hack
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hack, result.HackID).Error
+		if err != nil {
+			return err
+		}
+		if hack.Status == HackStatusDone {
+			return nil
+		}
+
+		points, message := result.Points, result.Message
+		rerank := false
+		if result.Verdict == queue.HackSuccess {
+			var target model.Submission
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("submit_id = ?", hack.TargetSubmitID).First(&target).Error
+			if err != nil {
+				return err
+			}
+			hackedBy := targetHackedBy(&target)
+			points, message = hackAward(result, hackedBy)
+			if hackedBy == 0 {
+				lost, err := r.hackTarget(tx, &hack, &target, result.TargetVerdict)
+				if err != nil {
+					return err
+				}
+				rerank = lost > 0
+			}
+		}
+
+		err = tx.Model(&hack).Updates(map[string]interface{}{
+			"status":         HackStatusDone,
+			"verdict":        result.Verdict,
+			"target_verdict": result.TargetVerdict,
+			"message":        message,
+			"answer":         result.Answer,
+			"points":         points,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if points != 0 {
+			err = tx.Model(&model.ContestParticipant{}).
+				Where("contest_id = ? AND user_id = ?", hack.ContestID, hack.HackerID).
+				Update("score", gorm.Expr("score + ?", points)).Error
+			if err != nil {
+				return err
+			}
+			rerank = true
+		}
+		if rerank {
+			return rerankContest(tx, hack.ContestID)
+		}
+		return nil
+	})
+}
+
+// hackAward 成功 hack 的实际得分和说明：目标已被其他 hack 攻破时不再得分
+func hackAward(result *queue.HackResult, hackedBy int64) (int, string) {
+	if result.Verdict != queue.HackSuccess || hackedBy == 0 {
+		return result.Points, result.Message
+	}
+	return 0, fmt.Sprintf("target already hacked by hack #%d", hackedBy)
+}
+
+// targetHackedBy 目标提交已被攻破时返回对应的 hack ID
+func targetHackedBy(sub *model.Submission) int64 {
+	var jr queue.JudgeResult
+	if sub.JudgeResult == "" || json.Unmarshal([]byte(sub.JudgeResult), &jr) != nil {
+		return 0
+	}
+	return jr.HackedBy
+}
+
+// hackTarget 改判目标提交，并从目标选手的比赛得分中扣除该题因此失去的分数，返回扣除的分数。
+// 题目得分取选手在该题所有提交中的最高分，另有同样高分的提交时不扣分。
+func (r *HackRepo) hackTarget(tx *gorm.DB, hack *model.Hack, target *model.Submission, verdict string) (int, error) {
+	before, err := bestContestScore(tx, hack.ContestID, target.UserID, target.ProblemID)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Model(&model.Submission{}).
+		Where("id = ?", target.ID).
+		Update("judge_result", gorm.Expr(
+			`judge_result || jsonb_build_object('verdict', ?::text, 'score', 0, 'hacked_by', ?::bigint)`,
+			verdict, hack.ID,
+		)).Error
+	if err != nil {
+		return 0, err
+	}
+	after, err := bestContestScore(tx, hack.ContestID, target.UserID, target.ProblemID)
+	if err != nil {
+		return 0, err
+	}
+
+	lost := before - after
+	if lost <= 0 {
+		return 0, nil
+	}
+	err = tx.Model(&model.ContestParticipant{}).
+		Where("contest_id = ? AND user_id = ?", hack.ContestID, target.UserID).
+		Update("score", gorm.Expr("score - ?", lost)).Error
+	return lost, err
+}
+
+// bestContestScore 选手在比赛中某题已完成评测的提交的最高分
+func bestContestScore(tx *gorm.DB, contestID, userID, problemID int64) (int, error) {
+	var best int
+	err := tx.Model(&model.Submission{}).
+		Where("contest_id = ? AND user_id = ? AND problem_id = ?", contestID, userID, problemID).
+		Where("judge_result->>'status' = ?", queue.StatusDone).
+		Select("COALESCE(MAX((judge_result->>'score')::int), 0)").
+		Scan(&best).Error
+	return best, err
+}
+
+// rerankContest 按得分降序、罚时升序重新计算比赛排名
+func rerankContest(tx *gorm.DB, contestID int64) error {
+	return tx.Exec(`
+		UPDATE contest_participants p SET rank = r.rank
+		FROM (
+			SELECT id, RANK() OVER (ORDER BY score DESC, penalty ASC) AS rank
+			FROM contest_participants
+			WHERE contest_id = ? AND deleted_at IS NULL
+		) r
+		WHERE p.id = r.id`, contestID).Error
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestHackAward(t *testing.T) {
+	success := &queue.HackResult{Verdict: queue.HackSuccess, Points: 100, Message: "wrong answer"}
+	if points, msg := hackAward(success, 0); points != 100 || msg != "wrong answer" {
+		t.Errorf("first hack = (%d, %q), want full points", points, msg)
+	}
+	if points, msg := hackAward(success, 7); points != 0 || msg != "target already hacked by hack #7" {
+		t.Errorf("second hack = (%d, %q), want no points", points, msg)
+	}
+	failed := &queue.HackResult{Verdict: queue.HackFailed, Points: -50}
+	if points, _ := hackAward(failed, 7); points != -50 {
+		t.Errorf("failed hack points = %d, want -50", points)
+	}
+}
+
+// testDB 连接 TEST_DATABASE_URL 指定的 PostgreSQL，在独立 schema 中建表，未配置时跳过
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	cfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("oj_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	db, err := gorm.Open(postgres.Open(dsn+sep+"search_path="+schema), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Submission{}, &model.Hack{}, &model.ContestParticipant{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSaveResultDoubleHack(t *testing.T) {
+	db := testDB(t)
+	repo := NewHackRepo(db)
+
+	const contestID, target, hackerA, hackerB = 1, 10, 20, 30
+	cid := int64(contestID)
+	for _, p := range []model.ContestParticipant{
+		{ContestID: contestID, UserID: target, Score: 100},
+		{ContestID: contestID, UserID: hackerA},
+		{ContestID: contestID, UserID: hackerB},
+	} {
+		if err := db.Create(&p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	sub := model.Submission{
+		SubmitID:       "target",
+		UserID:         target,
+		ProblemID:      1,
+		ContestID:      &cid,
+		IdempotencyKey: "target",
+		JudgeResult:    `{"status":"DONE","verdict":"AC","score":100}`,
+	}
+	if err := db.Create(&sub).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	hacks := []model.Hack{
+		{ContestID: contestID, ProblemID: 1, HackerID: hackerA, TargetSubmitID: "target", TargetUserID: target},
+		{ContestID: contestID, ProblemID: 1, HackerID: hackerB, TargetSubmitID: "target", TargetUserID: target},
+	}
+	for i := range hacks {
+		if err := repo.Create(&hacks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 两个 hack 同时成功，只有先提交事务的一个得分
+	var wg sync.WaitGroup
+	errs := make([]error, len(hacks))
+	for i, h := range hacks {
+		wg.Add(1)
+		go func(i int, id int64) {
+			defer wg.Done()
+			errs[i] = repo.SaveResult(&queue.HackResult{
+				HackID:        id,
+				Verdict:       queue.HackSuccess,
+				TargetVerdict: queue.VerdictWA,
+				Points:        100,
+			})
+		}(i, h.ID)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var saved []model.Hack
+	if err := db.Order("id").Find(&saved).Error; err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, h := range saved {
+		if h.Status != HackStatusDone {
+			t.Errorf("hack %d status = %s, want DONE", h.ID, h.Status)
+		}
+		total += h.Points
+	}
+	if total != 100 {
+		t.Errorf("total hack points = %d, want 100", total)
+	}
+
+	scores := map[int64]int{}
+	var participants []model.ContestParticipant
+	if err := db.Where("contest_id = ?", contestID).Find(&participants).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range participants {
+		scores[p.UserID] = p.Score
+	}
+	if scores[target] != 0 {
+		t.Errorf("target score = %d, want 0", scores[target])
+	}
+	if scores[hackerA]+scores[hackerB] != 100 {
+		t.Errorf("hacker scores = %d + %d, want 100 in total", scores[hackerA], scores[hackerB])
+	}
+	for _, p := range participants {
+		if p.Score == 100 && p.Rank != 1 {
+			t.Errorf("winning hacker rank = %d, want 1", p.Rank)
+		}
+	}
+
+	// 重复投递不再改变结果
+	if err := repo.SaveResult(&queue.HackResult{HackID: hacks[0].ID, Verdict: queue.HackSuccess, Points: 100}); err != nil {
+		t.Fatal(err)
+	}
+	var after model.ContestParticipant
+	db.Where("contest_id = ? AND user_id = ?", contestID, target).First(&after)
+	if after.Score != 0 {
+		t.Errorf("target score after redelivery = %d, want 0", after.Score)
+	}
+}
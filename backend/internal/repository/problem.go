@@ -37,8 +37,49 @@ func (r *ProblemRepo) GetBySlug(slug string) (*model.Problem, error) {
 	return &problem, nil
 }
 
+// Update 保存题目。test_data_check 由后台校验单独写入，不随表单覆盖
 func (r *ProblemRepo) Update(problem *model.Problem) error {
-	return r.db.Save(problem).Error
+	return r.db.Omit("test_data_check").Save(problem).Error
+}
+
+// UpdateSPJCompileOut 写回 SPJ 试编译信息，编译通过时为空
+func (r *ProblemRepo) UpdateSPJCompileOut(id int64, out string) error {
+	return r.db.Model(&model.Problem{}).Where("id = ?", id).
+		UpdateColumn("spj_compile_out", out).Error
+}
+
+// UpdateTestData 更新测试数据压缩包的位置和哈希
+func (r *ProblemRepo) UpdateTestData(id int64, location, hash string) error {
+	return r.db.Model(&model.Problem{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"test_data_zip":  location,
+		"test_data_hash": hash,
+	}).Error
+}
+
+// UpdatePublic 单独更新题目是否公开，测试数据变化待校验时撤下、校验通过后恢复
+func (r *ProblemRepo) UpdatePublic(id int64, public bool) error {
+	return r.db.Model(&model.Problem{}).Where("id = ?", id).
+		UpdateColumn("is_public", public).Error
+}
+
+// UpdateTestDataCheck 写回测试数据校验结果
+func (r *ProblemRepo) UpdateTestDataCheck(id int64, check string) error {
+	return r.db.Model(&model.Problem{}).Where("id = ?", id).
+		UpdateColumn("test_data_check", check).Error
+}
+
+// SwapTestDataCheck 仅当校验结果仍为 old 时写入 next，返回是否写入。
+// 后台校验据此放弃已被更新的校验任务的结果。
+func (r *ProblemRepo) SwapTestDataCheck(id int64, old, next string) (bool, error) {
+	res := r.db.Model(&model.Problem{}).Where("id = ? AND test_data_check = ?", id, old).
+		UpdateColumn("test_data_check", next)
+	return res.RowsAffected > 0, res.Error
+}
+
+// UpdateTestDataGen 写回测试数据生成结果
+func (r *ProblemRepo) UpdateTestDataGen(id int64, gen string) error {
+	return r.db.Model(&model.Problem{}).Where("id = ?", id).
+		UpdateColumn("test_data_gen", gen).Error
 }
 
 func (r *ProblemRepo) Delete(id int64) error {
@@ -4,20 +4,26 @@ import "gorm.io/gorm"
 
 // Repositories 所有 Repository 的集合
 type Repositories struct {
-	User    *UserRepo
-	Problem *ProblemRepo
-	Submit  *SubmitRepo
-	Contest *ContestRepo
-	Lang    *LanguageRepo
+	User     *UserRepo
+	Problem  *ProblemRepo
+	Submit   *SubmitRepo
+	Contest  *ContestRepo
+	Lang     *LanguageRepo
+	Hack     *HackRepo
+	Solution *SolutionRepo
+	DLQAudit *DeadLetterAuditRepo
 }
 
 // NewRepositories 创建 Repository 集合
 func NewRepositories(db *gorm.DB) *Repositories {
 	return &Repositories{
-		User:    NewUserRepo(db),
-		Problem: NewProblemRepo(db),
-		Submit:  NewSubmitRepo(db),
-		Contest: NewContestRepo(db),
-		Lang:    NewLanguageRepo(db),
+		User:     NewUserRepo(db),
+		Problem:  NewProblemRepo(db),
+		Submit:   NewSubmitRepo(db),
+		Contest:  NewContestRepo(db),
+		Lang:     NewLanguageRepo(db),
+		Hack:     NewHackRepo(db),
+		Solution: NewSolutionRepo(db),
+		DLQAudit: NewDeadLetterAuditRepo(db),
 	}
 }
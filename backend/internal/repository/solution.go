@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"encoding/json"
+
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+	"gorm.io/gorm"
+)
+
+// SolutionRepo 参考解和参考解运行记录
+type SolutionRepo struct {
+	db *gorm.DB
+}
+
+func NewSolutionRepo(db *gorm.DB) *SolutionRepo {
+	return &SolutionRepo{db: db}
+}
+
+func (r *SolutionRepo) Create(solution *model.ProblemSolution) error {
+	return r.db.Create(solution).Error
+}
+
+func (r *SolutionRepo) GetByID(id int64) (*model.ProblemSolution, error) {
+	var solution model.ProblemSolution
+	err := r.db.First(&solution, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &solution, nil
+}
+
+func (r *SolutionRepo) ListByProblem(problemID int64) ([]model.ProblemSolution, error) {
+	var solutions []model.ProblemSolution
+	err := r.db.Where("problem_id = ?", problemID).Order("id ASC").Find(&solutions).Error
+	return solutions, err
+}
+
+func (r *SolutionRepo) Delete(id int64) error {
+	return r.db.Delete(&model.ProblemSolution{}, id).Error
+}
+
+func (r *SolutionRepo) CreateInvocation(invocation *model.ProblemInvocation) error {
+	return r.db.Create(invocation).Error
+}
+
+func (r *SolutionRepo) GetInvocation(id int64) (*model.ProblemInvocation, error) {
+	var invocation model.ProblemInvocation
+	err := r.db.First(&invocation, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invocation, nil
+}
+
+// ListInvocations 列出题目最近的运行记录，不带结果矩阵
+func (r *SolutionRepo) ListInvocations(problemID int64, limit int) ([]model.ProblemInvocation, error) {
+	var invocations []model.ProblemInvocation
+	err := r.db.Where("problem_id = ?", problemID).
+		Omit("result").
+		Order("id DESC").
+		Limit(limit).
+		Find(&invocations).Error
+	return invocations, err
+}
+
+// SaveInvocationResult 写回参考解运行结果
+func (r *SolutionRepo) SaveInvocationResult(result *queue.InvokeResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return r.db.Model(&model.ProblemInvocation{}).
+		Where("id = ?", result.InvocationID).
+		Updates(map[string]interface{}{
+			"status":               result.Status,
+			"result":               string(data),
+			"mismatches":           result.Mismatches,
+			"suggested_time_limit": result.SuggestedTimeLimit,
+		}).Error
+}
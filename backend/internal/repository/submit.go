@@ -43,24 +43,69 @@ func (r *SubmitRepo) Update(submission *model.Submission) error {
 	return r.db.Save(submission).Error
 }
 
+// UpdateStatus 更新评测生命周期状态（COMPILING/RUNNING），已出结果的提交不会被改回
 func (r *SubmitRepo) UpdateStatus(submitID, status, workerID string, startTime time.Time) error {
+	statusJSON, err := json.Marshal(map[string]string{"status": status})
+	if err != nil {
+		return err
+	}
 	updates := map[string]interface{}{
-		"judge_result": map[string]interface{}{
-			"status":    status,
-			"worker_id": workerID,
-		},
+		"judge_result": string(statusJSON),
 	}
 
-	if status == "RUNNING" {
+	if status == queue.StatusCompiling {
 		updates["worker_id"] = workerID
 		updates["start_time"] = startTime
 	}
 
 	return r.db.Model(&model.Submission{}).
-		Where("submit_id = ? AND (judge_result->>'status' IN ('PENDING', 'RUNNING') OR judge_result->>'status' IS NULL)", submitID).
+		Where("submit_id = ? AND (judge_result->>'status' IN (?) OR judge_result->>'status' IS NULL)", submitID,
+			[]string{queue.StatusPending, queue.StatusCompiling, queue.StatusRunning}).
 		Updates(updates).Error
 }
 
+// UpdateRetryCount 记录本次评测是第几次重试
+func (r *SubmitRepo) UpdateRetryCount(submitID string, count int) error {
+	return r.db.Model(&model.Submission{}).
+		Where("submit_id = ?", submitID).
+		Update("retry_count", count).Error
+}
+
+// RecordFailure 追加一条评测失败记录
+func (r *SubmitRepo) RecordFailure(submitID string, taskErr queue.TaskError) error {
+	submission, err := r.GetBySubmitID(submitID)
+	if err != nil {
+		return err
+	}
+	var errs []queue.TaskError
+	if submission.JudgeErrors != "" {
+		if err := json.Unmarshal([]byte(submission.JudgeErrors), &errs); err != nil {
+			errs = nil
+		}
+	}
+	data, err := json.Marshal(append(errs, taskErr))
+	if err != nil {
+		return err
+	}
+	return r.db.Model(&model.Submission{}).
+		Where("submit_id = ?", submitID).
+		Update("judge_errors", string(data)).Error
+}
+
+// ResetPending 把提交改回 PENDING，重新入队评测前调用
+func (r *SubmitRepo) ResetPending(submitID string) error {
+	statusJSON, err := json.Marshal(map[string]string{"status": queue.StatusPending})
+	if err != nil {
+		return err
+	}
+	return r.db.Model(&model.Submission{}).
+		Where("submit_id = ?", submitID).
+		Updates(map[string]interface{}{
+			"judge_result": string(statusJSON),
+			"finish_time":  nil,
+		}).Error
+}
+
 func (r *SubmitRepo) UpdateResult(submitID string, result *queue.JudgeResult) error {
 	resultJSON, _ := json.Marshal(result)
 
@@ -68,9 +113,15 @@ func (r *SubmitRepo) UpdateResult(submitID string, result *queue.JudgeResult) er
 		"judge_result": string(resultJSON),
 		"finish_time":  time.Now(),
 	}
+	if result.CompileInfo != "" {
+		updates["compile_info"] = result.CompileInfo
+	}
+	if result.CompileLogURL != "" {
+		updates["compile_log_url"] = result.CompileLogURL
+	}
 
 	// 只有在成功时才更新排名
-	if result.Status == "FINISHED" && result.Score > 0 {
+	if result.Status == queue.StatusDone && result.Score > 0 {
 		// Contest rank update handled elsewhere
 	}
 
@@ -79,7 +130,19 @@ func (r *SubmitRepo) UpdateResult(submitID string, result *queue.JudgeResult) er
 		Updates(updates).Error
 }
 
-func (r *SubmitRepo) ListByUser(userID int64, problemID *int64, status *string, page, pageSize int) ([]model.Submission, int64, error) {
+// HasAccepted 用户在比赛中是否已通过该题（不含样例评测）
+func (r *SubmitRepo) HasAccepted(userID, contestID, problemID int64) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Submission{}).
+		Where("user_id = ? AND contest_id = ? AND problem_id = ? AND is_sample = ?", userID, contestID, problemID, false).
+		Where("judge_result->>'verdict' = ?", queue.VerdictAC).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListByUser 按用户列出提交，status 过滤生命周期状态，verdict 过滤最终结论；
+// 样例评测默认不列出
+func (r *SubmitRepo) ListByUser(userID int64, problemID *int64, status, verdict *string, includeSample bool, page, pageSize int) ([]model.Submission, int64, error) {
 	var submissions []model.Submission
 	var total int64
 
@@ -88,9 +151,15 @@ func (r *SubmitRepo) ListByUser(userID int64, problemID *int64, status *string,
 	if problemID != nil {
 		query = query.Where("problem_id = ?", *problemID)
 	}
+	if !includeSample {
+		query = query.Where("is_sample = ?", false)
+	}
 	if status != nil && *status != "" {
 		query = query.Where("judge_result->>'status' = ?", *status)
 	}
+	if verdict != nil && *verdict != "" {
+		query = query.Where("judge_result->>'verdict' = ?", *verdict)
+	}
 
 	err := query.Count(&total).Error
 	if err != nil {
@@ -103,6 +172,97 @@ func (r *SubmitRepo) ListByUser(userID int64, problemID *int64, status *string,
 	return submissions, total, err
 }
 
+// JudgeTimeSince 用户 since 之后创建的提交已占用的评测时长（开始到结束），以及尚未评测完的提交数
+func (r *SubmitRepo) JudgeTimeSince(userID int64, since time.Time) (time.Duration, int64, error) {
+	var row struct {
+		Seconds float64
+		Pending int64
+	}
+	err := r.db.Model(&model.Submission{}).
+		Select("COALESCE(SUM(EXTRACT(EPOCH FROM finish_time - start_time)) FILTER (WHERE start_time IS NOT NULL AND finish_time IS NOT NULL), 0) AS seconds, "+
+			"COUNT(*) FILTER (WHERE finish_time IS NULL) AS pending").
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Scan(&row).Error
+	return time.Duration(row.Seconds * float64(time.Second)), row.Pending, err
+}
+
+// MarkQueued 记录评测任务发布的 subject 和入队时间
+func (r *SubmitRepo) MarkQueued(submitID, subject string, queuedAt time.Time) error {
+	return r.db.Model(&model.Submission{}).
+		Where("submit_id = ?", submitID).
+		Updates(map[string]interface{}{
+			"queue_subject": subject,
+			"queued_at":     queuedAt,
+		}).Error
+}
+
+// CountPendingBySubject 各 subject 上仍在排队（PENDING）的提交数，subject 以 prefix 开头
+func (r *SubmitRepo) CountPendingBySubject(prefix string) (map[string]int64, error) {
+	var rows []struct {
+		QueueSubject string
+		Count        int64
+	}
+	err := r.db.Model(&model.Submission{}).
+		Select("queue_subject, COUNT(*) AS count").
+		Where("queue_subject LIKE ?", prefix+"%").
+		Where("judge_result->>'status' = ?", queue.StatusPending).
+		Group("queue_subject").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.QueueSubject] = row.Count
+	}
+	return counts, nil
+}
+
+// CountPendingAhead 同一 subject 上比 queuedAt 更早入队、仍在排队的提交数
+func (r *SubmitRepo) CountPendingAhead(subject string, queuedAt time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Submission{}).
+		Where("queue_subject = ? AND queued_at < ?", subject, queuedAt).
+		Where("judge_result->>'status' = ?", queue.StatusPending).
+		Count(&count).Error
+	return count, err
+}
+
+// LastStartedUser subject 以 prefix 开头、本次入队后最近开始评测的提交的用户，即通道轮询最近一次取到的用户。
+// 没有这样的提交时 ok 为 false
+func (r *SubmitRepo) LastStartedUser(prefix string) (userID int64, ok bool, err error) {
+	var submission model.Submission
+	err = r.db.Model(&model.Submission{}).
+		Select("user_id").
+		Where("queue_subject LIKE ? AND start_time >= queued_at", prefix+"%").
+		Order("start_time DESC").
+		Limit(1).
+		Find(&submission).Error
+	if err != nil || submission.UserID == 0 {
+		return 0, false, err
+	}
+	return submission.UserID, true, nil
+}
+
+// CountFinishedSince since 之后评测完成、subject 以 prefix 开头的提交数
+func (r *SubmitRepo) CountFinishedSince(prefix string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Submission{}).
+		Where("queue_subject LIKE ? AND finish_time >= ?", prefix+"%", since).
+		Count(&count).Error
+	return count, err
+}
+
+// ListSubmitIDsByProblem 题目下全部非样例提交的 submit_id，按提交时间排序
+func (r *SubmitRepo) ListSubmitIDsByProblem(problemID int64) ([]string, error) {
+	var ids []string
+	err := r.db.Model(&model.Submission{}).
+		Where("problem_id = ? AND is_sample = ?", problemID, false).
+		Order("created_at").
+		Pluck("submit_id", &ids).Error
+	return ids, err
+}
+
 func (r *SubmitRepo) CheckIdempotency(key string) (bool, error) {
 	var count int64
 	err := r.db.Model(&model.Submission{}).Where("idempotency_key = ?", key).Count(&count).Error
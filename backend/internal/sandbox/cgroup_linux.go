@@ -0,0 +1,138 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cgroup 单次运行的 cgroup v2 节点
+type cgroup struct {
+	path string
+}
+
+// setupCgroupRoot 创建评测根 cgroup 并开启子树控制器
+func setupCgroupRoot(root string) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup root: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return fmt.Errorf("%s is not a cgroup v2 directory: %w", root, err)
+	}
+
+	// 父节点和评测根节点都需要向下开放 memory/pids/cpu
+	for _, dir := range []string{filepath.Dir(root), root} {
+		for _, ctrl := range []string{"memory", "pids", "cpu"} {
+			err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+ctrl), 0644)
+			if err != nil && ctrl != "cpu" {
+				return fmt.Errorf("failed to enable %s controller in %s: %w", ctrl, dir, err)
+			}
+		}
+	}
+	return nil
+}
+
+func newCgroup(root, name string, memoryLimit int64, pidsLimit int) (*cgroup, error) {
+	path := filepath.Join(root, name)
+	if err := os.Mkdir(path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	cg := &cgroup{path: path}
+
+	if memoryLimit > 0 {
+		if err := cg.write("memory.max", strconv.FormatInt(memoryLimit, 10)); err != nil {
+			cg.destroy()
+			return nil, err
+		}
+		// 未开启 swap 记账时该文件不存在
+		_ = cg.write("memory.swap.max", "0")
+	}
+	if pidsLimit > 0 {
+		if err := cg.write("pids.max", strconv.Itoa(pidsLimit)); err != nil {
+			cg.destroy()
+			return nil, err
+		}
+	}
+	return cg, nil
+}
+
+func (c *cgroup) write(file, value string) error {
+	if err := os.WriteFile(filepath.Join(c.path, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
+
+// openProcs 打开 cgroup.procs，交给沙箱 init 在 exec 前把自己迁入
+func (c *cgroup) openProcs() (*os.File, error) {
+	return os.OpenFile(filepath.Join(c.path, "cgroup.procs"), os.O_WRONLY, 0)
+}
+
+// cpuTime 读取 cpu.stat 中的 usage_usec
+func (c *cgroup) cpuTime() time.Duration {
+	v, ok := c.readKey("cpu.stat", "usage_usec")
+	if !ok {
+		return 0
+	}
+	return time.Duration(v) * time.Microsecond
+}
+
+// peakMemory 读取 memory.peak（内核 5.19+），返回字节数
+func (c *cgroup) peakMemory() (int64, bool) {
+	data, err := os.ReadFile(filepath.Join(c.path, "memory.peak"))
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// oomKilled 是否发生过 OOM kill
+func (c *cgroup) oomKilled() bool {
+	v, ok := c.readKey("memory.events", "oom_kill")
+	return ok && v > 0
+}
+
+func (c *cgroup) readKey(file, key string) (int64, bool) {
+	f, err := os.Open(filepath.Join(c.path, file))
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			v, err := strconv.ParseInt(fields[1], 10, 64)
+			return v, err == nil
+		}
+	}
+	return 0, false
+}
+
+// destroy 杀掉残留进程并删除 cgroup
+func (c *cgroup) destroy() {
+	for i := 0; i < 50; i++ {
+		err := os.Remove(c.path)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		if errors.Is(err, syscall.EBUSY) {
+			// cgroup.kill 需要内核 5.14+
+			_ = c.write("cgroup.kill", "1")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,290 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	initEnv            = "OJ_SANDBOX_INIT"
+	initFailedExitCode = 127
+
+	// 父进程传给 init 的文件描述符
+	configFD = 3
+	errorFD  = 4
+	procsFD  = 5
+
+	defaultPath = "/usr/local/bin:/usr/bin:/bin"
+)
+
+// initConfig init 进程所需的全部参数，经 fd 3 以 JSON 传入
+type initConfig struct {
+	Args          []string `json:"args"`
+	Env           []string `json:"env"`
+	Dir           string   `json:"dir"`
+	Root          string   `json:"root"`
+	ReadOnlyPaths []string `json:"read_only_paths"`
+	Mounts        []Mount  `json:"mounts"`
+	TmpSize       int64    `json:"tmp_size"`
+	StackLimit    int64    `json:"stack_limit"`
+	OutputLimit   int64    `json:"output_limit"`
+	CPULimitSec   uint64   `json:"cpu_limit_sec"`
+	UID           int      `json:"uid"`
+	GID           int      `json:"gid"`
+}
+
+// Init 沙箱 init 入口。必须在 main 函数最开始调用：
+// 若当前进程是沙箱 init，则完成挂载、限制、降权后 exec 目标程序，永不返回；
+// 否则立即返回。
+func Init() {
+	if os.Getenv(initEnv) != "1" {
+		return
+	}
+	runtime.LockOSThread()
+
+	errPipe := os.NewFile(errorFD, "errpipe")
+	err := runInit()
+	// 只有 exec 失败才会走到这里
+	fmt.Fprintf(errPipe, "%v", err)
+	os.Exit(initFailedExitCode)
+}
+
+func runInit() error {
+	syscall.CloseOnExec(errorFD)
+
+	cfgFile := os.NewFile(configFD, "config")
+	var cfg initConfig
+	if err := json.NewDecoder(cfgFile).Decode(&cfg); err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	cfgFile.Close()
+
+	if len(cfg.Args) == 0 {
+		return fmt.Errorf("empty command")
+	}
+
+	if err := setupRootfs(&cfg); err != nil {
+		return err
+	}
+	if err := syscall.Sethostname([]byte("sandbox")); err != nil {
+		return fmt.Errorf("sethostname: %w", err)
+	}
+	if err := setRlimits(&cfg); err != nil {
+		return err
+	}
+
+	path, err := lookPath(cfg.Args[0], cfg.Env)
+	if err != nil {
+		return err
+	}
+
+	// 最后一刻才迁入评测 cgroup，避免 init 自身的内存和 CPU 计入选手程序
+	procs := os.NewFile(procsFD, "cgroup.procs")
+	if _, err := procs.WriteString("0"); err != nil {
+		return fmt.Errorf("failed to join cgroup: %w", err)
+	}
+	procs.Close()
+
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(cfg.GID); err != nil {
+		return fmt.Errorf("setgid: %w", err)
+	}
+	if err := syscall.Setuid(cfg.UID); err != nil {
+		return fmt.Errorf("setuid: %w", err)
+	}
+
+	if err := syscall.Exec(path, cfg.Args, cfg.Env); err != nil {
+		return fmt.Errorf("exec %s: %w", path, err)
+	}
+	return nil
+}
+
+// setupRootfs 构造只读根文件系统并 pivot_root 进去
+func setupRootfs(cfg *initConfig) error {
+	// 挂载事件不传播回宿主机
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make / private: %w", err)
+	}
+
+	root := cfg.Root
+	if err := syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "size=1m,mode=755"); err != nil {
+		return fmt.Errorf("mount root tmpfs: %w", err)
+	}
+
+	for _, p := range cfg.ReadOnlyPaths {
+		fi, err := os.Lstat(p)
+		if err != nil {
+			continue
+		}
+		// usrmerge 系统上 /bin、/lib 是符号链接，原样复制
+		if fi.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(p)
+			if err != nil {
+				return fmt.Errorf("readlink %s: %w", p, err)
+			}
+			if err := os.Symlink(target, filepath.Join(root, p)); err != nil {
+				return fmt.Errorf("symlink %s: %w", p, err)
+			}
+			continue
+		}
+		if err := bindMount(p, filepath.Join(root, p), true); err != nil {
+			return err
+		}
+	}
+
+	// /proc 属于新的 pid namespace
+	procDir := filepath.Join(root, "proc")
+	if err := os.MkdirAll(procDir, 0555); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", procDir, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount proc: %w", err)
+	}
+
+	// /dev 只放最基本的几个设备
+	devDir := filepath.Join(root, "dev")
+	if err := os.MkdirAll(devDir, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", devDir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NOEXEC, "size=64k,mode=755"); err != nil {
+		return fmt.Errorf("mount dev: %w", err)
+	}
+	for _, dev := range []string{"null", "zero", "random", "urandom"} {
+		if err := bindMount("/dev/"+dev, filepath.Join(devDir, dev), false); err != nil {
+			return err
+		}
+	}
+
+	tmpDir := filepath.Join(root, "tmp")
+	if err := os.MkdirAll(tmpDir, 01777); err != nil {
+		return err
+	}
+	tmpOpts := "mode=1777"
+	if cfg.TmpSize > 0 {
+		tmpOpts += ",size=" + strconv.FormatInt(cfg.TmpSize, 10)
+	}
+	if err := syscall.Mount("tmpfs", tmpDir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, tmpOpts); err != nil {
+		return fmt.Errorf("mount tmp: %w", err)
+	}
+
+	for _, m := range cfg.Mounts {
+		if err := bindMount(m.Source, filepath.Join(root, m.Target), m.ReadOnly); err != nil {
+			return err
+		}
+	}
+
+	// 所有挂载点就绪后，根本身也变为只读
+	if err := syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("remount root read-only: %w", err)
+	}
+
+	if err := syscall.Chdir(root); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %w", err)
+	}
+
+	dir := cfg.Dir
+	if dir == "" {
+		dir = "/"
+	}
+	if err := syscall.Chdir(dir); err != nil {
+		return fmt.Errorf("chdir %s: %w", dir, err)
+	}
+	return nil
+}
+
+func bindMount(src, dst string, readOnly bool) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("bind source %s: %w", src, err)
+	}
+	if fi.IsDir() {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+
+	if err := syscall.Mount(src, dst, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", src, err)
+	}
+	if readOnly {
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV)
+		if err := syscall.Mount("", dst, "", flags, ""); err != nil {
+			return fmt.Errorf("remount %s read-only: %w", dst, err)
+		}
+	}
+	return nil
+}
+
+func setRlimits(cfg *initConfig) error {
+	set := func(resource int, v uint64) error {
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: v, Max: v}); err != nil {
+			return fmt.Errorf("setrlimit(%d): %w", resource, err)
+		}
+		return nil
+	}
+
+	if err := set(syscall.RLIMIT_CORE, 0); err != nil {
+		return err
+	}
+	if cfg.StackLimit > 0 {
+		if err := set(syscall.RLIMIT_STACK, uint64(cfg.StackLimit)); err != nil {
+			return err
+		}
+	}
+	if cfg.OutputLimit > 0 {
+		if err := set(syscall.RLIMIT_FSIZE, uint64(cfg.OutputLimit)); err != nil {
+			return err
+		}
+	}
+	// cgroup 统计才是准确的 CPU 时间，这里只是兜底
+	if cfg.CPULimitSec > 0 {
+		if err := set(syscall.RLIMIT_CPU, cfg.CPULimitSec); err != nil {
+			return err
+		}
+	}
+	return set(syscall.RLIMIT_NOFILE, 64)
+}
+
+func lookPath(file string, env []string) (string, error) {
+	if strings.Contains(file, "/") {
+		return file, nil
+	}
+	path := defaultPath
+	for _, e := range env {
+		if strings.HasPrefix(e, "PATH=") {
+			path = strings.TrimPrefix(e, "PATH=")
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		p := filepath.Join(dir, file)
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() && fi.Mode()&0111 != 0 {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("executable %q not found in PATH", file)
+}
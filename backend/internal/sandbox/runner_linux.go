@@ -0,0 +1,211 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Runner 基于 Linux namespaces + cgroups v2 的沙箱运行器，不依赖 Docker
+type Runner struct {
+	opts    Options
+	rootDir string
+	seq     atomic.Uint64
+}
+
+// NewRunner 创建沙箱运行器，需要 root 权限和 cgroup v2
+func NewRunner(opts Options) (*Runner, error) {
+	if opts.CgroupRoot == "" {
+		return nil, fmt.Errorf("sandbox: cgroup root is required")
+	}
+	if len(opts.ReadOnlyPaths) == 0 {
+		opts.ReadOnlyPaths = DefaultReadOnlyPaths
+	}
+	if err := setupCgroupRoot(opts.CgroupRoot); err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	// 新根的挂载点，每次运行在各自私有的 mount namespace 中挂 tmpfs
+	rootDir := filepath.Join(os.TempDir(), ".oj-sandbox")
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	return &Runner{opts: opts, rootDir: rootDir}, nil
+}
+
+// UID 沙箱内进程的用户 ID
+func (r *Runner) UID() int { return r.opts.UID }
+
+// GID 沙箱内进程的组 ID
+func (r *Runner) GID() int { return r.opts.GID }
+
+// Run 在沙箱中运行一次程序。
+// 只有沙箱自身故障时返回 error；选手程序的各种失败体现在 Result.Status 中。
+func (r *Runner) Run(ctx context.Context, cfg *Config) (*Result, error) {
+	afterStart := sync.OnceFunc(func() {
+		if cfg.AfterStart != nil {
+			cfg.AfterStart()
+		}
+	})
+	defer afterStart()
+
+	name := fmt.Sprintf("run-%d-%d", os.Getpid(), r.seq.Add(1))
+	cg, err := newCgroup(r.opts.CgroupRoot, name, cfg.MemoryLimit, cfg.PidsLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer cg.destroy()
+
+	procs, err := cg.openProcs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cgroup.procs: %w", err)
+	}
+	defer procs.Close()
+
+	cfgR, cfgW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer cfgW.Close()
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		cfgR.Close()
+		return nil, err
+	}
+	defer errR.Close()
+
+	cmd := exec.Command("/proc/self/exe")
+	cmd.Args = []string{"oj-sandbox-init"}
+	cmd.Env = []string{initEnv + "=1"}
+	cmd.Stdin = cfg.Stdin
+	cmd.Stdout = cfg.Stdout
+	cmd.Stderr = cfg.Stderr
+	cmd.ExtraFiles = []*os.File{cfgR, errW, procs} // fd 3, 4, 5
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		Pdeathsig: syscall.SIGKILL,
+	}
+
+	start := time.Now()
+	err = cmd.Start()
+	cfgR.Close()
+	errW.Close()
+	afterStart()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sandbox: %w", err)
+	}
+
+	if err := json.NewEncoder(cfgW).Encode(r.initConfig(cfg)); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("failed to send sandbox config: %w", err)
+	}
+	cfgW.Close()
+
+	var wallExceeded atomic.Bool
+	timer := time.AfterFunc(cfg.wallTimeLimit(), func() {
+		wallExceeded.Store(true)
+		_ = cmd.Process.Kill()
+	})
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+
+	waitErr := cmd.Wait()
+	timer.Stop()
+	close(done)
+	wall := time.Since(start)
+
+	initErr, _ := io.ReadAll(errR)
+	if len(initErr) > 0 {
+		return nil, fmt.Errorf("sandbox init: %s", initErr)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		return nil, fmt.Errorf("failed to wait sandbox: %w", waitErr)
+	}
+
+	res := &Result{
+		Status:   StatusOK,
+		CPUTime:  cg.cpuTime(),
+		WallTime: wall,
+	}
+	if peak, ok := cg.peakMemory(); ok {
+		res.MemoryKB = peak / 1024
+	} else if ru, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+		res.MemoryKB = ru.Maxrss
+	}
+
+	ws, _ := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	switch {
+	case cg.oomKilled():
+		res.Status = StatusMemoryLimitExceeded
+	case wallExceeded.Load(),
+		cfg.TimeLimit > 0 && res.CPUTime > cfg.TimeLimit,
+		ws.Signaled() && ws.Signal() == syscall.SIGXCPU:
+		res.Status = StatusTimeLimitExceeded
+	case ws.Signaled() && ws.Signal() == syscall.SIGXFSZ:
+		res.Status = StatusOutputLimitExceeded
+	case ws.Signaled():
+		res.Status = StatusRuntimeError
+		res.Error = ws.Signal().String()
+	case ws.ExitStatus() != 0:
+		res.Status = StatusRuntimeError
+		res.ExitCode = ws.ExitStatus()
+		res.Error = fmt.Sprintf("exit code %d", res.ExitCode)
+	}
+	if ws.Signaled() {
+		res.Signal = int(ws.Signal())
+	}
+	return res, nil
+}
+
+func (r *Runner) initConfig(cfg *Config) *initConfig {
+	env := cfg.Env
+	if len(env) == 0 {
+		env = []string{"PATH=" + defaultPath, "HOME=/tmp", "LANG=C.UTF-8"}
+	}
+	stack := cfg.StackLimit
+	if stack == 0 {
+		stack = cfg.MemoryLimit
+	}
+	var cpuSec uint64
+	if cfg.TimeLimit > 0 {
+		cpuSec = uint64((cfg.TimeLimit+time.Second-1)/time.Second) + 1
+	}
+	return &initConfig{
+		Args:          cfg.Args,
+		Env:           env,
+		Dir:           cfg.Dir,
+		Root:          r.rootDir,
+		ReadOnlyPaths: r.opts.ReadOnlyPaths,
+		Mounts:        cfg.Mounts,
+		TmpSize:       cfg.TmpSize,
+		StackLimit:    stack,
+		OutputLimit:   cfg.OutputLimit,
+		CPULimitSec:   cpuSec,
+		UID:           r.opts.UID,
+		GID:           r.opts.GID,
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+package sandbox
+
+import "context"
+
+// Runner 非 Linux 平台占位实现
+type Runner struct{}
+
+// NewRunner 非 Linux 平台不支持沙箱
+func NewRunner(opts Options) (*Runner, error) {
+	return nil, ErrUnsupported
+}
+
+// Init 非 Linux 平台无需处理
+func Init() {}
+
+// UID 沙箱内进程的用户 ID
+func (r *Runner) UID() int { return 0 }
+
+// GID 沙箱内进程的组 ID
+func (r *Runner) GID() int { return 0 }
+
+// Run 非 Linux 平台不支持沙箱
+func (r *Runner) Run(ctx context.Context, cfg *Config) (*Result, error) {
+	return nil, ErrUnsupported
+}
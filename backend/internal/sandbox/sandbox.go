@@ -0,0 +1,86 @@
+package sandbox
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrUnsupported 当前平台不支持沙箱
+var ErrUnsupported = errors.New("sandbox: unsupported platform")
+
+// Status 沙箱运行状态
+type Status string
+
+const (
+	StatusOK                  Status = "OK"
+	StatusTimeLimitExceeded   Status = "TLE"
+	StatusMemoryLimitExceeded Status = "MLE"
+	StatusOutputLimitExceeded Status = "OLE"
+	StatusRuntimeError        Status = "RE"
+	StatusSystemError         Status = "SE"
+)
+
+// Mount 挂载到沙箱内的目录
+type Mount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// Config 单次运行配置
+type Config struct {
+	Args []string
+	Env  []string
+	Dir  string // 沙箱内工作目录
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Mounts []Mount
+
+	TimeLimit     time.Duration // CPU 时间限制
+	WallTimeLimit time.Duration // 墙钟时间限制，为 0 时取 CPU 限制的 2 倍 + 1s
+	MemoryLimit   int64         // 字节
+	StackLimit    int64         // 字节，0 表示与内存限制一致
+	OutputLimit   int64         // 单个文件最大写入字节数
+	PidsLimit     int
+	TmpSize       int64 // /tmp tmpfs 大小，字节
+
+	// AfterStart 子进程启动后调用（启动失败时也会调用），
+	// 交互题用它关闭父进程持有的管道端，使对端能读到 EOF
+	AfterStart func()
+}
+
+// Result 运行结果
+type Result struct {
+	Status   Status        `json:"status"`
+	ExitCode int           `json:"exit_code"`
+	Signal   int           `json:"signal"`
+	CPUTime  time.Duration `json:"cpu_time"`
+	WallTime time.Duration `json:"wall_time"`
+	MemoryKB int64         `json:"memory_kb"` // 峰值内存
+	Error    string        `json:"error,omitempty"`
+}
+
+// Options Runner 配置
+type Options struct {
+	// CgroupRoot 评测专用 cgroup v2 目录，每次运行在其下创建子 cgroup
+	CgroupRoot string
+	// UID/GID 沙箱内进程身份
+	UID int
+	GID int
+	// ReadOnlyPaths 只读挂载进沙箱的宿主机目录（工具链、动态库）
+	ReadOnlyPaths []string
+}
+
+// DefaultReadOnlyPaths 默认只读挂载目录
+var DefaultReadOnlyPaths = []string{"/bin", "/lib", "/lib64", "/usr", "/etc"}
+
+func (c *Config) wallTimeLimit() time.Duration {
+	if c.WallTimeLimit > 0 {
+		return c.WallTimeLimit
+	}
+	return 2*c.TimeLimit + time.Second
+}
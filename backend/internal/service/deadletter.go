@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/repository"
+)
+
+// dlqTimeout 单次死信操作的超时，批量重放按条计算
+const dlqTimeout = 10 * time.Second
+
+// DeadLetterService 死信查看、重放和清除，所有变更都记录操作人
+type DeadLetterService struct {
+	js         jetstream.JetStream
+	submitRepo *repository.SubmitRepo
+	auditRepo  *repository.DeadLetterAuditRepo
+	submits    *SubmitService
+}
+
+func NewDeadLetterService(js jetstream.JetStream, submitRepo *repository.SubmitRepo, auditRepo *repository.DeadLetterAuditRepo, submits *SubmitService) *DeadLetterService {
+	return &DeadLetterService{
+		js:         js,
+		submitRepo: submitRepo,
+		auditRepo:  auditRepo,
+		submits:    submits,
+	}
+}
+
+// List 从新到旧列出死信，列表不带代码
+func (s *DeadLetterService) List(page, pageSize int) ([]queue.DeadLetter, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dlqTimeout)
+	defer cancel()
+
+	letters, total, err := queue.ListDeadLetters(ctx, s.js, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range letters {
+		letters[i].Task.Code = ""
+	}
+	return letters, total, nil
+}
+
+// Get 查看单条死信，包括代码和历次失败记录
+func (s *DeadLetterService) Get(seq uint64) (*queue.DeadLetter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dlqTimeout)
+	defer cancel()
+	return queue.GetDeadLetter(ctx, s.js, seq)
+}
+
+// Replay 按提交和题目的当前配置重新生成任务并发布到死信原来的通道，随后从死信队列删除。
+// 死信中冻结的任务只用于确定提交和通道：题目、语言在死信期间可能已修改，与重判一样以数据库为准，
+// 队列类别也按语言当前的配置重新选择。重试次数从零开始，失败记录保留在提交上。
+func (s *DeadLetterService) Replay(adminID int64, seq uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dlqTimeout)
+	defer cancel()
+
+	dl, err := queue.GetDeadLetter(ctx, s.js, seq)
+	if err != nil {
+		return err
+	}
+	if dl.Subject == "" {
+		return fmt.Errorf("dead letter %d has no subject", seq)
+	}
+	submission, err := s.submitRepo.GetBySubmitID(dl.Task.SubmitID)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrSubmissionNotFound, dl.Task.SubmitID)
+	}
+
+	// 旧版本写入的死信没有通道，重放时进入 practice
+	lane := queue.SubjectLane(dl.Subject)
+	// 先改回 PENDING 再发布，避免覆盖 worker 写入的 COMPILING
+	if err := s.submitRepo.ResetPending(submission.SubmitID); err != nil {
+		return fmt.Errorf("failed to reset submission: %w", err)
+	}
+	if err := s.submits.publishJudgeTask(submission, lane); err != nil {
+		return fmt.Errorf("failed to publish task: %w", err)
+	}
+	if err := queue.DeleteDeadLetter(ctx, s.js, seq); err != nil && !errors.Is(err, queue.ErrDeadLetterNotFound) {
+		return fmt.Errorf("task replayed but dead letter not removed: %w", err)
+	}
+
+	return s.auditRepo.Create(&model.DeadLetterAudit{
+		AdminID:  adminID,
+		Action:   repository.DeadLetterReplay,
+		Seq:      int64(seq),
+		SubmitID: submission.SubmitID,
+		Detail:   fmt.Sprintf("replayed to %s lane after %d failed attempts", lane, len(dl.Task.Errors)),
+	})
+}
+
+// ReplayFailure 批量重放中失败的一条
+type ReplayFailure struct {
+	Seq   uint64 `json:"seq"`
+	Error string `json:"error"`
+}
+
+// ReplayBatch 批量重放，all 为 true 时重放全部死信；单条失败不影响其余
+func (s *DeadLetterService) ReplayBatch(adminID int64, seqs []uint64, all bool) (int, []ReplayFailure, error) {
+	if all {
+		ctx, cancel := context.WithTimeout(context.Background(), dlqTimeout)
+		var err error
+		seqs, err = queue.DeadLetterSeqs(ctx, s.js)
+		cancel()
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	replayed := 0
+	failures := []ReplayFailure{}
+	for _, seq := range seqs {
+		if err := s.Replay(adminID, seq); err != nil {
+			failures = append(failures, ReplayFailure{Seq: seq, Error: err.Error()})
+			continue
+		}
+		replayed++
+	}
+	return replayed, failures, nil
+}
+
+// Delete 丢弃单条死信，提交保持系统错误
+func (s *DeadLetterService) Delete(adminID int64, seq uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dlqTimeout)
+	defer cancel()
+
+	dl, err := queue.GetDeadLetter(ctx, s.js, seq)
+	if err != nil {
+		return err
+	}
+	if err := queue.DeleteDeadLetter(ctx, s.js, seq); err != nil {
+		return err
+	}
+	return s.auditRepo.Create(&model.DeadLetterAudit{
+		AdminID:  adminID,
+		Action:   repository.DeadLetterDelete,
+		Seq:      int64(seq),
+		SubmitID: dl.Task.SubmitID,
+	})
+}
+
+// Purge 清空死信队列
+func (s *DeadLetterService) Purge(adminID int64) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dlqTimeout)
+	defer cancel()
+
+	purged, err := queue.PurgeDeadLetters(ctx, s.js)
+	if err != nil {
+		return 0, err
+	}
+	err = s.auditRepo.Create(&model.DeadLetterAudit{
+		AdminID: adminID,
+		Action:  repository.DeadLetterPurge,
+		Detail:  fmt.Sprintf("purged %d dead letters", purged),
+	})
+	return purged, err
+}
+
+func (s *DeadLetterService) ListAudits(submitID string, page, pageSize int) ([]model.DeadLetterAudit, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	return s.auditRepo.List(submitID, page, pageSize)
+}
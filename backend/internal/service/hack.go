@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/repository"
+)
+
+var (
+	ErrHackNotFound   = errors.New("hack not found")
+	ErrHackNotAllowed = errors.New("hack not allowed")
+	ErrInvalidHack    = errors.New("invalid hack")
+)
+
+// hackInputLimit hack 输入的上限
+const hackInputLimit = 256 << 10
+
+type HackService struct {
+	repo           *repository.HackRepo
+	submitRepo     *repository.SubmitRepo
+	contestRepo    *repository.ContestRepo
+	problemRepo    *repository.ProblemRepo
+	langRepo       *repository.LanguageRepo
+	problemService *ProblemService
+	js             jetstream.JetStream
+}
+
+func NewHackService(repo *repository.HackRepo, submitRepo *repository.SubmitRepo, contestRepo *repository.ContestRepo, problemRepo *repository.ProblemRepo, langRepo *repository.LanguageRepo, problemService *ProblemService, js jetstream.JetStream) *HackService {
+	return &HackService{
+		repo:           repo,
+		submitRepo:     submitRepo,
+		contestRepo:    contestRepo,
+		problemRepo:    problemRepo,
+		langRepo:       langRepo,
+		problemService: problemService,
+		js:             js,
+	}
+}
+
+type HackParams struct {
+	TargetSubmitID string `json:"target_submit_id" binding:"required"`
+	Input          string `json:"input" binding:"required"`
+}
+
+// Create 发起 hack。只能在开放 hack 的比赛进行中、自己已通过该题后，
+// 针对同场比赛中他人已通过的提交发起。
+func (s *HackService) Create(userID, contestID int64, params HackParams) (*model.Hack, error) {
+	contest, err := s.contestRepo.GetByID(contestID)
+	if err != nil {
+		return nil, ErrContestNotFound
+	}
+	now := time.Now()
+	if !contest.AllowHack {
+		return nil, fmt.Errorf("%w: contest does not allow hacks", ErrHackNotAllowed)
+	}
+	if now.Before(contest.StartTime) || now.After(contest.EndTime) {
+		return nil, fmt.Errorf("%w: contest is not running", ErrHackNotAllowed)
+	}
+	if _, err := s.contestRepo.GetParticipant(contestID, userID); err != nil {
+		return nil, fmt.Errorf("%w: not a participant", ErrHackNotAllowed)
+	}
+
+	target, err := s.submitRepo.GetBySubmitID(params.TargetSubmitID)
+	if err != nil || target.ContestID == nil || *target.ContestID != contestID || target.IsSample {
+		return nil, fmt.Errorf("%w: target submission not found in this contest", ErrInvalidHack)
+	}
+	if target.UserID == userID {
+		return nil, fmt.Errorf("%w: cannot hack your own submission", ErrInvalidHack)
+	}
+	if submissionVerdict(target) != queue.VerdictAC {
+		return nil, fmt.Errorf("%w: target submission is not accepted", ErrInvalidHack)
+	}
+	solved, err := s.submitRepo.HasAccepted(userID, contestID, target.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	if !solved {
+		return nil, fmt.Errorf("%w: solve the problem before hacking it", ErrHackNotAllowed)
+	}
+
+	if strings.TrimSpace(params.Input) == "" || len(params.Input) > hackInputLimit {
+		return nil, fmt.Errorf("%w: input must be non-empty and at most %d bytes", ErrInvalidHack, hackInputLimit)
+	}
+
+	problem, err := s.problemRepo.GetByID(target.ProblemID)
+	if err != nil {
+		return nil, ErrProblemNotFound
+	}
+	if problem.IsInteractive || strings.TrimSpace(problem.ValidatorCode) == "" || strings.TrimSpace(problem.StdCode) == "" {
+		return nil, fmt.Errorf("%w: problem does not support hacks", ErrHackNotAllowed)
+	}
+
+	hack := &model.Hack{
+		ContestID:      contestID,
+		ProblemID:      target.ProblemID,
+		HackerID:       userID,
+		TargetSubmitID: target.SubmitID,
+		TargetUserID:   target.UserID,
+		Input:          params.Input,
+		Status:         repository.HackStatusPending,
+	}
+	if err := s.repo.Create(hack); err != nil {
+		return nil, err
+	}
+
+	if err := s.publishHackTask(hack, target, problem); err != nil {
+		return nil, err
+	}
+	return hack, nil
+}
+
+// publishHackTask 发布 hack 任务，题目配置带上校验器和标程
+func (s *HackService) publishHackTask(hack *model.Hack, target *model.Submission, problem *model.Problem) error {
+	lang, err := s.langRepo.GetByID(target.LanguageID)
+	if err != nil {
+		return fmt.Errorf("failed to get language: %w", err)
+	}
+	qp, err := toQueueProblem(s.langRepo, problem)
+	if err != nil {
+		return err
+	}
+	qp.Validator, err = problemProgram(s.langRepo, problem.ValidatorLang, problem.ValidatorCode)
+	if err != nil {
+		return fmt.Errorf("failed to get validator language: %w", err)
+	}
+	qp.Std, err = problemProgram(s.langRepo, problem.StdLang, problem.StdCode)
+	if err != nil {
+		return fmt.Errorf("failed to get std language: %w", err)
+	}
+
+	return queue.PublishHack(context.Background(), s.js, &queue.HackTask{
+		HackID:    hack.ID,
+		ContestID: hack.ContestID,
+		Problem:   *qp,
+		Language:  toQueueLanguage(lang),
+		Code:      target.Code,
+		CodeHash:  target.CodeHash,
+		Input:     hack.Input,
+		CreatedAt: time.Now(),
+	})
+}
+
+// Get 获取 hack 详情。比赛结束前只有 hacker、被 hack 者和管理员能看到输入。
+func (s *HackService) Get(contestID, hackID, userID int64, isAdmin bool) (*model.Hack, error) {
+	hack, err := s.repo.GetByID(hackID)
+	if err != nil || hack.ContestID != contestID {
+		return nil, ErrHackNotFound
+	}
+	if isAdmin || userID == hack.HackerID || userID == hack.TargetUserID {
+		return hack, nil
+	}
+	contest, err := s.contestRepo.GetByID(contestID)
+	if err != nil || time.Now().Before(contest.EndTime) {
+		hack.Input = ""
+	}
+	return hack, nil
+}
+
+func (s *HackService) List(params repository.ListHackParams) ([]model.Hack, int64, error) {
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 20
+	}
+	return s.repo.List(params)
+}
+
+// AddToTests 把成功 hack 的输入和标程答案追加到题目的系统测试
+func (s *HackService) AddToTests(contestID, hackID int64) error {
+	hack, err := s.repo.GetByID(hackID)
+	if err != nil || hack.ContestID != contestID {
+		return ErrHackNotFound
+	}
+	if hack.Verdict != queue.HackSuccess {
+		return fmt.Errorf("%w: only successful hacks can be added to tests", ErrInvalidHack)
+	}
+	if hack.AddedToTests {
+		return nil
+	}
+	if err := s.problemService.AppendTestCase(hack.ProblemID, hack.Input, hack.Answer); err != nil {
+		return err
+	}
+	return s.repo.MarkAddedToTests(hack.ID)
+}
+
+// submissionVerdict 提交的最终结论，评测未结束时为空
+func submissionVerdict(submission *model.Submission) string {
+	var result struct {
+		Verdict string `json:"verdict"`
+	}
+	if err := json.Unmarshal([]byte(submission.JudgeResult), &result); err != nil {
+		return ""
+	}
+	return result.Verdict
+}
@@ -0,0 +1,334 @@
+package judge
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+const (
+	artifactFetchTimeout = 2 * time.Minute
+	maxArtifactFileSize  = 256 << 20
+)
+
+// ArtifactCache 编译产物缓存。键由代码哈希、语言、编译命令和工具链版本共同决定，
+// 命中时直接复制产物，跳过编译。本地按 LRU 限制总大小；配置了 bucket 时
+// 产物同时上传到 MinIO，供其他 worker 和本机缓存淘汰后复用。
+type ArtifactCache struct {
+	*diskLRU
+	minio  *minio.Client
+	bucket string
+}
+
+// NewArtifactCache 创建编译产物缓存，bucket 为空时只使用本地缓存
+func NewArtifactCache(dir string, maxBytes int64, minioClient *minio.Client, bucket string) (*ArtifactCache, error) {
+	lru, err := newDiskLRU(dir, maxBytes, "artifact")
+	if err != nil {
+		return nil, err
+	}
+	c := &ArtifactCache{diskLRU: lru, bucket: bucket}
+	if bucket != "" {
+		c.minio = minioClient
+	}
+	return c, nil
+}
+
+// artifactKey 任务编译产物的缓存键，toolchain 为本机探测到的编译器版本（ProbeToolchains）。
+// 产物经 MinIO 在 worker 间共享，工具链版本不同的 worker 不能复用彼此的产物，
+// 因此没有代码哈希、无需编译或版本未知时返回空串，不走缓存。
+func artifactKey(task *queue.JudgeTask, toolchain string) string {
+	lang := task.Language
+	if !isSHA256(task.CodeHash) || strings.TrimSpace(lang.CompileCmd) == "" {
+		return ""
+	}
+	if toolchain == "" || toolchain == unknownToolchain {
+		return ""
+	}
+	h := sha256.New()
+	for _, part := range []string{task.CodeHash, lang.Slug, lang.CompileCmd, lang.SourceFilename, toolchain} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Restore 把缓存的产物复制到 box，文件交给 uid/gid。未命中时返回 false。
+func (c *ArtifactCache) Restore(key, box string, uid, gid int) (bool, error) {
+	e, ok := c.acquire(key)
+	if !ok {
+		if err := c.fetch(key); err != nil {
+			return false, err
+		}
+		if e, ok = c.acquire(key); !ok {
+			return false, nil
+		}
+	}
+	defer c.release(e)
+	return true, copyTree(e.dir, box, uid, gid)
+}
+
+// Store 把 box 中的编译产物加入缓存
+func (c *ArtifactCache) Store(key, box string) error {
+	c.mu.Lock()
+	_, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	tmp, err := os.MkdirTemp(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if err := copyTree(box, tmp, -1, -1); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := c.add(key, tmp, dirSize(tmp)); err != nil {
+		return err
+	}
+	if c.minio != nil {
+		go c.upload(key)
+	}
+	return nil
+}
+
+func artifactObject(key string) string {
+	return "artifacts/" + key + ".tar.gz"
+}
+
+// upload 打包上传到 MinIO，失败只记日志
+func (c *ArtifactCache) upload(key string) {
+	e, ok := c.acquire(key)
+	if !ok {
+		return
+	}
+	defer c.release(e)
+
+	var buf bytes.Buffer
+	if err := tarDir(e.dir, &buf); err != nil {
+		log.Printf("Failed to pack artifact %s: %v", key, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), artifactFetchTimeout)
+	defer cancel()
+	if _, err := c.minio.PutObject(ctx, c.bucket, artifactObject(key), &buf, int64(buf.Len()),
+		minio.PutObjectOptions{ContentType: "application/gzip"}); err != nil {
+		log.Printf("Failed to upload artifact %s: %v", key, err)
+	}
+}
+
+// fetch 从 MinIO 下载产物到本地缓存；对象不存在不算错误
+func (c *ArtifactCache) fetch(key string) error {
+	if c.minio == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), artifactFetchTimeout)
+	defer cancel()
+
+	obj, err := c.minio.GetObject(ctx, c.bucket, artifactObject(key), minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get artifact %s: %w", key, err)
+	}
+	defer obj.Close()
+	if _, err := obj.Stat(); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil
+		}
+		return fmt.Errorf("failed to get artifact %s: %w", key, err)
+	}
+
+	tmp, err := os.MkdirTemp(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if err := untarTo(obj, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("failed to unpack artifact %s: %w", key, err)
+	}
+	return c.add(key, tmp, dirSize(tmp))
+}
+
+// copyTree 复制目录内容（普通文件、子目录和符号链接），uid 为 -1 时不改属主
+func copyTree(src, dst string, uid, gid int) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if rel != "." {
+				if err := os.MkdirAll(target, info.Mode().Perm()); err != nil {
+					return err
+				}
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+			if uid >= 0 {
+				return os.Lchown(target, uid, gid)
+			}
+			return nil
+		case info.Mode().IsRegular():
+			if err := copyFile(path, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		if uid >= 0 {
+			return os.Chown(target, uid, gid)
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// tarDir 把目录打包为 tar.gz
+func tarDir(dir string, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// untarTo 解压 tar.gz 到目录，拒绝越界路径
+func untarTo(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in artifact: %s", hdr.Name)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			n, err := io.Copy(out, io.LimitReader(tr, maxArtifactFileSize+1))
+			out.Close()
+			if err != nil {
+				return err
+			}
+			if n > maxArtifactFileSize {
+				return fmt.Errorf("%s exceeds %d bytes", hdr.Name, maxArtifactFileSize)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package judge
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+func TestArtifactCache(t *testing.T) {
+	c, err := NewArtifactCache(t.TempDir(), 1<<20, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	box := t.TempDir()
+	if err := os.WriteFile(filepath.Join(box, "main"), []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(box, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(box, "pkg", "A.class"), []byte("class"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "a" + string(bytes.Repeat([]byte("0"), 63))
+	dst := t.TempDir()
+	if hit, err := c.Restore(key, dst, -1, -1); err != nil || hit {
+		t.Fatalf("Restore() before Store = %v, %v, want miss", hit, err)
+	}
+	if err := c.Store(key, box); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if hit, err := c.Restore(key, dst, -1, -1); err != nil || !hit {
+		t.Fatalf("Restore() = %v, %v, want hit", hit, err)
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "main"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("main not restored with mode 0755: %v %v", info, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "pkg", "A.class")); err != nil || string(data) != "class" {
+		t.Errorf("pkg/A.class = %q, %v", data, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tarDir(box, &buf); err != nil {
+		t.Fatalf("tarDir() error = %v", err)
+	}
+	out := t.TempDir()
+	if err := untarTo(&buf, out); err != nil {
+		t.Fatalf("untarTo() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(out, "pkg", "A.class")); err != nil || string(data) != "class" {
+		t.Errorf("untarred pkg/A.class = %q, %v", data, err)
+	}
+}
+
+func TestArtifactKey(t *testing.T) {
+	task := &queue.JudgeTask{
+		CodeHash: "b" + string(bytes.Repeat([]byte("0"), 63)),
+		Language: queue.Language{Slug: "cpp17", CompileCmd: `["g++", "-o", "main", "main.cpp"]`, SourceFilename: "main.cpp"},
+	}
+	gcc12 := artifactKey(task, "g++ (Debian 12.2.0-14) 12.2.0")
+	gcc13 := artifactKey(task, "g++ (Debian 13.2.0-4) 13.2.0")
+	if gcc12 == "" || gcc13 == "" {
+		t.Fatal("artifactKey() should not be empty with a known toolchain")
+	}
+	if gcc12 == gcc13 {
+		t.Error("toolchain upgrade should change the artifact key")
+	}
+	if got := artifactKey(task, unknownToolchain); got != "" {
+		t.Errorf("artifactKey() with unknown toolchain = %q, want empty", got)
+	}
+}
+
+func TestArtifactCacheEvict(t *testing.T) {
+	c, err := NewArtifactCache(t.TempDir(), 150, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := func(key string, size int) {
+		t.Helper()
+		box := t.TempDir()
+		if err := os.WriteFile(filepath.Join(box, "main"), bytes.Repeat([]byte("x"), size), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Store(key, box); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keyA := "a" + string(bytes.Repeat([]byte("0"), 63))
+	keyB := "b" + string(bytes.Repeat([]byte("0"), 63))
+	store(keyA, 100)
+
+	// 加入 b 后总大小超限，淘汰未被使用的 a；刚加入的 b 单独超过上限也要保留，否则存入即被删掉
+	store(keyB, 200)
+	c.mu.Lock()
+	_, hasA := c.entries[keyA]
+	size := c.size
+	c.mu.Unlock()
+	if hasA {
+		t.Fatal("unused artifact not evicted after store")
+	}
+	if size != 200 {
+		t.Fatalf("cache size %d, want only the newest artifact", size)
+	}
+	if hit, err := c.Restore(keyB, t.TempDir(), -1, -1); err != nil || !hit {
+		t.Fatalf("Restore() = %v, %v, want hit for the newest artifact", hit, err)
+	}
+}
@@ -0,0 +1,215 @@
+package judge
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 内置比对方式，对应 Problem.Checker
+const (
+	CheckerExact      = "exact"       // 逐字节完全一致
+	CheckerToken      = "token"       // 按空白分词比较，忽略空白数量和换行符差异（默认）
+	CheckerTokenICase = "token_icase" // 同 token，忽略大小写
+	CheckerFloat      = "float"       // 数字按绝对/相对误差比较，其余按 token 比较
+
+	defaultFloatEpsilon = 1e-6
+
+	maxTokenSize    = 64 << 20
+	maxMessageToken = 32 // WA 信息中单个 token 的最大展示长度
+)
+
+// CheckResult 比对结果
+type CheckResult struct {
+	Status  string // AC / WA / PE
+	Message string
+	// Score 部分分比例 [0, 1]，仅 SPJ 会给出非 0/1 的值
+	Score float64
+}
+
+// Checker 输出比对器
+type Checker interface {
+	Check(inputPath, outputPath, answerPath string) (*CheckResult, error)
+}
+
+// NewChecker 按题目配置创建内置比对器
+func NewChecker(kind string, epsilon float64) (Checker, error) {
+	switch kind {
+	case "", CheckerToken:
+		return &tokenChecker{}, nil
+	case CheckerTokenICase:
+		return &tokenChecker{ignoreCase: true}, nil
+	case CheckerExact:
+		return &exactChecker{}, nil
+	case CheckerFloat:
+		if epsilon < 0 {
+			return nil, fmt.Errorf("invalid checker epsilon %g", epsilon)
+		}
+		if epsilon == 0 {
+			epsilon = defaultFloatEpsilon
+		}
+		return &floatChecker{epsilon: epsilon}, nil
+	default:
+		return nil, fmt.Errorf("unknown checker %q", kind)
+	}
+}
+
+func accepted() *CheckResult {
+	return &CheckResult{Status: "AC", Score: 1}
+}
+
+func wrongAnswer(format string, args ...interface{}) *CheckResult {
+	return &CheckResult{Status: "WA", Message: fmt.Sprintf(format, args...)}
+}
+
+// exactChecker 逐字节比较
+type exactChecker struct{}
+
+func (c *exactChecker) Check(_, outputPath, answerPath string) (*CheckResult, error) {
+	out, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	ans, err := os.ReadFile(answerPath)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(out, ans) {
+		return accepted(), nil
+	}
+
+	n := min(len(out), len(ans))
+	pos := 0
+	for pos < n && out[pos] == ans[pos] {
+		pos++
+	}
+	line := bytes.Count(ans[:pos], []byte{'\n'}) + 1
+
+	// 内容一致、仅空白不同时判为格式错误
+	if tokensEqual(out, ans) {
+		return &CheckResult{
+			Status:  "PE",
+			Message: fmt.Sprintf("whitespace differs at byte %d (line %d)", pos+1, line),
+		}, nil
+	}
+	switch {
+	case pos == len(out):
+		return wrongAnswer("output is shorter than answer: ended at byte %d (line %d)", pos+1, line), nil
+	case pos == len(ans):
+		return wrongAnswer("output is longer than answer: extra data at byte %d (line %d)", pos+1, line), nil
+	default:
+		return wrongAnswer("byte %d (line %d) differs: expected %q, got %q", pos+1, line, ans[pos], out[pos]), nil
+	}
+}
+
+// tokenChecker 按空白分词比较
+type tokenChecker struct {
+	ignoreCase bool
+}
+
+func (c *tokenChecker) Check(_, outputPath, answerPath string) (*CheckResult, error) {
+	return compareTokens(outputPath, answerPath, func(i int, expected, got string) *CheckResult {
+		if expected == got || c.ignoreCase && strings.EqualFold(expected, got) {
+			return nil
+		}
+		return wrongAnswer("token %d differs: expected %s, got %s", i, shorten(expected), shorten(got))
+	})
+}
+
+// floatChecker 浮点数按 |a-b| <= eps 或 |a-b| <= eps*|b| 判定
+type floatChecker struct {
+	epsilon float64
+}
+
+func (c *floatChecker) Check(_, outputPath, answerPath string) (*CheckResult, error) {
+	return compareTokens(outputPath, answerPath, func(i int, expected, got string) *CheckResult {
+		want, err := strconv.ParseFloat(expected, 64)
+		if err != nil {
+			// 答案中的非数字 token 严格比较
+			if expected == got {
+				return nil
+			}
+			return wrongAnswer("token %d differs: expected %s, got %s", i, shorten(expected), shorten(got))
+		}
+		have, err := strconv.ParseFloat(got, 64)
+		if err != nil || math.IsNaN(have) || math.IsInf(have, 0) {
+			return wrongAnswer("token %d: expected number %s, got %s", i, shorten(expected), shorten(got))
+		}
+		diff := math.Abs(have - want)
+		if diff <= c.epsilon || diff <= c.epsilon*math.Abs(want) {
+			return nil
+		}
+		return wrongAnswer("token %d differs: expected %s, got %s (error %.3g)", i, shorten(expected), shorten(got), diff)
+	})
+}
+
+// compareTokens 逐个 token 调用 cmp，cmp 返回非 nil 即为比对失败
+func compareTokens(outputPath, answerPath string, cmp func(i int, expected, got string) *CheckResult) (*CheckResult, error) {
+	out, err := os.Open(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	ans, err := os.Open(answerPath)
+	if err != nil {
+		return nil, err
+	}
+	defer ans.Close()
+
+	outTokens := newTokenScanner(out)
+	ansTokens := newTokenScanner(ans)
+	for i := 1; ; i++ {
+		hasAns := ansTokens.Scan()
+		hasOut := outTokens.Scan()
+		if err := ansTokens.Err(); err != nil {
+			return nil, err
+		}
+		if err := outTokens.Err(); err != nil {
+			return wrongAnswer("failed to read output: %v", err), nil
+		}
+
+		switch {
+		case !hasAns && !hasOut:
+			return accepted(), nil
+		case !hasAns:
+			return wrongAnswer("extra token %d in output: %s", i, shorten(outTokens.Text())), nil
+		case !hasOut:
+			return wrongAnswer("output ended at token %d, expected %s", i, shorten(ansTokens.Text())), nil
+		}
+		if r := cmp(i, ansTokens.Text(), outTokens.Text()); r != nil {
+			return r, nil
+		}
+	}
+}
+
+func tokensEqual(a, b []byte) bool {
+	ta, tb := bytes.Fields(a), bytes.Fields(b)
+	if len(ta) != len(tb) {
+		return false
+	}
+	for i := range ta {
+		if !bytes.Equal(ta[i], tb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func newTokenScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxTokenSize)
+	s.Split(bufio.ScanWords)
+	return s
+}
+
+func shorten(token string) string {
+	if len(token) <= maxMessageToken {
+		return token
+	}
+	return strings.ToValidUTF8(token[:maxMessageToken], "") + "..."
+}
@@ -0,0 +1,202 @@
+package judge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckers(t *testing.T) {
+	tests := []struct {
+		name        string
+		checker     string
+		epsilon     float64
+		output      string
+		answer      string
+		wantStatus  string
+		wantMessage string
+	}{
+		{
+			name:       "exact match",
+			checker:    CheckerExact,
+			output:     "1 2\n3\n",
+			answer:     "1 2\n3\n",
+			wantStatus: "AC",
+		},
+		{
+			name:        "exact whitespace only",
+			checker:     CheckerExact,
+			output:      "1 2\r\n3\r\n",
+			answer:      "1 2\n3\n",
+			wantStatus:  "PE",
+			wantMessage: "whitespace differs at byte 4 (line 1)",
+		},
+		{
+			name:        "exact differs",
+			checker:     CheckerExact,
+			output:      "1 2\n4\n",
+			answer:      "1 2\n3\n",
+			wantStatus:  "WA",
+			wantMessage: "byte 5 (line 2) differs: expected '3', got '4'",
+		},
+		{
+			name:       "token ignores whitespace and line endings",
+			checker:    CheckerToken,
+			output:     "1   2\r\n3\r\n\r\n",
+			answer:     "1 2\n3",
+			wantStatus: "AC",
+		},
+		{
+			name:        "token differs",
+			checker:     "",
+			output:      "1 2 4",
+			answer:      "1 2 3",
+			wantStatus:  "WA",
+			wantMessage: "token 3 differs: expected 3, got 4",
+		},
+		{
+			name:        "token output too short",
+			checker:     CheckerToken,
+			output:      "1 2",
+			answer:      "1 2 3",
+			wantStatus:  "WA",
+			wantMessage: "output ended at token 3, expected 3",
+		},
+		{
+			name:        "token extra output",
+			checker:     CheckerToken,
+			output:      "1 2 3 4",
+			answer:      "1 2 3",
+			wantStatus:  "WA",
+			wantMessage: "extra token 4 in output: 4",
+		},
+		{
+			name:        "token is case sensitive",
+			checker:     CheckerToken,
+			output:      "yes",
+			answer:      "YES",
+			wantStatus:  "WA",
+			wantMessage: "token 1 differs: expected YES, got yes",
+		},
+		{
+			name:       "token ignore case",
+			checker:    CheckerTokenICase,
+			output:     "yes No",
+			answer:     "YES no",
+			wantStatus: "AC",
+		},
+		{
+			name:       "float within absolute error",
+			checker:    CheckerFloat,
+			epsilon:    1e-3,
+			output:     "3.1415 case",
+			answer:     "3.14159 case",
+			wantStatus: "AC",
+		},
+		{
+			name:       "float within relative error",
+			checker:    CheckerFloat,
+			epsilon:    1e-6,
+			output:     "1000000.5",
+			answer:     "1000000",
+			wantStatus: "AC",
+		},
+		{
+			name:        "float out of error",
+			checker:     CheckerFloat,
+			epsilon:     1e-6,
+			output:      "0.5",
+			answer:      "0.4",
+			wantStatus:  "WA",
+			wantMessage: "token 1 differs: expected 0.4, got 0.5 (error 0.1)",
+		},
+		{
+			name:        "float not a number",
+			checker:     CheckerFloat,
+			output:      "nan",
+			answer:      "1.0",
+			wantStatus:  "WA",
+			wantMessage: "token 1: expected number 1.0, got nan",
+		},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(dir, "out")
+			ans := filepath.Join(dir, "ans")
+			if err := os.WriteFile(out, []byte(tt.output), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(ans, []byte(tt.answer), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			checker, err := NewChecker(tt.checker, tt.epsilon)
+			if err != nil {
+				t.Fatalf("NewChecker() error = %v", err)
+			}
+			res, err := checker.Check("", out, ans)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s (%s)", res.Status, tt.wantStatus, res.Message)
+			}
+			if res.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestNewCheckerInvalid(t *testing.T) {
+	if _, err := NewChecker("regex", 0); err == nil {
+		t.Error("expected error for unknown checker")
+	}
+	if _, err := NewChecker(CheckerFloat, -1); err == nil {
+		t.Error("expected error for negative epsilon")
+	}
+}
+
+func TestParseTestlibResult(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        int
+		output      string
+		wantStatus  string
+		wantScore   float64
+		wantMessage string
+		wantErr     bool
+	}{
+		{name: "ok", code: 0, output: "ok 3 numbers\n", wantStatus: "AC", wantScore: 1, wantMessage: "ok 3 numbers"},
+		{name: "wrong answer", code: 1, output: "wrong answer expected 3, found 4", wantStatus: "WA", wantMessage: "wrong answer expected 3, found 4"},
+		{name: "presentation error", code: 2, output: "wrong output format", wantStatus: "PE", wantMessage: "wrong output format"},
+		{name: "unexpected eof", code: 8, output: "unexpected eof", wantStatus: "WA", wantMessage: "unexpected eof"},
+		{name: "fail", code: 3, output: "answer is wrong", wantErr: true},
+		{name: "partially correct", code: 16 + 40, output: "partially correct", wantStatus: "PC", wantScore: 0.4, wantMessage: "partially correct"},
+		{name: "partially zero", code: 16, output: "nothing", wantStatus: "WA", wantMessage: "nothing"},
+		{name: "points", code: 7, output: "points 0.25 two of eight", wantStatus: "PC", wantScore: 0.25, wantMessage: "two of eight"},
+		{name: "points full", code: 7, output: "points 1", wantStatus: "AC", wantScore: 1},
+		{name: "points malformed", code: 7, output: "half", wantErr: true},
+		{name: "unknown code", code: 42 + 100, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := parseTestlibResult(tt.code, tt.output)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTestlibResult() error = %v", err)
+			}
+			if res.Status != tt.wantStatus || res.Score != tt.wantScore || res.Message != tt.wantMessage {
+				t.Errorf("got %+v, want {%s %q %v}", res, tt.wantStatus, tt.wantMessage, tt.wantScore)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/sandbox"
+)
+
+const (
+	defaultCompileMemoryMB = 512
+	defaultCompileTimeout  = 10 * time.Second
+
+	compileOutputLimit = 128 << 20 // 编译产物和日志的单文件上限
+	compilePidsLimit   = 128       // 编译器会派生子进程，JVM 线程也计入 pids
+	compileTmpSize     = 256 << 20
+
+	// CompileInfo 只保存日志开头部分，完整日志在 MinIO
+	compileInfoLimit = 4096
+)
+
+// compileResult 编译结果
+type compileResult struct {
+	Success bool
+	Message string // 失败原因（超时、内存超限等）
+	Log     []byte // 编译器 stdout + stderr
+}
+
+// compile 编译选手代码，配置了产物缓存时相同代码和语言配置只编译一次
+func (s *JudgeService) compile(task *queue.JudgeTask, workspace string) (*compileResult, error) {
+	key := artifactKey(task, s.toolchains[task.Language.Slug])
+	if s.artifacts == nil || key == "" || s.runner == nil {
+		return s.compileSource(task.Language, workspace)
+	}
+
+	box := filepath.Join(workspace, "box")
+	hit, err := s.artifacts.Restore(key, box, s.runner.UID(), s.runner.GID())
+	if err != nil {
+		log.Printf("Task %s: failed to restore artifact: %v", task.SubmitID, err)
+	} else if hit {
+		return &compileResult{Success: true}, nil
+	}
+
+	cr, err := s.compileSource(task.Language, workspace)
+	if err == nil && cr.Success {
+		if err := s.artifacts.Store(key, box); err != nil {
+			log.Printf("Task %s: failed to cache artifact: %v", task.SubmitID, err)
+		}
+	}
+	return cr, err
+}
+
+// compileSource 在沙箱中对 workspace/box 执行 Language.CompileCmd。
+// 编译失败不算错误，体现在 compileResult.Success；只有沙箱故障返回 error。
+func (s *JudgeService) compileSource(lang queue.Language, workspace string) (*compileResult, error) {
+	// 解释型语言无需编译
+	if strings.TrimSpace(lang.CompileCmd) == "" {
+		return &compileResult{Success: true}, nil
+	}
+	if s.runner == nil {
+		return nil, fmt.Errorf("sandbox not configured")
+	}
+
+	args, err := parseCommand(lang.CompileCmd)
+	if err != nil {
+		return nil, terminal(fmt.Errorf("invalid compile command: %w", err))
+	}
+
+	logPath := filepath.Join(workspace, "compile.log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compile log: %w", err)
+	}
+	defer logFile.Close()
+
+	timeout := time.Duration(lang.CompileTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultCompileTimeout
+	}
+
+	res, err := s.runner.Run(context.Background(), &sandbox.Config{
+		Args:   args,
+		Dir:    sandboxWorkDir,
+		Stdout: logFile,
+		Stderr: logFile,
+		Mounts: []sandbox.Mount{
+			{Source: filepath.Join(workspace, "box"), Target: sandboxWorkDir},
+		},
+		TimeLimit:     timeout,
+		WallTimeLimit: 2 * timeout,
+		MemoryLimit:   s.compileMemoryLimit,
+		OutputLimit:   compileOutputLimit,
+		PidsLimit:     compilePidsLimit,
+		TmpSize:       compileTmpSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("compile sandbox: %w", err)
+	}
+
+	output, err := os.ReadFile(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compile log: %w", err)
+	}
+
+	result := &compileResult{Success: res.Status == sandbox.StatusOK, Log: output}
+	switch res.Status {
+	case sandbox.StatusTimeLimitExceeded:
+		result.Message = "Compile time limit exceeded"
+	case sandbox.StatusMemoryLimitExceeded:
+		result.Message = "Compiler memory limit exceeded"
+	case sandbox.StatusOutputLimitExceeded:
+		result.Message = "Compile output too large"
+	case sandbox.StatusRuntimeError:
+		result.Message = "Compile error"
+	}
+	return result, nil
+}
+
+// info 展示给用户的编译信息：失败原因 + 编译器输出
+func (cr *compileResult) info() string {
+	if cr.Message != "" && cr.Message != "Compile error" {
+		return cr.Message + "\n" + string(cr.Log)
+	}
+	return string(cr.Log)
+}
+
+// compileErrorResult 编译失败时的评测结果：所有测试点记为 CE
+func (s *JudgeService) compileErrorResult(task *queue.JudgeTask, totalTest int, cr *compileResult) *queue.JudgeResult {
+	cases := make([]queue.TestCase, totalTest)
+	for i := range cases {
+		cases[i] = queue.TestCase{ID: i + 1, Status: "CE"}
+	}
+
+	firstFailed := 0
+	if totalTest > 0 {
+		firstFailed = 1
+	}
+	return &queue.JudgeResult{
+		Status:        queue.StatusDone,
+		Verdict:       queue.VerdictCE,
+		FirstFailed:   firstFailed,
+		TotalTest:     totalTest,
+		Cases:         cases,
+		Error:         cr.Message,
+		CompileInfo:   truncateLog(cr.info(), compileInfoLimit),
+		CompileLogURL: s.uploadLog(task.SubmitID, "compile.log", cr.Log),
+		FinishTime:    timePtr(time.Now()),
+	}
+}
+
+// uploadLog 上传日志到 MinIO，返回 minio://bucket/key；失败时返回空串
+func (s *JudgeService) uploadLog(submitID, filename string, content []byte) string {
+	if s.minioClient == nil {
+		return ""
+	}
+	key := fmt.Sprintf("submissions/%s/%s", submitID, filename)
+	_, err := s.minioClient.PutObject(
+		context.Background(),
+		s.bucketName,
+		key,
+		bytes.NewReader(content),
+		int64(len(content)),
+		minio.PutObjectOptions{ContentType: "text/plain"},
+	)
+	if err != nil {
+		log.Printf("Failed to upload %s for %s: %v", filename, submitID, err)
+		return ""
+	}
+	return fmt.Sprintf("minio://%s/%s", s.bucketName, key)
+}
+
+// truncateLog 截断日志并保证结果是合法 UTF-8
+func truncateLog(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return strings.ToValidUTF8(s[:limit], "") + "\n... (truncated)"
+}
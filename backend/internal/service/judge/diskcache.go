@@ -0,0 +1,180 @@
+package judge
+
+import (
+	"container/list"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskLRU 以 sha256 为键的本地目录缓存，测试数据和编译产物共用。
+// 每个键对应 dir 下的一个目录，总大小受 LRU 约束；使用中的条目不会被淘汰。
+type diskLRU struct {
+	dir      string
+	maxBytes int64
+	name     string // 日志和错误信息中的缓存名称
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	lru     *list.List // 表头最近使用
+	size    int64
+}
+
+type cacheEntry struct {
+	hash  string
+	dir   string
+	size  int64
+	refs  int
+	stale bool
+	elem  *list.Element
+}
+
+// newDiskLRU 创建缓存目录并接管其中已有的缓存数据
+func newDiskLRU(dir string, maxBytes int64, name string) (*diskLRU, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s cache dir: %w", name, err)
+	}
+	c := &diskLRU{
+		dir:      dir,
+		maxBytes: maxBytes,
+		name:     name,
+		entries:  make(map[string]*cacheEntry),
+		lru:      list.New(),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load 扫描缓存目录，清理半成品，按修改时间恢复 LRU 顺序
+func (c *diskLRU) load() error {
+	items, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s cache dir: %w", c.name, err)
+	}
+
+	type found struct {
+		entry *cacheEntry
+		mtime time.Time
+	}
+	var all []found
+	for _, item := range items {
+		path := filepath.Join(c.dir, item.Name())
+		if strings.HasPrefix(item.Name(), ".tmp-") || !item.IsDir() || !isSHA256(item.Name()) {
+			os.RemoveAll(path)
+			continue
+		}
+		info, err := item.Info()
+		if err != nil {
+			continue
+		}
+		all = append(all, found{
+			entry: &cacheEntry{hash: item.Name(), dir: path, size: dirSize(path)},
+			mtime: info.ModTime(),
+		})
+	}
+
+	// 旧的放在队尾
+	sort.Slice(all, func(i, j int) bool { return all[i].mtime.After(all[j].mtime) })
+	for _, f := range all {
+		f.entry.elem = c.lru.PushBack(f.entry)
+		c.entries[f.entry.hash] = f.entry
+		c.size += f.entry.size
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+
+	log.Printf("Loaded %s cache: %d entries, %d bytes", c.name, len(c.entries), c.size)
+	return nil
+}
+
+// acquire 取得条目并增加引用，使用完毕调用 release。未缓存时返回 false
+func (c *diskLRU) acquire(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.touch(key)
+}
+
+// touch 增加条目引用并移到表头（调用方持有锁）
+func (c *diskLRU) touch(key string) (*cacheEntry, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e.refs++
+	c.lru.MoveToFront(e.elem)
+	return e, true
+}
+
+func (c *diskLRU) release(e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.refs--
+	if e.refs == 0 && e.stale {
+		c.remove(e)
+	}
+	c.evict()
+}
+
+// add 把准备好的临时目录改名为 key 的缓存条目并放在表头，随后淘汰超出上限的旧条目；
+// 条目已存在时丢弃临时目录
+func (c *diskLRU) add(key, tmp string, size int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		os.RemoveAll(tmp)
+		return nil
+	}
+	target := filepath.Join(c.dir, key)
+	if err := os.Rename(tmp, target); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	e := &cacheEntry{hash: key, dir: target, size: size}
+	e.elem = c.lru.PushFront(e)
+	c.entries[key] = e
+	c.size += size
+	c.evict()
+	return nil
+}
+
+// evict 从队尾淘汰未被使用的条目，直到总大小不超过上限（调用方持有锁）。
+// 表头是刚加入或刚使用的条目，不淘汰，否则超过上限的单个条目加入后会立即被删掉。
+func (c *diskLRU) evict() {
+	for elem := c.lru.Back(); elem != nil && elem != c.lru.Front() && c.size > c.maxBytes; {
+		prev := elem.Prev()
+		if e := elem.Value.(*cacheEntry); e.refs == 0 {
+			c.remove(e)
+		}
+		elem = prev
+	}
+}
+
+// remove 删除条目及其目录（调用方持有锁）
+func (c *diskLRU) remove(e *cacheEntry) {
+	if _, ok := c.entries[e.hash]; !ok {
+		return
+	}
+	delete(c.entries, e.hash)
+	c.lru.Remove(e.elem)
+	c.size -= e.size
+
+	// 先改名再后台删除，不在锁内做大量 IO
+	trash := filepath.Join(c.dir, ".tmp-evict-"+e.hash)
+	if err := os.Rename(e.dir, trash); err != nil {
+		log.Printf("Failed to evict %s %s: %v", c.name, e.hash, err)
+		return
+	}
+	go os.RemoveAll(trash)
+}
@@ -0,0 +1,263 @@
+package judge
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/sandbox"
+)
+
+// maxGenCommands 生成脚本的行数上限
+const maxGenCommands = 500
+
+var generatorNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// GeneratorConfig Problem.Generators 中的一项
+type GeneratorConfig struct {
+	Name string `json:"name"`
+	Lang string `json:"lang"`
+	Code string `json:"code"`
+}
+
+// ParseGenerators 解析并校验 Problem.Generators，未配置时返回 nil
+func ParseGenerators(raw string) ([]GeneratorConfig, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return nil, nil
+	}
+	var gens []GeneratorConfig
+	if err := json.Unmarshal([]byte(raw), &gens); err != nil {
+		return nil, fmt.Errorf("invalid generators: %w", err)
+	}
+	seen := make(map[string]bool, len(gens))
+	for i, g := range gens {
+		if !generatorNamePattern.MatchString(g.Name) {
+			return nil, fmt.Errorf("generator #%d: invalid name %q", i+1, g.Name)
+		}
+		if seen[g.Name] {
+			return nil, fmt.Errorf("generator %s: duplicate name", g.Name)
+		}
+		if strings.TrimSpace(g.Code) == "" {
+			return nil, fmt.Errorf("generator %s: code is required", g.Name)
+		}
+		seen[g.Name] = true
+	}
+	return gens, nil
+}
+
+// GenCommand 生成脚本的一行：运行生成器，输出作为第 Test 个测试点的输入
+type GenCommand struct {
+	Generator string
+	Args      []string
+	Test      int
+}
+
+// ParseGenScript 解析生成脚本，每行形如 "gen 10 5 > 3"，空行和 # 开头的行忽略。
+// 参数按空白分割，不支持引号和其他 shell 语法；测试点编号不能重复。
+func ParseGenScript(script string) ([]GenCommand, error) {
+	var cmds []GenCommand
+	seen := make(map[int]bool)
+	for i, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmdPart, target, ok := strings.Cut(line, ">")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing \"> test\"", i+1)
+		}
+		test, err := strconv.Atoi(strings.TrimSpace(target))
+		if err != nil || test <= 0 {
+			return nil, fmt.Errorf("line %d: invalid test number %q", i+1, strings.TrimSpace(target))
+		}
+		if seen[test] {
+			return nil, fmt.Errorf("line %d: test %d generated twice", i+1, test)
+		}
+		fields := strings.Fields(cmdPart)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("line %d: missing generator", i+1)
+		}
+		seen[test] = true
+		cmds = append(cmds, GenCommand{Generator: fields[0], Args: fields[1:], Test: test})
+	}
+	if len(cmds) == 0 {
+		return nil, fmt.Errorf("script generates no tests")
+	}
+	if len(cmds) > maxGenCommands {
+		return nil, fmt.Errorf("script has more than %d tests", maxGenCommands)
+	}
+	return cmds, nil
+}
+
+// GenerateTestData 按脚本运行生成器得到输入、用标程得到答案，打包上传到测试数据 bucket。
+// 脚本、生成器或标程本身的问题返回 FAILED 结果，只有评测系统故障才返回错误以便重试。
+func (s *JudgeService) GenerateTestData(task *queue.GenerateTask) (*queue.GenerateResult, error) {
+	cmds, err := ParseGenScript(task.Script)
+	if err != nil {
+		return generateFailed(err.Error()), nil
+	}
+
+	gens := make(map[string]*program, len(task.Generators))
+	for _, g := range task.Generators {
+		prog, cr, err := s.loadProgram(&g.SPJ)
+		if err != nil {
+			return nil, err
+		}
+		if cr != nil {
+			return generateFailed(fmt.Sprintf("generator %s compile error: %s", g.Name, truncateLog(cr.info(), compileInfoLimit))), nil
+		}
+		gens[g.Name] = prog
+	}
+	std, cr, err := s.loadProgram(&task.Std)
+	if err != nil {
+		return nil, err
+	}
+	if cr != nil {
+		return generateFailed("std compile error: " + truncateLog(cr.info(), compileInfoLimit)), nil
+	}
+
+	workspace, err := s.createWorkspace("gen-"+strconv.FormatInt(task.ProblemID, 10), queue.Language{}, "")
+	if err != nil {
+		return nil, err
+	}
+	defer s.cleanup(workspace)
+	dir := filepath.Join(workspace, "tests")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tests dir: %w", err)
+	}
+
+	for _, cmd := range cmds {
+		gen, ok := gens[cmd.Generator]
+		if !ok {
+			return generateFailed(fmt.Sprintf("test %d: unknown generator %s", cmd.Test, cmd.Generator)), nil
+		}
+		input := filepath.Join(dir, fmt.Sprintf("%d.in", cmd.Test))
+		msg, err := s.runGenerator(gen, cmd.Args, input)
+		if err != nil {
+			return nil, err
+		}
+		if msg != "" {
+			return generateFailed(fmt.Sprintf("test %d: generator %s %s", cmd.Test, cmd.Generator, msg)), nil
+		}
+		answer := filepath.Join(dir, fmt.Sprintf("%d.out", cmd.Test))
+		if err := s.generateAnswer(std, input, answer); err != nil {
+			return generateFailed(fmt.Sprintf("test %d: %s", cmd.Test, truncateLog(err.Error(), compileInfoLimit))), nil
+		}
+	}
+
+	location, hash, err := s.uploadTestData(task.ProblemID, dir, filepath.Join(workspace, "testdata.zip"))
+	if err != nil {
+		return nil, err
+	}
+	return &queue.GenerateResult{
+		Status:     queue.GenerateStatusDone,
+		Tests:      len(cmds),
+		Location:   location,
+		Hash:       hash,
+		FinishTime: timePtr(time.Now()),
+	}, nil
+}
+
+// runGenerator 运行生成器把输出写到 output。生成器异常退出时返回非空的说明。
+func (s *JudgeService) runGenerator(gen *program, args []string, output string) (string, error) {
+	out, err := os.Create(output)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	stderr := &limitedBuffer{limit: spjMessageLimit}
+	res, err := s.runProgram(gen, args, nil, out, stderr)
+	if err != nil {
+		return "", fmt.Errorf("generator %w", err)
+	}
+	if res.Status != sandbox.StatusOK {
+		return fmt.Sprintf("%s: %s", res.Status, strings.TrimSpace(stderr.String())), nil
+	}
+	return "", out.Chmod(0644)
+}
+
+// uploadTestData 把目录打包成 zip 上传到 problems/{id}/{hash}.zip，返回 minio:// 形式的位置和哈希
+func (s *JudgeService) uploadTestData(problemID int64, dir, zipPath string) (string, string, error) {
+	if s.minioClient == nil || s.testData == nil {
+		return "", "", fmt.Errorf("minio not configured")
+	}
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if err := zipDir(dir, io.MultiWriter(f, h)); err != nil {
+		return "", "", fmt.Errorf("failed to pack test data: %w", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	info, err := f.Stat()
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testDataFetchTimeout)
+	defer cancel()
+	key := fmt.Sprintf("problems/%d/%s.zip", problemID, hash)
+	_, err = s.minioClient.PutObject(ctx, s.testData.bucket, key, f, info.Size(), minio.PutObjectOptions{
+		ContentType: "application/zip",
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to upload test data: %w", err)
+	}
+	return fmt.Sprintf("minio://%s/%s", s.testData.bucket, key), hash, nil
+}
+
+// zipDir 把目录下的文件（不含子目录）按文件名顺序打包
+func zipDir(dir string, w io.Writer) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		fw, err := zw.Create(e.Name())
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(fw, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func generateFailed(msg string) *queue.GenerateResult {
+	return &queue.GenerateResult{
+		Status:     queue.GenerateStatusFailed,
+		Message:    msg,
+		FinishTime: timePtr(time.Now()),
+	}
+}
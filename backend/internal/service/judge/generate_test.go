@@ -0,0 +1,68 @@
+package judge
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGenScript(t *testing.T) {
+	script := `
+# 小数据
+gen 10 5 > 1
+gen 100 5 >2
+
+rand-big 100000 > 3
+`
+	cmds, err := ParseGenScript(script)
+	if err != nil {
+		t.Fatalf("ParseGenScript() error = %v", err)
+	}
+	want := []GenCommand{
+		{Generator: "gen", Args: []string{"10", "5"}, Test: 1},
+		{Generator: "gen", Args: []string{"100", "5"}, Test: 2},
+		{Generator: "rand-big", Args: []string{"100000"}, Test: 3},
+	}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("ParseGenScript() = %+v, want %+v", cmds, want)
+	}
+
+	for _, bad := range []string{
+		"",
+		"gen 10 5",
+		"gen 10 > x",
+		"gen > 0",
+		"> 1",
+		"gen 1 > 1\ngen 2 > 1",
+	} {
+		if _, err := ParseGenScript(bad); err == nil {
+			t.Errorf("ParseGenScript(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseGenerators(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantLen int
+		wantErr bool
+	}{
+		{name: "empty", raw: ""},
+		{name: "valid", raw: `[{"name":"gen","lang":"cpp17","code":"int main(){}"},{"name":"rand_big","lang":"cpp17","code":"x"}]`, wantLen: 2},
+		{name: "bad name", raw: `[{"name":"gen 1","lang":"cpp17","code":"x"}]`, wantErr: true},
+		{name: "duplicate", raw: `[{"name":"gen","lang":"cpp17","code":"x"},{"name":"gen","lang":"cpp17","code":"y"}]`, wantErr: true},
+		{name: "no code", raw: `[{"name":"gen","lang":"cpp17"}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gens, err := ParseGenerators(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGenerators() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(gens) != tt.wantLen {
+				t.Errorf("len = %d, want %d", len(gens), tt.wantLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package judge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// hack 得分，沿用 Codeforces 规则
+const (
+	HackSuccessPoints = 100
+	HackFailedPoints  = -50
+
+	hackMessageLimit = 400 // 结论说明的上限，给截断标记留出余量
+)
+
+// ProcessHack 处理 hack：校验输入，用标程生成答案，再用该输入评测目标提交。
+// 题目配置问题和不合法的输入直接给出结论，只有评测系统故障才返回错误以便重试。
+func (s *JudgeService) ProcessHack(task *queue.HackTask) (*queue.HackResult, error) {
+	result := &queue.HackResult{HackID: task.HackID}
+	p := &task.Problem
+	if p.Validator == nil || p.Std == nil {
+		result.Verdict = queue.HackError
+		result.Message = "problem has no validator or reference solution"
+		return result, nil
+	}
+	if p.IsInteractive {
+		result.Verdict = queue.HackError
+		result.Message = "interactive problems cannot be hacked"
+		return result, nil
+	}
+
+	// 1. 工作目录，box 中是目标提交的源码
+	id := "hack-" + strconv.FormatInt(task.HackID, 10)
+	workspace, err := s.createWorkspace(id, task.Language, task.Code)
+	if err != nil {
+		return nil, err
+	}
+	defer s.cleanup(workspace)
+
+	dir := filepath.Join(workspace, "hack")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create hack dir: %w", err)
+	}
+	t := testFile{Input: filepath.Join(dir, "1.in"), Answer: filepath.Join(dir, "1.out")}
+	if err := os.WriteFile(t.Input, []byte(task.Input), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write hack input: %w", err)
+	}
+
+	// 2. 校验输入
+	validator, cr, err := s.loadProgram(p.Validator)
+	if err != nil {
+		return nil, err
+	}
+	if cr != nil {
+		result.Verdict = queue.HackError
+		result.Message = "validator compile error: " + truncateLog(cr.info(), hackMessageLimit)
+		return result, nil
+	}
+	ok, msg, err := s.validateInput(validator, t.Input)
+	if err != nil {
+		result.Verdict = queue.HackError
+		result.Message = truncateLog(err.Error(), hackMessageLimit)
+		return result, nil
+	}
+	if !ok {
+		result.Verdict = queue.HackInvalid
+		result.Message = truncateLog(msg, hackMessageLimit)
+		return result, nil
+	}
+
+	// 3. 标程生成答案
+	std, cr, err := s.loadProgram(p.Std)
+	if err != nil {
+		return nil, err
+	}
+	if cr != nil {
+		result.Verdict = queue.HackError
+		result.Message = "std compile error: " + truncateLog(cr.info(), hackMessageLimit)
+		return result, nil
+	}
+	if err := s.generateAnswer(std, t.Input, t.Answer); err != nil {
+		result.Verdict = queue.HackError
+		result.Message = truncateLog(err.Error(), hackMessageLimit)
+		return result, nil
+	}
+	answer, err := os.ReadFile(t.Answer)
+	if err != nil {
+		return nil, err
+	}
+	result.Answer = string(answer)
+
+	// 4. 用该输入评测目标提交
+	judgeTask := &queue.JudgeTask{
+		SubmitID: id,
+		Problem:  task.Problem,
+		Language: task.Language,
+		Code:     task.Code,
+		CodeHash: task.CodeHash,
+	}
+	run, judgeCompile, err := s.caseRunner(judgeTask, workspace)
+	if err != nil {
+		return nil, err
+	}
+	if judgeCompile != nil {
+		result.Verdict = queue.HackError
+		result.Message = "special judge compile error: " + truncateLog(judgeCompile.info(), hackMessageLimit)
+		return result, nil
+	}
+	compiled, err := s.compile(judgeTask, workspace)
+	if err != nil {
+		return nil, err
+	}
+	if !compiled.Success {
+		// 已通过的提交编译失败只能是环境变化，不算 hack 成功
+		result.Verdict = queue.HackError
+		result.Message = "target compile error"
+		return result, nil
+	}
+
+	tc, _, err := run(t)
+	if err != nil {
+		return nil, err
+	}
+	return hackVerdict(result, tc), nil
+}
+
+// hackVerdict 根据目标提交在 hack 输入上的结果给出结论
+func hackVerdict(result *queue.HackResult, tc queue.TestCase) *queue.HackResult {
+	verdict, failed := caseVerdict(tc.Status)
+	switch {
+	case !failed:
+		result.Verdict = queue.HackFailed
+		result.TargetVerdict = queue.VerdictAC
+		result.Points = HackFailedPoints
+	case verdict == queue.VerdictSE:
+		result.Verdict = queue.HackError
+		result.TargetVerdict = verdict
+		result.Message = "judge error"
+	default:
+		result.Verdict = queue.HackSuccess
+		result.TargetVerdict = verdict
+		result.Message = truncateLog(tc.Message, hackMessageLimit)
+		result.Points = HackSuccessPoints
+	}
+	return result
+}
@@ -0,0 +1,31 @@
+package judge
+
+import (
+	"testing"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+func TestHackVerdict(t *testing.T) {
+	tests := []struct {
+		status      string
+		wantVerdict string
+		wantTarget  string
+		wantPoints  int
+	}{
+		{status: "AC", wantVerdict: queue.HackFailed, wantTarget: queue.VerdictAC, wantPoints: HackFailedPoints},
+		{status: "WA", wantVerdict: queue.HackSuccess, wantTarget: queue.VerdictWA, wantPoints: HackSuccessPoints},
+		{status: "TLE", wantVerdict: queue.HackSuccess, wantTarget: queue.VerdictTLE, wantPoints: HackSuccessPoints},
+		{status: "PC", wantVerdict: queue.HackSuccess, wantTarget: queue.VerdictPartial, wantPoints: HackSuccessPoints},
+		{status: "SE", wantVerdict: queue.HackError, wantTarget: queue.VerdictSE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			got := hackVerdict(&queue.HackResult{HackID: 1}, queue.TestCase{Status: tt.status})
+			if got.Verdict != tt.wantVerdict || got.TargetVerdict != tt.wantTarget || got.Points != tt.wantPoints {
+				t.Errorf("hackVerdict(%s) = %+v, want %s/%s/%d", tt.status, got, tt.wantVerdict, tt.wantTarget, tt.wantPoints)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package judge
+
+import (
+	"context"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// toolchainProbeTimeout 探测单个编译器/解释器版本的超时
+const toolchainProbeTimeout = 5 * time.Second
+
+// setSlot 记录槽位正在评测的任务，task 为 nil 表示空闲
+func (p *WorkerPool) setSlot(index int, task *queue.JudgeTask) {
+	p.slotsMu.Lock()
+	defer p.slotsMu.Unlock()
+	if task == nil {
+		p.slots[index] = queue.WorkerSlot{Index: index}
+		return
+	}
+	now := time.Now()
+	p.slots[index] = queue.WorkerSlot{
+		Index:     index,
+		SubmitID:  task.SubmitID,
+		ProblemID: task.Problem.ID,
+		Language:  task.Language.Slug,
+		StartedAt: &now,
+	}
+}
+
+// Slots 各槽位当前状态的快照和忙碌的槽位数
+func (p *WorkerPool) Slots() ([]queue.WorkerSlot, int) {
+	p.slotsMu.Lock()
+	defer p.slotsMu.Unlock()
+	slots := make([]queue.WorkerSlot, len(p.slots))
+	copy(slots, p.slots)
+	busy := 0
+	for _, slot := range slots {
+		if slot.SubmitID != "" {
+			busy++
+		}
+	}
+	return slots, busy
+}
+
+// Heartbeat 按 queue.HeartbeatInterval 上报心跳，ctx 取消后删除心跳记录。
+// info 中的静态字段由调用方填好，槽位和运行时长每次上报时刷新。
+func (p *WorkerPool) Heartbeat(ctx context.Context, kv jetstream.KeyValue, info queue.WorkerInfo) {
+	ticker := time.NewTicker(queue.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		info.Slots, info.Busy = p.Slots()
+		info.UptimeSec = int64(now.Sub(info.StartedAt).Seconds())
+		info.UpdatedAt = now
+		if err := queue.PutHeartbeat(ctx, kv, &info); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to put heartbeat: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			deleteCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := queue.RemoveWorker(deleteCtx, kv, info.ID); err != nil {
+				log.Printf("Failed to remove heartbeat: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ClassLanguages 选出属于 class 队列、且所需能力都在 capabilities 中的语言。
+// 第二个返回值为同一队列中因缺少能力而跳过的语言及其缺少的能力，供启动时告警。
+func ClassLanguages(langs []model.Language, class string, capabilities []string) ([]queue.Language, map[string][]string) {
+	if class == "" {
+		class = queue.DefaultQueueClass
+	}
+	has := make(map[string]bool, len(capabilities))
+	for _, c := range capabilities {
+		has[c] = true
+	}
+
+	matched := make([]queue.Language, 0, len(langs))
+	missing := map[string][]string{}
+	for _, lang := range langs {
+		langClass := lang.QueueClass
+		if langClass == "" {
+			langClass = queue.DefaultQueueClass
+		}
+		if langClass != class {
+			continue
+		}
+		var lack []string
+		for _, c := range lang.Capabilities {
+			if !has[c] {
+				lack = append(lack, c)
+			}
+		}
+		if len(lack) > 0 {
+			missing[lang.Slug] = lack
+			continue
+		}
+		matched = append(matched, queue.Language{Slug: lang.Slug, CompileCmd: lang.CompileCmd, RunCmd: lang.RunCmd})
+	}
+	return matched, missing
+}
+
+// ProbeToolchains 探测本机可用的语言及其编译器/解释器版本。
+// 以编译命令（解释型语言为运行命令）的第一个参数作为工具链，找不到的语言视为不可用。
+func ProbeToolchains(langs []queue.Language) ([]string, map[string]string) {
+	available := make([]string, 0, len(langs))
+	versions := make(map[string]string, len(langs))
+	for _, lang := range langs {
+		cmd := lang.CompileCmd
+		if strings.TrimSpace(cmd) == "" {
+			cmd = lang.RunCmd
+		}
+		args, err := parseCommand(cmd)
+		if err != nil {
+			continue
+		}
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			continue
+		}
+		available = append(available, lang.Slug)
+		versions[lang.Slug] = toolchainVersion(path)
+	}
+	return available, versions
+}
+
+// unknownToolchain 探测不到版本时的占位
+const unknownToolchain = "unknown"
+
+// toolchainVersion 取 --version（go 等工具为 version）输出的第一行
+func toolchainVersion(path string) string {
+	for _, arg := range []string{"--version", "version"} {
+		ctx, cancel := context.WithTimeout(context.Background(), toolchainProbeTimeout)
+		out, err := exec.CommandContext(ctx, path, arg).CombinedOutput()
+		cancel()
+		if err != nil {
+			continue
+		}
+		if line := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0]); line != "" {
+			return line
+		}
+	}
+	return unknownToolchain
+}
@@ -0,0 +1,45 @@
+package judge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+func TestClassLanguages(t *testing.T) {
+	langs := []model.Language{
+		{Slug: "cpp17", QueueClass: "heavy"},
+		{Slug: "java17", QueueClass: "heavy", Capabilities: model.StringArray{"jvm"}},
+		{Slug: "python3"},
+		{Slug: "pypy3", QueueClass: "light", Capabilities: model.StringArray{"pypy"}},
+	}
+
+	matched, missing := ClassLanguages(langs, "heavy", nil)
+	if got := slugs(matched); !reflect.DeepEqual(got, []string{"cpp17"}) {
+		t.Fatalf("heavy without capabilities = %v", got)
+	}
+	if !reflect.DeepEqual(missing, map[string][]string{"java17": {"jvm"}}) {
+		t.Fatalf("missing = %v", missing)
+	}
+
+	matched, missing = ClassLanguages(langs, "heavy", []string{"jvm"})
+	if got := slugs(matched); !reflect.DeepEqual(got, []string{"cpp17", "java17"}) || len(missing) != 0 {
+		t.Fatalf("heavy with jvm = %v, missing %v", got, missing)
+	}
+
+	// 未配置队列类别的语言归入 light
+	matched, _ = ClassLanguages(langs, "", []string{"pypy"})
+	if got := slugs(matched); !reflect.DeepEqual(got, []string{"python3", "pypy3"}) {
+		t.Fatalf("light = %v", got)
+	}
+}
+
+func slugs(langs []queue.Language) []string {
+	out := make([]string, 0, len(langs))
+	for _, l := range langs {
+		out = append(out, l.Slug)
+	}
+	return out
+}
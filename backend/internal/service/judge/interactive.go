@@ -0,0 +1,156 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/sandbox"
+)
+
+// runInteractiveCase 运行交互题的单个测试点。
+// 选手程序与交互器分别在各自的沙箱中运行，标准输入输出经两条管道交叉相连：
+//
+//	选手 stdout → 交互器 stdin，交互器 stdout → 选手 stdin
+//
+// 交互器按 testlib 约定调用：interactor <input> <output> <answer>，结果由其退出码决定。
+// 与 runTestCase 相同，基础设施故障返回 error，交互器自身出错记为该测试点 SE。
+func (s *JudgeService) runInteractiveCase(task *queue.JudgeTask, workspace string, args []string, t testFile, inter *program) (queue.TestCase, float64, error) {
+	tc := queue.TestCase{
+		InputFile:  filepath.Base(t.Input),
+		OutputFile: filepath.Base(t.Answer),
+	}
+	if s.runner == nil {
+		return tc, 0, fmt.Errorf("sandbox not configured")
+	}
+
+	// 交互器写出的记录（testlib 的 tout），需以沙箱用户身份可写
+	outPath := filepath.Join(workspace, "out", strings.TrimSuffix(filepath.Base(t.Input), ".in")+".out")
+	if err := createOwnedFile(outPath, s.runner.UID(), s.runner.GID()); err != nil {
+		return tc, 0, fmt.Errorf("failed to create interactor output %s: %w", outPath, err)
+	}
+
+	// 父进程持有的四个管道端在返回时统一关闭（os.File 重复 Close 无副作用）；
+	// AfterStart 只负责在子进程启动后尽早关闭，让对端能读到 EOF
+	toInterR, toInterW, err := os.Pipe()
+	if err != nil {
+		return tc, 0, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer toInterR.Close()
+	defer toInterW.Close()
+	toSolR, toSolW, err := os.Pipe()
+	if err != nil {
+		return tc, 0, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer toSolR.Close()
+	defer toSolW.Close()
+
+	solCfg := runConfig(task, workspace, args)
+	solCfg.Stdin = toSolR
+	solCfg.Stdout = toInterW
+	solCfg.AfterStart = func() {
+		toSolR.Close()
+		toInterW.Close()
+	}
+
+	msg := &limitedBuffer{limit: spjMessageLimit}
+	interCfg := &sandbox.Config{
+		Args:   inter.withArgs("/judge/input", "/judge/output", "/judge/answer"),
+		Dir:    sandboxWorkDir,
+		Stdin:  toInterR,
+		Stdout: toSolW,
+		Stderr: msg,
+		Mounts: []sandbox.Mount{
+			{Source: inter.box, Target: sandboxWorkDir, ReadOnly: true},
+			{Source: t.Input, Target: "/judge/input", ReadOnly: true},
+			{Source: outPath, Target: "/judge/output"},
+			{Source: t.Answer, Target: "/judge/answer", ReadOnly: true},
+		},
+		// 交互器与选手程序分别计时，交互器使用 SPJ 的限制
+		TimeLimit:   spjTimeLimit,
+		MemoryLimit: spjMemoryLimit,
+		OutputLimit: spjOutputLimit,
+		PidsLimit:   spjPidsLimit,
+		AfterStart: func() {
+			toInterR.Close()
+			toSolW.Close()
+		},
+	}
+
+	var (
+		wg               sync.WaitGroup
+		solRes, interRes *sandbox.Result
+		solErr, interErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		solRes, solErr = s.runner.Run(context.Background(), solCfg)
+	}()
+	go func() {
+		defer wg.Done()
+		interRes, interErr = s.runner.Run(context.Background(), interCfg)
+	}()
+	wg.Wait()
+
+	if solErr != nil || interErr != nil {
+		return tc, 0, fmt.Errorf("sandbox error on %s: solution: %v, interactor: %v", tc.InputFile, solErr, interErr)
+	}
+
+	tc.TimeMs = int(solRes.CPUTime.Milliseconds())
+	tc.MemoryKB = int(solRes.MemoryKB)
+
+	cr, err := interactiveVerdict(solRes, interRes, msg.String())
+	if err != nil {
+		log.Printf("Task %s: interactor failed on %s: %v", task.SubmitID, tc.InputFile, err)
+		tc.Status = "SE"
+		return tc, 0, nil
+	}
+	tc.Status = cr.Status
+	tc.Message = cr.Message
+	return tc, cr.Score, nil
+}
+
+// interactiveVerdict 综合选手程序和交互器的运行结果给出测试点结论：
+// 选手超时/超内存/超输出优先；选手崩溃（交互器先退出导致的 SIGPIPE 除外）判 RE；
+// 其余以交互器的 testlib 退出码为准。交互器自身故障返回 error。
+func interactiveVerdict(sol, inter *sandbox.Result, interOutput string) (*CheckResult, error) {
+	switch sol.Status {
+	case sandbox.StatusTimeLimitExceeded, sandbox.StatusMemoryLimitExceeded, sandbox.StatusOutputLimitExceeded:
+		return &CheckResult{Status: string(sol.Status)}, nil
+	}
+	solCrashed := sol.Status != sandbox.StatusOK && sol.Signal != int(syscall.SIGPIPE)
+	if solCrashed {
+		return &CheckResult{Status: string(sol.Status), Message: sol.Error}, nil
+	}
+
+	code, err := testlibExitCode(inter)
+	if err != nil {
+		return nil, err
+	}
+	cr, err := parseTestlibResult(code, interOutput)
+	if err != nil {
+		return nil, err
+	}
+	// 交互器读到 EOF：选手程序在交互完成前退出
+	if code == testlibUnexpectedEOF && sol.Status == sandbox.StatusOK {
+		cr.Message = strings.TrimSpace("solution exited before the interaction finished; " + cr.Message)
+	}
+	return cr, nil
+}
+
+// createOwnedFile 创建空文件并交给指定用户
+func createOwnedFile(path string, uid, gid int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Chown(path, uid, gid)
+}
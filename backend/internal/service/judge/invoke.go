@@ -0,0 +1,132 @@
+package judge
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// 建议时间限制：最慢的标准解用时乘以系数，向上取整到 timeLimitStep
+const (
+	timeLimitFactor = 2
+	timeLimitStep   = 100
+)
+
+// InvokeSolutions 在完整测试集上运行题目的全部参考解（不遇错即停、不按子任务计分），
+// 给出结论矩阵、与预期不符的参考解和建议的时间限制
+func (s *JudgeService) InvokeSolutions(task *queue.InvokeTask) (*queue.InvokeResult, error) {
+	if task.Problem.TestDataZip == "" || !isSHA256(task.Problem.TestDataHash) {
+		return invokeFailed(task, "problem has no test data"), nil
+	}
+	dataDir, release, err := s.testData.Acquire(task.Problem)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	tests, err := loadTestFiles(dataDir)
+	if err != nil {
+		return invokeFailed(task, err.Error()), nil
+	}
+
+	result := &queue.InvokeResult{InvocationID: task.InvocationID, Status: queue.InvokeStatusDone}
+	for _, sol := range task.Solutions {
+		run, judgeCompile, err := s.invokeSolution(task, sol, tests)
+		if err != nil {
+			return nil, err
+		}
+		if judgeCompile != nil {
+			return invokeFailed(task, "special judge compile error: "+truncateLog(judgeCompile.info(), compileInfoLimit)), nil
+		}
+		result.Runs = append(result.Runs, *run)
+	}
+	summarizeInvocation(task, result)
+	result.FinishTime = timePtr(time.Now())
+	return result, nil
+}
+
+// invokeSolution 编译并运行单个参考解。SPJ/交互器编译失败时返回非空的 compileResult。
+func (s *JudgeService) invokeSolution(task *queue.InvokeTask, sol queue.InvokeSolution, tests []testFile) (*queue.SolutionRun, *compileResult, error) {
+	jt := &queue.JudgeTask{
+		SubmitID: fmt.Sprintf("invoke-%d-%d", task.InvocationID, sol.ID),
+		Problem:  task.Problem,
+		Language: sol.Language,
+		Code:     sol.Code,
+		CodeHash: sol.CodeHash,
+	}
+	workspace, err := s.createWorkspace(jt.SubmitID, jt.Language, jt.Code)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer s.cleanup(workspace)
+
+	run, judgeCompile, err := s.caseRunner(jt, workspace)
+	if err != nil || judgeCompile != nil {
+		return nil, judgeCompile, err
+	}
+
+	sr := &queue.SolutionRun{SolutionID: sol.ID, Name: sol.Name, Expected: sol.Expected}
+	cr, err := s.compile(jt, workspace)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cr.Success {
+		sr.Verdict = queue.VerdictCE
+		sr.CompileInfo = truncateLog(cr.info(), compileInfoLimit)
+		return sr, nil, nil
+	}
+
+	sr.Cases, err = s.runTestCases(tests, run, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	sr.Verdict, _ = finalVerdict(sr.Cases)
+	for _, c := range sr.Cases {
+		sr.TimeMs = max(sr.TimeMs, c.TimeMs)
+		sr.MemoryKB = max(sr.MemoryKB, c.MemoryKB)
+	}
+	return sr, nil, nil
+}
+
+// summarizeInvocation 标记与预期不符的参考解，并按只允许 AC 的参考解中最慢的一次给出建议时间限制。
+// 用时按语言的时间系数折算回题目的基准限制。
+func summarizeInvocation(task *queue.InvokeTask, result *queue.InvokeResult) {
+	slowest := 0
+	for i := range result.Runs {
+		run := &result.Runs[i]
+		run.Matched = slices.Contains(run.Expected, run.Verdict)
+		if !run.Matched {
+			result.Mismatches++
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: expected %v, got %s", run.Name, run.Expected, run.Verdict))
+		}
+		if run.Verdict == queue.VerdictAC && slices.Equal(run.Expected, []string{queue.VerdictAC}) {
+			factor := factor(task.Solutions[i].Language.TimeFactor)
+			slowest = max(slowest, int(math.Ceil(float64(run.TimeMs)/factor)))
+		}
+	}
+	if slowest == 0 {
+		result.Warnings = append(result.Warnings, "no solution tagged AC passed, cannot suggest a time limit")
+		return
+	}
+	result.SuggestedTimeLimit = max(timeLimitStep, (slowest*timeLimitFactor+timeLimitStep-1)/timeLimitStep*timeLimitStep)
+
+	// 超时的参考解只在当前限制下验证过，建议的限制更宽时未必还会超时
+	if result.SuggestedTimeLimit > task.Problem.TimeLimit {
+		for _, run := range result.Runs {
+			if run.Verdict == queue.VerdictTLE && slices.Contains(run.Expected, queue.VerdictTLE) {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s: TLE was only verified under the current %dms limit", run.Name, task.Problem.TimeLimit))
+			}
+		}
+	}
+}
+
+func invokeFailed(task *queue.InvokeTask, msg string) *queue.InvokeResult {
+	return &queue.InvokeResult{
+		InvocationID: task.InvocationID,
+		Status:       queue.InvokeStatusFailed,
+		Message:      msg,
+		FinishTime:   timePtr(time.Now()),
+	}
+}
@@ -0,0 +1,54 @@
+package judge
+
+import (
+	"testing"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+func TestSummarizeInvocation(t *testing.T) {
+	task := &queue.InvokeTask{
+		Problem: queue.Problem{TimeLimit: 1000},
+		Solutions: []queue.InvokeSolution{
+			{Language: queue.Language{TimeFactor: 1}},
+			{Language: queue.Language{TimeFactor: 2}},
+			{Language: queue.Language{TimeFactor: 1}},
+			{Language: queue.Language{TimeFactor: 1}},
+		},
+	}
+	result := &queue.InvokeResult{Runs: []queue.SolutionRun{
+		{Name: "main", Expected: []string{"AC"}, Verdict: "AC", TimeMs: 420},
+		{Name: "java", Expected: []string{"AC"}, Verdict: "AC", TimeMs: 1100}, // 折算后 550ms
+		{Name: "brute", Expected: []string{"TLE"}, Verdict: "TLE", TimeMs: 1000},
+		{Name: "greedy", Expected: []string{"WA", "TLE"}, Verdict: "AC", TimeMs: 900}, // 不参与建议
+	}}
+
+	summarizeInvocation(task, result)
+
+	if result.SuggestedTimeLimit != 1100 {
+		t.Errorf("SuggestedTimeLimit = %d, want 1100", result.SuggestedTimeLimit)
+	}
+	if result.Mismatches != 1 || result.Runs[3].Matched || !result.Runs[2].Matched {
+		t.Errorf("Mismatches = %d, runs = %+v", result.Mismatches, result.Runs)
+	}
+	// greedy 不符合预期 + brute 的 TLE 只在当前限制下验证过
+	if len(result.Warnings) != 2 {
+		t.Errorf("Warnings = %v, want 2 entries", result.Warnings)
+	}
+}
+
+func TestSummarizeInvocationNoAccepted(t *testing.T) {
+	task := &queue.InvokeTask{
+		Problem:   queue.Problem{TimeLimit: 1000},
+		Solutions: []queue.InvokeSolution{{}},
+	}
+	result := &queue.InvokeResult{Runs: []queue.SolutionRun{
+		{Name: "main", Expected: []string{"AC"}, Verdict: "WA"},
+	}}
+
+	summarizeInvocation(task, result)
+
+	if result.SuggestedTimeLimit != 0 || result.Mismatches != 1 {
+		t.Errorf("got suggested %d, mismatches %d", result.SuggestedTimeLimit, result.Mismatches)
+	}
+}
@@ -0,0 +1,44 @@
+package judge
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// 测试点执行策略
+const (
+	ModeACM = "acm" // 遇到第一个未通过的测试点即停止，其余记为 SKIPPED
+	ModeIOI = "ioi" // 运行全部测试点，按得分给部分分
+)
+
+// ValidateJudgeMode 校验题目的执行策略，空值表示跟随比赛赛制
+func ValidateJudgeMode(mode string) error {
+	switch mode {
+	case "", ModeACM, ModeIOI:
+		return nil
+	}
+	return fmt.Errorf("unknown judge mode %q", mode)
+}
+
+// judgeMode 确定任务的执行策略：题目单独配置优先，其次是比赛赛制，
+// 练习提交默认运行全部测试点
+func judgeMode(task *queue.JudgeTask) string {
+	if task.Problem.JudgeMode != "" {
+		return task.Problem.JudgeMode
+	}
+	if c := task.Contest; c != nil {
+		rule := c.RuleType
+		if rule == "" {
+			rule = c.Type
+		}
+		switch strings.ToUpper(rule) {
+		case "ACM", "ICPC":
+			return ModeACM
+		case "IOI", "OI":
+			return ModeIOI
+		}
+	}
+	return ModeIOI
+}
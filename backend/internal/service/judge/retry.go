@@ -0,0 +1,43 @@
+package judge
+
+import (
+	"errors"
+	"time"
+)
+
+// terminalError 重试也不会成功的错误：任务或题目配置本身有问题（缺测试数据、命令写错等），
+// 直接按系统错误结束。其余错误视为基础设施故障（MinIO/数据库/沙箱不可用），交给 JetStream 延迟重投。
+type terminalError struct {
+	err error
+}
+
+func (e *terminalError) Error() string { return e.err.Error() }
+func (e *terminalError) Unwrap() error { return e.err }
+
+// terminal 把错误标记为不可重试
+func terminal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &terminalError{err: err}
+}
+
+// IsTerminal 错误是否不可重试
+func IsTerminal(err error) bool {
+	var t *terminalError
+	return errors.As(err, &t)
+}
+
+// retryDelays 第 n 次投递失败后到下一次投递的间隔，超出部分取最后一项
+var retryDelays = []time.Duration{5 * time.Second, 30 * time.Second, 2 * time.Minute}
+
+// retryDelay 已投递 delivered 次后的重试间隔
+func retryDelay(delivered int) time.Duration {
+	if delivered < 1 {
+		delivered = 1
+	}
+	if delivered > len(retryDelays) {
+		return retryDelays[len(retryDelays)-1]
+	}
+	return retryDelays[delivered-1]
+}
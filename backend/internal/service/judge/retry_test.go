@@ -0,0 +1,57 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+func TestIsTerminal(t *testing.T) {
+	base := errors.New("no test data")
+	if !IsTerminal(terminal(base)) {
+		t.Fatal("terminal error not detected")
+	}
+	if !IsTerminal(fmt.Errorf("acquire: %w", terminal(base))) {
+		t.Fatal("wrapped terminal error not detected")
+	}
+	if !errors.Is(terminal(base), base) {
+		t.Fatal("terminal error should unwrap to the cause")
+	}
+	if IsTerminal(errors.New("connection refused")) {
+		t.Fatal("plain error should be retryable")
+	}
+	if terminal(nil) != nil {
+		t.Fatal("terminal(nil) should be nil")
+	}
+}
+
+func TestProblemCheckerConfigErrors(t *testing.T) {
+	s := &JudgeService{}
+	problems := []*queue.Problem{
+		{ID: 1, Checker: "no-such-checker"},
+		{ID: 2, IsSPJ: true},
+	}
+	for _, p := range problems {
+		if _, _, err := s.problemChecker(p); !IsTerminal(err) {
+			t.Errorf("problem %d: error = %v, want terminal", p.ID, err)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		0: 5 * time.Second,
+		1: 5 * time.Second,
+		2: 30 * time.Second,
+		3: 2 * time.Minute,
+		9: 2 * time.Minute,
+	}
+	for delivered, want := range cases {
+		if got := retryDelay(delivered); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", delivered, got, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package judge
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// 自定义输入运行的限制上限，请求中的限制超出时截断
+const (
+	maxRunTimeLimitMs   = 5000
+	maxRunMemoryLimitMB = 512
+	defaultRunStackMB   = 64
+	runOutputLimit      = 64 << 10 // stdout/stderr 各自保留的字节数
+)
+
+// RunCode 编译并以自定义输入运行一次代码，不做比对
+func (s *JudgeService) RunCode(req *queue.RunRequest) *queue.RunReply {
+	if s.runner == nil {
+		return &queue.RunReply{Error: "sandbox not configured"}
+	}
+	task := &queue.JudgeTask{
+		SubmitID: "run-" + req.RunID,
+		Problem: queue.Problem{
+			TimeLimit:   clampLimit(req.TimeLimit, maxRunTimeLimitMs),
+			MemoryLimit: clampLimit(req.MemoryLimit, maxRunMemoryLimitMB),
+			StackLimit:  defaultRunStackMB,
+		},
+		Language: req.Language,
+		Code:     req.Code,
+		CodeHash: req.CodeHash,
+	}
+
+	workspace, err := s.createWorkspace(task.SubmitID, task.Language, task.Code)
+	if err != nil {
+		return &queue.RunReply{Error: err.Error()}
+	}
+	defer s.cleanup(workspace)
+
+	cr, err := s.compile(task, workspace)
+	if err != nil {
+		return &queue.RunReply{Error: err.Error()}
+	}
+	if !cr.Success {
+		return &queue.RunReply{Status: queue.VerdictCE, CompileInfo: truncateLog(cr.info(), compileInfoLimit)}
+	}
+
+	args, err := parseCommand(task.Language.RunCmd)
+	if err != nil {
+		return &queue.RunReply{Error: "invalid run command: " + err.Error()}
+	}
+	stdout := &limitedBuffer{limit: runOutputLimit}
+	stderr := &limitedBuffer{limit: runOutputLimit}
+	cfg := runConfig(task, workspace, args)
+	cfg.Stdin = strings.NewReader(req.Stdin)
+	cfg.Stdout = stdout
+	cfg.Stderr = stderr
+
+	res, err := s.runner.Run(context.Background(), cfg)
+	if err != nil {
+		log.Printf("Run %s: sandbox error: %v", req.RunID, err)
+		return &queue.RunReply{Error: err.Error()}
+	}
+	return &queue.RunReply{
+		Status:    string(res.Status),
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Truncated: stdout.truncated || stderr.truncated,
+		ExitCode:  res.ExitCode,
+		TimeMs:    int(res.CPUTime.Milliseconds()),
+		MemoryKB:  int(res.MemoryKB),
+	}
+}
+
+// clampLimit 未指定时取上限，超过上限时截断
+func clampLimit(v, limit int) int {
+	if v <= 0 || v > limit {
+		return limit
+	}
+	return v
+}
@@ -0,0 +1,64 @@
+package judge
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// sampleIOLimit 样例评测结果中每段输入输出保留的字节数
+const sampleIOLimit = 16 << 10
+
+// writeSamples 把样例写到 workspace/samples/{n}.in 和 {n}.out
+func writeSamples(workspace string, samples []queue.Sample) ([]testFile, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("problem has no samples")
+	}
+	dir := filepath.Join(workspace, "samples")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create samples dir: %w", err)
+	}
+
+	tests := make([]testFile, 0, len(samples))
+	for i, sample := range samples {
+		base := filepath.Join(dir, strconv.Itoa(i+1))
+		t := testFile{Input: base + ".in", Answer: base + ".out"}
+		// SPJ 以沙箱用户身份读取
+		if err := os.WriteFile(t.Input, []byte(sample.Input), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write sample: %w", err)
+		}
+		if err := os.WriteFile(t.Answer, []byte(sample.Output), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write sample: %w", err)
+		}
+		tests = append(tests, t)
+	}
+	return tests, nil
+}
+
+// attachSampleIO 把样例输入、期望输出和选手输出附到测试点结果
+func attachSampleIO(workspace string, cases []queue.TestCase, tests []testFile) {
+	for i := range cases {
+		t := tests[i]
+		outPath := filepath.Join(workspace, "out", strings.TrimSuffix(filepath.Base(t.Input), ".in")+".out")
+		cases[i].Input = readHead(t.Input, sampleIOLimit)
+		cases[i].Expected = readHead(t.Answer, sampleIOLimit)
+		cases[i].Actual = readHead(outPath, sampleIOLimit)
+	}
+}
+
+// readHead 读取文件开头至多 limit 字节，文件不存在时返回空串
+func readHead(path string, limit int) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	buf := make([]byte, limit)
+	n, _ := io.ReadFull(f, buf)
+	return string(buf[:n])
+}
@@ -0,0 +1,40 @@
+package judge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+func TestSampleIO(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.Mkdir(filepath.Join(workspace, "out"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests, err := writeSamples(workspace, []queue.Sample{{Input: "1 2\n", Output: "3\n"}, {Input: "2 2\n", Output: "4\n"}})
+	if err != nil {
+		t.Fatalf("writeSamples() error = %v", err)
+	}
+	if len(tests) != 2 {
+		t.Fatalf("len(tests) = %d, want 2", len(tests))
+	}
+	// 模拟选手输出：第二个样例没有产生输出
+	if err := os.WriteFile(filepath.Join(workspace, "out", "1.out"), []byte("3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := make([]queue.TestCase, len(tests))
+	attachSampleIO(workspace, cases, tests)
+	if cases[0].Input != "1 2\n" || cases[0].Expected != "3\n" || cases[0].Actual != "3\n" {
+		t.Errorf("case 1 = %+v", cases[0])
+	}
+	if cases[1].Expected != "4\n" || cases[1].Actual != "" {
+		t.Errorf("case 2 = %+v", cases[1])
+	}
+
+	if _, err := writeSamples(t.TempDir(), nil); err == nil {
+		t.Error("writeSamples() with no samples should fail")
+	}
+}
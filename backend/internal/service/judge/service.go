@@ -5,6 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,19 +19,30 @@ import (
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/oj/oj-backend/internal/model"
 	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/sandbox"
+	"golang.org/x/sync/singleflight"
 )
 
 // WorkerPool 评测工作池
 type WorkerPool struct {
 	concurrency int
-	queue       chan *queue.JudgeTask
+	queue       chan *queue.Delivery
+	done        chan struct{}
 	wg          sync.WaitGroup
 	service     *JudgeService
 
+	// workerID 写入 Submission.WorkerID；slots 记录各槽位正在评测的提交，供心跳上报
+	workerID  string
+	languages map[string]bool // 本 worker 能运行的语言，为空时不检查
+	slotsMu   sync.Mutex
+	slots     []queue.WorkerSlot
+
 	submitRepo interface {
 		UpdateStatus(id string, status string, workerID string, startTime time.Time) error
 		UpdateResult(id string, result *queue.JudgeResult) error
-		GetSubmitByID(id string) (*model.Submission, error)
+		UpdateRetryCount(id string, count int) error
+		RecordFailure(id string, taskErr queue.TaskError) error
+		GetBySubmitID(submitID string) (*model.Submission, error)
 	}
 
 	// ✅ 修复：签名必须和 jetstream.JetStream.Publish 完全一致
@@ -40,21 +58,42 @@ func NewWorkerPool(
 	submitRepo interface {
 		UpdateStatus(id string, status string, workerID string, startTime time.Time) error
 		UpdateResult(id string, result *queue.JudgeResult) error
-		GetSubmitByID(id string) (*model.Submission, error)
+		UpdateRetryCount(id string, count int) error
+		RecordFailure(id string, taskErr queue.TaskError) error
+		GetBySubmitID(submitID string) (*model.Submission, error)
 	},
 	js interface {
 		Publish(ctx context.Context, subject string, data []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
 	},
 ) *WorkerPool {
+	slots := make([]queue.WorkerSlot, concurrency)
+	for i := range slots {
+		slots[i].Index = i
+	}
 	return &WorkerPool{
 		concurrency: concurrency,
-		queue:       make(chan *queue.JudgeTask, concurrency*2),
+		queue:       make(chan *queue.Delivery),
+		done:        make(chan struct{}),
 		service:     service,
+		slots:       slots,
 		submitRepo:  submitRepo,
 		js:          js,
 	}
 }
 
+// SetLanguages 限定本 worker 能运行的语言 slug，其余任务交还队列
+func (p *WorkerPool) SetLanguages(slugs []string) {
+	p.languages = make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		p.languages[slug] = true
+	}
+}
+
+// SetWorkerID 设置评测记录中的 worker 标识
+func (p *WorkerPool) SetWorkerID(id string) {
+	p.workerID = id
+}
+
 // Start 启动工作池
 func (p *WorkerPool) Start() {
 	for i := 0; i < p.concurrency; i++ {
@@ -64,157 +103,579 @@ func (p *WorkerPool) Start() {
 	log.Printf("Worker pool started with %d workers", p.concurrency)
 }
 
-// Stop 停止工作池
+// Stop 停止工作池：正在评测的任务做完并确认，尚未开始的任务交还队列（不占用重试次数）
 func (p *WorkerPool) Stop() {
-	close(p.queue)
+	close(p.done)
 	p.wg.Wait()
 	log.Println("Worker pool stopped")
 }
 
-// Submit 提交任务
-func (p *WorkerPool) Submit(task *queue.JudgeTask) {
-	p.queue <- task
+// Submit 提交任务，阻塞到有空闲的 worker 接手；工作池已停止时交还队列
+func (p *WorkerPool) Submit(d *queue.Delivery) {
+	select {
+	case p.queue <- d:
+	case <-p.done:
+		if err := d.Release(); err != nil {
+			log.Printf("Failed to release task %s: %v", d.Task.SubmitID, err)
+		}
+	}
 }
 
 func (p *WorkerPool) worker(id int) {
 	defer p.wg.Done()
 	log.Printf("Worker %d started", id)
 
-	for task := range p.queue {
-		p.processTask(task)
+	for {
+		select {
+		case <-p.done:
+			log.Printf("Worker %d stopped", id)
+			return
+		case d := <-p.queue:
+			p.setSlot(id, d.Task)
+			p.processTask(d)
+			p.setSlot(id, nil)
+		}
+	}
+}
+
+// finishTask 保存结果后确认消息；保存失败时延迟重投重新评测
+func (p *WorkerPool) finishTask(d *queue.Delivery, result *queue.JudgeResult) {
+	result.RetryCount = d.Task.RetryCount
+	result.WorkerID = p.workerID
+	if err := p.submitRepo.UpdateResult(d.Task.SubmitID, result); err != nil {
+		log.Printf("Failed to update result: %v", err)
+		p.retry(d, "failed to save result: "+err.Error())
+		return
 	}
+	if err := d.Ack(); err != nil {
+		log.Printf("Failed to ack task %s: %v", d.Task.SubmitID, err)
+	}
+}
 
-	log.Printf("Worker %d stopped", id)
+// deadLetter 把任务连同数据库中历次的失败记录发布到死信队列，subject 为任务原来的 subject
+func (p *WorkerPool) deadLetter(t *queue.JudgeTask, subject string) error {
+	task := *t
+	submission, err := p.submitRepo.GetBySubmitID(task.SubmitID)
+	if err != nil {
+		log.Printf("Failed to load failures of task %s: %v", task.SubmitID, err)
+	} else if submission.JudgeErrors != "" {
+		var errs []queue.TaskError
+		if err := json.Unmarshal([]byte(submission.JudgeErrors), &errs); err == nil {
+			task.Errors = errs
+		}
+	}
+	data, err := json.Marshal(&queue.DeadLetter{
+		Subject:  subject,
+		Task:     task,
+		WorkerID: p.workerID,
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = p.js.Publish(context.Background(), queue.DLQSubject, data)
+	return err
 }
 
-func (p *WorkerPool) processTask(task *queue.JudgeTask) {
+func (p *WorkerPool) processTask(d *queue.Delivery) {
+	task := d.Task
 	log.Printf("Processing task %s", task.SubmitID)
 
-	// 更新状态为 RUNNING
+	// panic 时工作目录已由 ProcessTask 的 defer 清理，这里只需结束本次评测
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Task %s panicked: %v\n%s", task.SubmitID, r, debug.Stack())
+			p.finishTask(d, systemErrorResult(fmt.Sprintf("internal error: %v", r)))
+		}
+	}()
+
+	// 上次已用完重试次数但死信没有发出，不再评测，直接再试一次死信队列
+	if d.Attempt() > queue.MaxDeliver {
+		p.giveUp(d, "judge retries exhausted")
+		return
+	}
+
+	// 同一队列类别的 worker 应具备相同能力，收到不能运行的语言时交还队列，
+	// 重试耗尽后进入死信队列提醒管理员调整配置
+	if len(p.languages) > 0 && !p.languages[task.Language.Slug] {
+		p.handleFailure(d, fmt.Errorf("worker %s cannot run language %s", p.workerID, task.Language.Slug))
+		return
+	}
+
+	if task.RetryCount > 0 {
+		if err := p.submitRepo.UpdateRetryCount(task.SubmitID, task.RetryCount); err != nil {
+			log.Printf("Failed to update retry count: %v", err)
+		}
+	}
+
+	// 更新状态为 COMPILING，编译通过后再更新为 RUNNING
 	now := time.Now()
-	if err := p.submitRepo.UpdateStatus(task.SubmitID, "RUNNING", "", now); err != nil {
-		log.Printf("Failed to update status to RUNNING: %v", err)
+	if err := p.submitRepo.UpdateStatus(task.SubmitID, queue.StatusCompiling, p.workerID, now); err != nil {
+		log.Printf("Failed to update status to COMPILING: %v", err)
+		p.retry(d, err.Error())
 		return
 	}
 
 	// 处理任务
-	result, err := p.service.ProcessTask(task)
+	result, err := p.service.ProcessTask(task, func() {
+		if err := p.submitRepo.UpdateStatus(task.SubmitID, queue.StatusRunning, p.workerID, now); err != nil {
+			log.Printf("Failed to update status to RUNNING: %v", err)
+		}
+	})
 	if err != nil {
-		log.Printf("Task %s failed: %v", task.SubmitID, err)
-
-		// 重试逻辑
-		if task.RetryCount < 3 {
-			task.RetryCount++
+		p.handleFailure(d, err)
+		return
+	}
 
-			// 延迟重试
-			time.Sleep(time.Duration(task.RetryCount) * 5 * time.Second)
+	// 更新结果，落库后才确认消息
+	p.finishTask(d, result)
 
-			// 重新发布到队列
-			data, mErr := json.Marshal(task)
-			if mErr != nil {
-				log.Printf("Failed to marshal retry task %s: %v", task.SubmitID, mErr)
-				return
-			}
-
-			subject := "judge.tasks.light"
-			if task.Language.Slug == "cpp17" || task.Language.Slug == "java17" {
-				subject = "judge.tasks.heavy"
-			}
+	log.Printf("Task %s completed with verdict %s", task.SubmitID, result.Verdict)
+}
 
-			if _, pubErr := p.js.Publish(context.Background(), subject, data); pubErr != nil {
-				log.Printf("Failed to republish task %s to %s: %v", task.SubmitID, subject, pubErr)
-				return
-			}
-		} else {
-			// 进入 DLQ（这里你现在只是标记结果为 DLQ，后续建议再真正 publish 到 judge.dlq）
-			result.Status = "DLQ"
-			result.Error = err.Error()
-			_ = p.submitRepo.UpdateResult(task.SubmitID, result)
+// giveUp 重试耗尽：任务连同失败记录进入死信队列，提交按系统错误结束，管理员可重放。
+// 死信发布成功后才确认消息，即使写库失败也确认，由死信保留任务；
+// 发布失败时延迟重投，下次投递直接再试死信队列，任务不会两头都丢。
+func (p *WorkerPool) giveUp(d *queue.Delivery, reason string) {
+	task := d.Task
+	if err := p.deadLetter(task, d.Subject()); err != nil {
+		log.Printf("Failed to publish task %s to dlq: %v", task.SubmitID, err)
+		delay := retryDelay(d.Attempt())
+		if err := d.NakWithDelay(delay); err != nil {
+			log.Printf("Failed to nak task %s: %v", task.SubmitID, err)
+			return
 		}
+		log.Printf("Task %s will retry the dlq in %s", task.SubmitID, delay)
 		return
 	}
-
-	// 更新结果
+	result := systemErrorResult(reason)
+	result.RetryCount = task.RetryCount
+	result.WorkerID = p.workerID
 	if err := p.submitRepo.UpdateResult(task.SubmitID, result); err != nil {
 		log.Printf("Failed to update result: %v", err)
 	}
+	if err := d.Ack(); err != nil {
+		log.Printf("Failed to ack task %s: %v", task.SubmitID, err)
+	}
+	log.Printf("Task %s moved to dlq after %d attempts", task.SubmitID, task.RetryCount+1)
+}
+
+// handleFailure 处理评测失败：不可重试的错误直接按系统错误结束；
+// 可重试的错误延迟重投，投递次数用完后进入死信队列
+func (p *WorkerPool) handleFailure(d *queue.Delivery, err error) {
+	task := d.Task
+	log.Printf("Task %s failed (delivery %d): %v", task.SubmitID, task.RetryCount+1, err)
+	if recErr := p.submitRepo.RecordFailure(task.SubmitID, queue.TaskError{
+		Attempt:  task.RetryCount + 1,
+		WorkerID: p.workerID,
+		Error:    err.Error(),
+		Time:     time.Now(),
+	}); recErr != nil {
+		log.Printf("Failed to record failure for task %s: %v", task.SubmitID, recErr)
+	}
+
+	if IsTerminal(err) {
+		p.finishTask(d, systemErrorResult(err.Error()))
+		return
+	}
+	p.retry(d, err.Error())
+}
 
-	log.Printf("Task %s completed with status %s", task.SubmitID, result.Status)
+// retry 按投递次数延迟重投，不占用本地槽位；已是最后一次投递时转入死信队列
+func (p *WorkerPool) retry(d *queue.Delivery, reason string) {
+	if d.LastDelivery() {
+		p.giveUp(d, reason)
+		return
+	}
+	delay := retryDelay(d.Attempt())
+	if err := d.NakWithDelay(delay); err != nil {
+		log.Printf("Failed to nak task %s: %v", d.Task.SubmitID, err)
+		return
+	}
+	log.Printf("Task %s will be retried in %s", d.Task.SubmitID, delay)
 }
 
+// 沙箱内选手程序的工作目录
+const sandboxWorkDir = "/app"
+
 // JudgeService 评测服务
 type JudgeService struct {
 	minioClient *minio.Client
 	bucketName  string
+	runner      *sandbox.Runner
+	testData    *TestDataCache
+	artifacts   *ArtifactCache
+	toolchains  map[string]string // 语言 slug -> 本机工具链版本，参与编译产物缓存键
+
+	compileMemoryLimit int64 // 字节
+
+	spjDir   string
+	spjGroup singleflight.Group
+
+	workspaceDir       string
+	workspaceDiskQuota int64 // 字节，0 为不限
 }
 
 // NewJudgeService 创建评测服务
-func NewJudgeService(minioClient *minio.Client, bucketName string) *JudgeService {
+func NewJudgeService(minioClient *minio.Client, bucketName string, runner *sandbox.Runner, testData *TestDataCache) *JudgeService {
 	return &JudgeService{
 		minioClient: minioClient,
 		bucketName:  bucketName,
+		runner:      runner,
+		testData:    testData,
+
+		compileMemoryLimit: defaultCompileMemoryMB << 20,
+		spjDir:             defaultSPJCacheDir,
+		workspaceDir:       os.TempDir(),
 	}
 }
 
-// ProcessTask 处理评测任务
-func (s *JudgeService) ProcessTask(task *queue.JudgeTask) (*queue.JudgeResult, error) {
+// SetArtifactCache 启用编译产物缓存
+func (s *JudgeService) SetArtifactCache(c *ArtifactCache) {
+	s.artifacts = c
+}
+
+// SetToolchains 设置本机探测到的工具链版本（ProbeToolchains），未设置的语言不使用编译产物缓存
+func (s *JudgeService) SetToolchains(versions map[string]string) {
+	s.toolchains = versions
+}
+
+// SetCompileMemoryLimit 设置编译阶段的内存限制（MB）
+func (s *JudgeService) SetCompileMemoryLimit(mb int) {
+	if mb > 0 {
+		s.compileMemoryLimit = int64(mb) << 20
+	}
+}
+
+// ProcessTask 处理评测任务，编译通过开始运行测试点时回调 onRunning（可为 nil）
+func (s *JudgeService) ProcessTask(task *queue.JudgeTask, onRunning func()) (*queue.JudgeResult, error) {
 	// 1. 创建工作目录
-	workspace, err := s.createWorkspace(task)
+	workspace, err := s.createWorkspace(task.SubmitID, task.Language, task.Code)
 	if err != nil {
-		return &queue.JudgeResult{Status: "SYSTEM_ERROR", Error: err.Error()}, err
+		return systemErrorResult(err.Error()), err
 	}
 	defer s.cleanup(workspace)
 
+	tests, release, err := s.acquireTests(task, workspace)
+	if err != nil {
+		return systemErrorResult(err.Error()), err
+	}
+	defer release()
+
+	run, judgeCompile, err := s.caseRunner(task, workspace)
+	if err != nil {
+		return systemErrorResult(err.Error()), err
+	}
+	// SPJ/交互器编不过是题目配置问题，重试无用，直接落库
+	if judgeCompile != nil {
+		name := "special judge"
+		if task.Problem.IsInteractive {
+			name = "interactor"
+		}
+		msg := name + " compile error: " + truncateLog(judgeCompile.info(), compileInfoLimit)
+		return systemErrorResult(msg), nil
+	}
+
 	// 2. 编译
 	compileResult, err := s.compile(task, workspace)
 	if err != nil {
-		return &queue.JudgeResult{
-			Status:       "FINISHED",
-			Score:        0,
-			AcceptedTest: 0,
-			TotalTest:    len(task.Problem.TestDataZip),
-			Error:        compileResult.Error,
-		}, nil
+		return systemErrorResult(err.Error()), err
+	}
+	if !compileResult.Success {
+		return s.compileErrorResult(task, len(tests), compileResult), nil
+	}
+	if onRunning != nil {
+		onRunning()
 	}
 
-	// 3. 运行测试
-	results := s.runTestCases(task, workspace)
+	// 3. 运行测试：样例评测运行全部样例并附上输入输出；配置了子任务时按组计分，
+	// 否则各测试点平分；ACM 赛制遇错即停
+	if task.SampleOnly {
+		results, err := s.runTestCases(tests, run, false)
+		if err != nil {
+			return systemErrorResult(err.Error()), err
+		}
+		attachSampleIO(workspace, results, tests)
+		return s.aggregateResults(results, nil), nil
+	}
+	stopOnFail := judgeMode(task) == ModeACM
+	if len(task.Problem.Subtasks) > 0 {
+		cases, subtasks, err := runSubtasks(tests, task.Problem.Subtasks, run, stopOnFail)
+		if err != nil {
+			return systemErrorResult(err.Error()), err
+		}
+		return s.aggregateResults(cases, subtasks), nil
+	}
+	results, err := s.runTestCases(tests, run, stopOnFail)
+	if err != nil {
+		return systemErrorResult(err.Error()), err
+	}
 
 	// 4. 聚合结果
-	return s.aggregateResults(results), nil
+	return s.aggregateResults(results, nil), nil
 }
 
-func (s *JudgeService) createWorkspace(task *queue.JudgeTask) (string, error) {
-	// 实际实现中创建临时目录
-	return fmt.Sprintf("/tmp/oj-%s", task.SubmitID), nil
+// acquireTests 准备任务的测试点。测试数据来自本地缓存，多个任务共享同一份解压目录；
+// 样例评测则把任务携带的样例写到工作目录。使用完毕调用 release。
+func (s *JudgeService) acquireTests(task *queue.JudgeTask, workspace string) ([]testFile, func(), error) {
+	if task.SampleOnly {
+		tests, err := writeSamples(workspace, task.Samples)
+		return tests, func() {}, err
+	}
+
+	dataDir, release, err := s.testData.Acquire(task.Problem)
+	if err != nil {
+		return nil, nil, err
+	}
+	tests, err := loadTestFiles(dataDir)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return tests, release, nil
 }
 
-func (s *JudgeService) cleanup(workspace string) {
-	// 清理临时文件
+// prepareBox 创建 workspace/box 并写入源码
+func (s *JudgeService) prepareBox(workspace string, lang queue.Language, code string) error {
+	box := filepath.Join(workspace, "box")
+	if err := os.MkdirAll(box, 0755); err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	// 编译阶段以沙箱用户身份往 box 写入产物
+	if s.runner != nil {
+		if err := os.Chown(box, s.runner.UID(), s.runner.GID()); err != nil {
+			return fmt.Errorf("failed to chown workspace: %w", err)
+		}
+	}
+
+	if lang.SourceFilename != "" {
+		src := filepath.Join(box, lang.SourceFilename)
+		if err := os.WriteFile(src, []byte(code), 0644); err != nil {
+			return fmt.Errorf("failed to write source: %w", err)
+		}
+	}
+	return nil
 }
 
-func (s *JudgeService) compile(task *queue.JudgeTask, workspace string) (*queue.JudgeResult, error) {
-	// 检查是否需要编译
-	if task.Language.CompileCmd == "" {
-		return &queue.JudgeResult{}, nil
+// caseFunc 运行单个测试点，返回测试点结果和得分比例。
+// 沙箱等基础设施故障返回 error，由调用方中止评测并重投，不记为测试点 SE。
+type caseFunc func(t testFile) (queue.TestCase, float64, error)
+
+// caseRunner 按题目类型准备测试点的运行方式。SPJ/交互器编译失败时返回非空的 compileResult。
+func (s *JudgeService) caseRunner(task *queue.JudgeTask, workspace string) (caseFunc, *compileResult, error) {
+	args, err := parseCommand(task.Language.RunCmd)
+	if err != nil {
+		return nil, nil, terminal(fmt.Errorf("invalid run command: %w", err))
+	}
+
+	if task.Problem.IsInteractive {
+		if task.Problem.Interactor == nil {
+			return nil, nil, terminal(fmt.Errorf("problem %d has no interactor", task.Problem.ID))
+		}
+		inter, cr, err := s.loadProgram(task.Problem.Interactor)
+		if err != nil || cr != nil {
+			return nil, cr, err
+		}
+		return func(t testFile) (queue.TestCase, float64, error) {
+			return s.runInteractiveCase(task, workspace, args, t, inter)
+		}, nil, nil
+	}
+
+	checker, cr, err := s.problemChecker(&task.Problem)
+	if err != nil || cr != nil {
+		return nil, cr, err
 	}
+	return func(t testFile) (queue.TestCase, float64, error) {
+		return s.runTestCase(task, workspace, args, t, checker)
+	}, nil, nil
+}
 
-	// TODO: 实现编译逻辑
-	return &queue.JudgeResult{}, nil
+// runTestCases 依次运行各测试点，stopOnFail 时遇到第一个未通过的测试点即停止，其余记为 SKIPPED
+func (s *JudgeService) runTestCases(tests []testFile, run caseFunc, stopOnFail bool) ([]queue.TestCase, error) {
+	cases := make([]queue.TestCase, 0, len(tests))
+	stopped := false
+	for i, t := range tests {
+		if stopped {
+			cases = append(cases, skippedCase(i, t))
+			continue
+		}
+		tc, ratio, err := run(t)
+		if err != nil {
+			return nil, err
+		}
+		tc.ID = i + 1
+		tc.Score = int(math.Round(float64(caseScore(i, len(tests))) * ratio))
+		cases = append(cases, tc)
+		stopped = stopOnFail && tc.Status != "AC"
+	}
+	return cases, nil
 }
 
-func (s *JudgeService) runTestCases(task *queue.JudgeTask, workspace string) []queue.TestCase {
-	// TODO: 实现测试运行
-	// 1. 拉取测试数据
-	// 2. 运行 Docker 容器
-	// 3. 比对输出
-	// 4. 收集结果
-	return []queue.TestCase{
-		{ID: 1, Status: "AC", TimeMs: 10, MemoryKB: 1024, Score: 100},
+// skippedCase 未运行的测试点
+func skippedCase(i int, t testFile) queue.TestCase {
+	return queue.TestCase{
+		ID:         i + 1,
+		Status:     "SKIPPED",
+		InputFile:  filepath.Base(t.Input),
+		OutputFile: filepath.Base(t.Answer),
 	}
 }
 
-func (s *JudgeService) aggregateResults(cases []queue.TestCase) *queue.JudgeResult {
+// runTestCase 在沙箱中运行单个测试点并比对输出，返回测试点结果和得分比例。
+// 沙箱不可用、读写工作目录失败等基础设施故障返回 error；
+// 比对器自身出错（SPJ 崩溃等题目问题）记为该测试点 SE。
+func (s *JudgeService) runTestCase(task *queue.JudgeTask, workspace string, args []string, t testFile, checker Checker) (queue.TestCase, float64, error) {
+	tc := queue.TestCase{
+		InputFile:  filepath.Base(t.Input),
+		OutputFile: filepath.Base(t.Answer),
+	}
+	if s.runner == nil {
+		return tc, 0, fmt.Errorf("sandbox not configured")
+	}
+
+	stdin, err := os.Open(t.Input)
+	if err != nil {
+		return tc, 0, fmt.Errorf("failed to open input %s: %w", t.Input, err)
+	}
+	defer stdin.Close()
+
+	outPath := filepath.Join(workspace, "out", strings.TrimSuffix(filepath.Base(t.Input), ".in")+".out")
+	stdout, err := os.Create(outPath)
+	if err != nil {
+		return tc, 0, fmt.Errorf("failed to create output %s: %w", outPath, err)
+	}
+	defer stdout.Close()
+
+	cfg := runConfig(task, workspace, args)
+	cfg.Stdin = stdin
+	cfg.Stdout = stdout
+
+	res, err := s.runner.Run(context.Background(), cfg)
+	if err != nil {
+		return tc, 0, fmt.Errorf("sandbox error on %s: %w", tc.InputFile, err)
+	}
+
+	tc.TimeMs = int(res.CPUTime.Milliseconds())
+	tc.MemoryKB = int(res.MemoryKB)
+	if res.Status != sandbox.StatusOK {
+		tc.Status = string(res.Status)
+		return tc, 0, nil
+	}
+
+	cr, err := checker.Check(t.Input, outPath, t.Answer)
+	if err != nil {
+		if !IsTerminal(err) {
+			return tc, 0, fmt.Errorf("failed to check %s: %w", tc.InputFile, err)
+		}
+		log.Printf("Task %s: failed to check %s: %v", task.SubmitID, tc.InputFile, err)
+		tc.Status = "SE"
+		return tc, 0, nil
+	}
+	tc.Status = cr.Status
+	tc.Message = cr.Message
+	return tc, cr.Score, nil
+}
+
+// runConfig 选手程序的沙箱配置，时间和内存按语言系数放大
+func runConfig(task *queue.JudgeTask, workspace string, args []string) *sandbox.Config {
+	p, l := task.Problem, task.Language
+	timeLimit := time.Duration(float64(p.TimeLimit)*factor(l.TimeFactor)) * time.Millisecond
+	memoryLimit := int64(float64(p.MemoryLimit)*factor(l.MemoryFactor)) << 20
+
+	return &sandbox.Config{
+		Args: args,
+		Dir:  sandboxWorkDir,
+		Mounts: []sandbox.Mount{
+			{Source: filepath.Join(workspace, "box"), Target: sandboxWorkDir, ReadOnly: true},
+		},
+		TimeLimit:   timeLimit,
+		MemoryLimit: memoryLimit,
+		StackLimit:  int64(p.StackLimit) << 20,
+		OutputLimit: int64(l.OutputLimit) << 10, // OutputLimit 单位 KB
+		PidsLimit:   l.PidsLimit,
+	}
+}
+
+func factor(f float64) float64 {
+	if f <= 0 {
+		return 1
+	}
+	return f
+}
+
+// caseScore 把 100 分平均分配到各测试点，余数分给前面的测试点
+func caseScore(i, n int) int {
+	score := 100 / n
+	if i < 100%n {
+		score++
+	}
+	return score
+}
+
+// testFile 一组输入/答案文件
+type testFile struct {
+	Input  string
+	Answer string
+}
+
+// loadTestFiles 扫描测试数据目录，xxx.in 对应 xxx.out 或 xxx.ans，按编号排序
+func loadTestFiles(dir string) ([]testFile, error) {
+	inputs, err := filepath.Glob(filepath.Join(dir, "*.in"))
+	if err != nil {
+		return nil, err
+	}
+
+	tests := make([]testFile, 0, len(inputs))
+	for _, in := range inputs {
+		base := strings.TrimSuffix(in, ".in")
+		ans := base + ".out"
+		if _, err := os.Stat(ans); err != nil {
+			ans = base + ".ans"
+			if _, err := os.Stat(ans); err != nil {
+				return nil, terminal(fmt.Errorf("missing answer for %s", filepath.Base(in)))
+			}
+		}
+		tests = append(tests, testFile{Input: in, Answer: ans})
+	}
+	if len(tests) == 0 {
+		return nil, terminal(fmt.Errorf("no test data in %s", dir))
+	}
+
+	sort.Slice(tests, func(i, j int) bool {
+		a := strings.TrimSuffix(filepath.Base(tests[i].Input), ".in")
+		b := strings.TrimSuffix(filepath.Base(tests[j].Input), ".in")
+		na, errA := strconv.Atoi(a)
+		nb, errB := strconv.Atoi(b)
+		if errA == nil && errB == nil {
+			return na < nb
+		}
+		return a < b
+	})
+	return tests, nil
+}
+
+// parseCommand 解析语言命令：JSON 数组，或按空白分割的字符串
+func parseCommand(cmd string) ([]string, error) {
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return nil, fmt.Errorf("empty command")
+	}
+	if strings.HasPrefix(cmd, "[") {
+		var args []string
+		if err := json.Unmarshal([]byte(cmd), &args); err != nil {
+			return nil, fmt.Errorf("invalid command %q: %w", cmd, err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("empty command")
+		}
+		return args, nil
+	}
+	return strings.Fields(cmd), nil
+}
+
+// aggregateResults 汇总测试点结果；有子任务时总分取各子任务得分之和
+func (s *JudgeService) aggregateResults(cases []queue.TestCase, subtasks []queue.SubtaskResult) *queue.JudgeResult {
 	accepted := 0
 	totalTime := 0
 	maxMemory := 0
@@ -230,15 +691,25 @@ func (s *JudgeService) aggregateResults(cases []queue.TestCase) *queue.JudgeResu
 		}
 		totalScore += c.Score
 	}
+	if subtasks != nil {
+		totalScore = 0
+		for _, st := range subtasks {
+			totalScore += st.Score
+		}
+	}
 
+	verdict, firstFailed := finalVerdict(cases)
 	return &queue.JudgeResult{
-		Status:       "FINISHED",
+		Status:       queue.StatusDone,
+		Verdict:      verdict,
+		FirstFailed:  firstFailed,
 		Score:        totalScore,
 		AcceptedTest: accepted,
 		TotalTest:    len(cases),
 		TimeMs:       totalTime,
 		MemoryKB:     maxMemory,
 		Cases:        cases,
+		Subtasks:     subtasks,
 		FinishTime:   timePtr(time.Now()),
 	}
 }
@@ -0,0 +1,99 @@
+package judge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// fakeSubmitRepo 与 SubmitRepo 一样把失败记录以 JSON 数组存在 JudgeErrors 中
+type fakeSubmitRepo struct {
+	submissions map[string]*model.Submission
+}
+
+func (r *fakeSubmitRepo) UpdateStatus(id, status, workerID string, startTime time.Time) error {
+	return nil
+}
+func (r *fakeSubmitRepo) UpdateResult(id string, result *queue.JudgeResult) error { return nil }
+func (r *fakeSubmitRepo) UpdateRetryCount(id string, count int) error             { return nil }
+
+func (r *fakeSubmitRepo) RecordFailure(id string, taskErr queue.TaskError) error {
+	submission, err := r.GetBySubmitID(id)
+	if err != nil {
+		return err
+	}
+	var errs []queue.TaskError
+	if submission.JudgeErrors != "" {
+		json.Unmarshal([]byte(submission.JudgeErrors), &errs)
+	}
+	data, _ := json.Marshal(append(errs, taskErr))
+	submission.JudgeErrors = string(data)
+	return nil
+}
+
+func (r *fakeSubmitRepo) GetBySubmitID(submitID string) (*model.Submission, error) {
+	submission, ok := r.submissions[submitID]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return submission, nil
+}
+
+// fakePublisher 记录发布的消息，err 非空时发布失败
+type fakePublisher struct {
+	err       error
+	published map[string][]byte
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, subject string, data []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.published[subject] = data
+	return &jetstream.PubAck{}, nil
+}
+
+func TestDeadLetterCarriesRecordedFailures(t *testing.T) {
+	repo := &fakeSubmitRepo{submissions: map[string]*model.Submission{"s1": {SubmitID: "s1"}}}
+	js := &fakePublisher{published: map[string][]byte{}}
+	p := NewWorkerPool(1, nil, repo, js)
+	p.SetWorkerID("w1")
+
+	// 前几次投递各记录一次失败
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err := repo.RecordFailure("s1", queue.TaskError{Attempt: attempt, WorkerID: "w1", Error: "sandbox unavailable"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	task := &queue.JudgeTask{SubmitID: "s1", RetryCount: 3}
+	if err := p.deadLetter(task, "judge.tasks.light.practice.u1"); err != nil {
+		t.Fatal(err)
+	}
+	var dl queue.DeadLetter
+	if err := json.Unmarshal(js.published[queue.DLQSubject], &dl); err != nil {
+		t.Fatal(err)
+	}
+	if len(dl.Task.Errors) != 3 {
+		t.Fatalf("dead letter carries %d failures, want 3", len(dl.Task.Errors))
+	}
+	for i, e := range dl.Task.Errors {
+		if e.Attempt != i+1 {
+			t.Errorf("failure %d has attempt %d", i, e.Attempt)
+		}
+	}
+	if dl.Subject != "judge.tasks.light.practice.u1" || dl.WorkerID != "w1" {
+		t.Errorf("dead letter subject %s worker %s", dl.Subject, dl.WorkerID)
+	}
+
+	js.err = errors.New("nats: timeout")
+	if err := p.deadLetter(task, "judge.tasks.light.practice.u1"); err == nil {
+		t.Fatal("publish failure should be returned so the task is not acked")
+	}
+}
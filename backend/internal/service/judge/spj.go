@@ -0,0 +1,289 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/sandbox"
+)
+
+const (
+	defaultSPJCacheDir = "/var/cache/oj-spj"
+
+	spjTimeLimit    = 10 * time.Second
+	spjMemoryLimit  = 1 << 30
+	spjOutputLimit  = 16 << 20
+	spjPidsLimit    = 64
+	spjMessageLimit = 1024 // 写入测试点 Message 的 checker 输出上限
+)
+
+// testlib 退出码
+const (
+	testlibOK            = 0
+	testlibWA            = 1
+	testlibPE            = 2
+	testlibFail          = 3
+	testlibDirt          = 4
+	testlibPoints        = 7
+	testlibUnexpectedEOF = 8
+	testlibPartially     = 16 // _pc(n) 退出码为 16+n，n 为百分比
+)
+
+// SetSPJCacheDir 设置已编译 SPJ 的缓存目录，并清理上次残留的半成品
+func (s *JudgeService) SetSPJCacheDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create spj cache dir: %w", err)
+	}
+	tmps, _ := filepath.Glob(filepath.Join(dir, ".tmp-*"))
+	for _, tmp := range tmps {
+		os.RemoveAll(tmp)
+	}
+	s.spjDir = dir
+	return nil
+}
+
+// CompileSPJ 试编译 SPJ，成功的产物直接进入缓存，供题目保存时检查
+func (s *JudgeService) CompileSPJ(spj *queue.SPJ) *queue.SPJCompileReply {
+	_, cr, err := s.buildSPJ(spj)
+	if err != nil {
+		return &queue.SPJCompileReply{Error: err.Error()}
+	}
+	reply := &queue.SPJCompileReply{Success: cr.Success}
+	if !cr.Success {
+		reply.CompileInfo = truncateLog(cr.info(), compileInfoLimit)
+	}
+	return reply
+}
+
+// problemChecker 题目使用的比对器。SPJ 编译失败时返回非空的 compileResult，
+// 题目配置错误不可重试。
+func (s *JudgeService) problemChecker(p *queue.Problem) (Checker, *compileResult, error) {
+	if !p.IsSPJ {
+		checker, err := NewChecker(p.Checker, p.CheckerEpsilon)
+		if err != nil {
+			return nil, nil, terminal(fmt.Errorf("problem %d: %w", p.ID, err))
+		}
+		return checker, nil, nil
+	}
+	if p.SPJ == nil {
+		return nil, nil, terminal(fmt.Errorf("problem %d has no special judge", p.ID))
+	}
+
+	prog, cr, err := s.loadProgram(p.SPJ)
+	if err != nil || cr != nil {
+		return nil, cr, err
+	}
+	return &spjChecker{runner: s.runner, prog: prog}, nil, nil
+}
+
+// program 已编译的 SPJ 或交互器
+type program struct {
+	box  string // 编译产物目录，只读挂载到 /app
+	args []string
+}
+
+// withArgs 追加命令行参数
+func (p *program) withArgs(extra ...string) []string {
+	return append(append([]string{}, p.args...), extra...)
+}
+
+// loadProgram 编译（或从缓存取出）SPJ/交互器。编译失败时返回非空的 compileResult。
+func (s *JudgeService) loadProgram(spj *queue.SPJ) (*program, *compileResult, error) {
+	box, cr, err := s.buildSPJ(spj)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cr.Success {
+		return nil, cr, nil
+	}
+	args, err := parseCommand(spj.Language.RunCmd)
+	if err != nil {
+		return nil, nil, terminal(fmt.Errorf("invalid %s run command: %w", spj.Language.Slug, err))
+	}
+	return &program{box: box, args: args}, nil, nil
+}
+
+// buildSPJ 编译 SPJ 并返回产物目录。
+// 缓存以语言配置和源码的哈希为键，题目更新 SPJ 后自然对应新目录，同一版本只编译一次。
+func (s *JudgeService) buildSPJ(spj *queue.SPJ) (string, *compileResult, error) {
+	key := spjKey(spj)
+	dir := filepath.Join(s.spjDir, key)
+	box := filepath.Join(dir, "box")
+	if _, err := os.Stat(box); err == nil {
+		return box, &compileResult{Success: true}, nil
+	}
+
+	v, err, _ := s.spjGroup.Do(key, func() (interface{}, error) {
+		if _, err := os.Stat(box); err == nil {
+			return &compileResult{Success: true}, nil
+		}
+		if err := os.MkdirAll(s.spjDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create spj cache dir: %w", err)
+		}
+		tmp, err := os.MkdirTemp(s.spjDir, ".tmp-")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(tmp, 0755); err != nil {
+			os.RemoveAll(tmp)
+			return nil, err
+		}
+		if err := s.prepareBox(tmp, spj.Language, spj.Code); err != nil {
+			os.RemoveAll(tmp)
+			return nil, err
+		}
+
+		cr, err := s.compileSource(spj.Language, tmp)
+		if err != nil || !cr.Success {
+			os.RemoveAll(tmp)
+			return cr, err
+		}
+		if err := os.Rename(tmp, dir); err != nil {
+			os.RemoveAll(tmp)
+			return nil, fmt.Errorf("failed to cache spj: %w", err)
+		}
+		return cr, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return box, v.(*compileResult), nil
+}
+
+func spjKey(spj *queue.SPJ) string {
+	h := sha256.New()
+	for _, part := range []string{spj.Language.Slug, spj.Language.CompileCmd, spj.Language.SourceFilename, spj.Code} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// spjChecker 在沙箱中按 testlib 约定运行 SPJ：checker <input> <output> <answer>
+type spjChecker struct {
+	runner *sandbox.Runner
+	prog   *program
+}
+
+// Check 沙箱故障返回可重试的错误；SPJ 自身运行失败或返回无效结果属于题目问题，不可重试
+func (c *spjChecker) Check(inputPath, outputPath, answerPath string) (*CheckResult, error) {
+	if c.runner == nil {
+		return nil, fmt.Errorf("sandbox not configured")
+	}
+
+	msg := &limitedBuffer{limit: spjMessageLimit}
+	res, err := c.runner.Run(context.Background(), &sandbox.Config{
+		Args:   c.prog.withArgs("/judge/input", "/judge/output", "/judge/answer"),
+		Dir:    sandboxWorkDir,
+		Stdout: msg,
+		Stderr: msg,
+		Mounts: []sandbox.Mount{
+			{Source: c.prog.box, Target: sandboxWorkDir, ReadOnly: true},
+			{Source: inputPath, Target: "/judge/input", ReadOnly: true},
+			{Source: outputPath, Target: "/judge/output", ReadOnly: true},
+			{Source: answerPath, Target: "/judge/answer", ReadOnly: true},
+		},
+		TimeLimit:   spjTimeLimit,
+		MemoryLimit: spjMemoryLimit,
+		OutputLimit: spjOutputLimit,
+		PidsLimit:   spjPidsLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("spj sandbox: %w", err)
+	}
+
+	code, err := testlibExitCode(res)
+	if err != nil {
+		return nil, terminal(fmt.Errorf("checker %w", err))
+	}
+	cr, err := parseTestlibResult(code, msg.String())
+	if err != nil {
+		return nil, terminal(err)
+	}
+	return cr, nil
+}
+
+// testlibExitCode 只有正常退出的返回码才有意义，超时、超内存、被信号杀死都属于程序故障
+func testlibExitCode(res *sandbox.Result) (int, error) {
+	switch {
+	case res.Status == sandbox.StatusOK:
+		return 0, nil
+	case res.Status == sandbox.StatusRuntimeError && res.Signal == 0:
+		return res.ExitCode, nil
+	default:
+		return 0, fmt.Errorf("%s: %s", res.Status, res.Error)
+	}
+}
+
+// parseTestlibResult 按 testlib 退出码解释 checker 结果
+func parseTestlibResult(code int, output string) (*CheckResult, error) {
+	msg := strings.ToValidUTF8(strings.TrimSpace(output), "")
+	switch {
+	case code == testlibOK:
+		return &CheckResult{Status: "AC", Message: msg, Score: 1}, nil
+	case code == testlibWA, code == testlibUnexpectedEOF:
+		return &CheckResult{Status: "WA", Message: msg}, nil
+	case code == testlibPE, code == testlibDirt:
+		return &CheckResult{Status: "PE", Message: msg}, nil
+	case code == testlibFail:
+		return nil, fmt.Errorf("checker failed: %s", msg)
+	case code == testlibPoints:
+		// quitp 输出 "points <value> <message>"，value 按 [0, 1] 的得分比例解释
+		rest, ok := strings.CutPrefix(msg, "points ")
+		if !ok {
+			return nil, fmt.Errorf("checker returned points without score: %s", msg)
+		}
+		value, comment, _ := strings.Cut(rest, " ")
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("checker returned invalid points %q", value)
+		}
+		return partialResult(ratio, strings.TrimSpace(comment)), nil
+	case code >= testlibPartially && code <= testlibPartially+100:
+		return partialResult(float64(code-testlibPartially)/100, msg), nil
+	default:
+		return nil, fmt.Errorf("checker exited with unexpected code %d: %s", code, msg)
+	}
+}
+
+func partialResult(ratio float64, msg string) *CheckResult {
+	switch {
+	case ratio >= 1:
+		return &CheckResult{Status: "AC", Message: msg, Score: 1}
+	case ratio > 0:
+		return &CheckResult{Status: "PC", Message: msg, Score: ratio}
+	default:
+		return &CheckResult{Status: "WA", Message: msg}
+	}
+}
+
+// limitedBuffer 只保留前 limit 字节的 io.Writer，超出部分静默丢弃
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := b.limit - b.buf.Len()
+	if n > 0 {
+		b.buf.Write(p[:min(n, len(p))])
+	}
+	if len(p) > n {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
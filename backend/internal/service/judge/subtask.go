@@ -0,0 +1,180 @@
+package judge
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// 子任务评分策略
+const (
+	PolicyMin = "min" // 取组内最低得分比例（默认）
+	PolicySum = "sum" // 按组内平均得分比例
+	PolicyAll = "all" // 全部通过才得分
+)
+
+// TestCaseConfig Problem.TestCases 的结构
+type TestCaseConfig struct {
+	Subtasks []queue.Subtask `json:"subtasks"`
+}
+
+// ParseSubtasks 解析并校验 Problem.TestCases，未配置时返回 nil
+func ParseSubtasks(raw string) ([]queue.Subtask, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return nil, nil
+	}
+	var cfg TestCaseConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid test_cases: %w", err)
+	}
+	if err := ValidateSubtasks(cfg.Subtasks); err != nil {
+		return nil, err
+	}
+	return cfg.Subtasks, nil
+}
+
+// ValidateSubtasks 校验子任务定义。依赖只能指向前面声明的子任务，从而保证无环，
+// 评测时按声明顺序执行即可。
+func ValidateSubtasks(subtasks []queue.Subtask) error {
+	seen := make(map[int]bool, len(subtasks))
+	for i, st := range subtasks {
+		if st.ID <= 0 {
+			return fmt.Errorf("subtask #%d: id must be positive", i+1)
+		}
+		if seen[st.ID] {
+			return fmt.Errorf("subtask %d: duplicate id", st.ID)
+		}
+		if st.Score < 0 {
+			return fmt.Errorf("subtask %d: negative score", st.ID)
+		}
+		switch st.Policy {
+		case "", PolicyMin, PolicySum, PolicyAll:
+		default:
+			return fmt.Errorf("subtask %d: unknown policy %q", st.ID, st.Policy)
+		}
+		if len(st.Cases) == 0 {
+			return fmt.Errorf("subtask %d: no test cases", st.ID)
+		}
+		for _, dep := range st.DependsOn {
+			if !seen[dep] {
+				return fmt.Errorf("subtask %d: dependency %d must be declared before it", st.ID, dep)
+			}
+		}
+		seen[st.ID] = true
+	}
+	return nil
+}
+
+// runSubtasks 按子任务评测。同一测试点被多个子任务引用时只运行一次；
+// 依赖未全部通过的子任务整组跳过，不属于任何已运行子任务的测试点记为 SKIPPED。
+// stopOnFail 时遇到第一个未通过的测试点即停止，后续测试点和子任务都跳过。
+func runSubtasks(tests []testFile, subtasks []queue.Subtask, run caseFunc, stopOnFail bool) ([]queue.TestCase, []queue.SubtaskResult, error) {
+	index := make(map[string]int, len(tests))
+	for i, t := range tests {
+		index[strings.TrimSuffix(filepath.Base(t.Input), ".in")] = i
+	}
+	for _, st := range subtasks {
+		for _, name := range st.Cases {
+			if _, ok := index[name]; !ok {
+				return nil, nil, terminal(fmt.Errorf("subtask %d: test case %q not found in test data", st.ID, name))
+			}
+		}
+	}
+
+	cases := make([]queue.TestCase, len(tests))
+	ratios := make([]float64, len(tests))
+	done := make([]bool, len(tests))
+	passed := make(map[int]bool, len(subtasks))
+	results := make([]queue.SubtaskResult, 0, len(subtasks))
+	stopped := false
+
+	for _, st := range subtasks {
+		sr := queue.SubtaskResult{ID: st.ID, FullScore: st.Score, Status: "AC"}
+		for _, name := range st.Cases {
+			sr.Cases = append(sr.Cases, index[name]+1)
+		}
+
+		skip := stopped
+		for _, dep := range st.DependsOn {
+			if !passed[dep] {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			sr.Status = "SKIPPED"
+			results = append(results, sr)
+			continue
+		}
+
+		groupRatios := make([]float64, 0, len(st.Cases))
+		for _, name := range st.Cases {
+			i := index[name]
+			if stopped {
+				// 未运行的测试点按 0 分计入本组
+				groupRatios = append(groupRatios, 0)
+				continue
+			}
+			if !done[i] {
+				var err error
+				cases[i], ratios[i], err = run(tests[i])
+				if err != nil {
+					return nil, nil, err
+				}
+				cases[i].ID = i + 1
+				done[i] = true
+			}
+			groupRatios = append(groupRatios, ratios[i])
+			if cases[i].Status != "AC" {
+				if sr.Status == "AC" {
+					sr.Status = cases[i].Status
+				}
+				stopped = stopOnFail
+			}
+		}
+		sr.Score = subtaskScore(st, groupRatios)
+		passed[st.ID] = sr.Status == "AC"
+		results = append(results, sr)
+	}
+
+	for i, t := range tests {
+		if !done[i] {
+			cases[i] = skippedCase(i, t)
+		}
+	}
+	return cases, results, nil
+}
+
+// subtaskScore 按评分策略计算子任务得分，ratios 为组内各测试点的得分比例
+func subtaskScore(st queue.Subtask, ratios []float64) int {
+	if len(ratios) == 0 {
+		return 0
+	}
+	var ratio float64
+	switch st.Policy {
+	case PolicyAll:
+		ratio = 1
+		for _, r := range ratios {
+			if r < 1 {
+				ratio = 0
+				break
+			}
+		}
+	case PolicySum:
+		for _, r := range ratios {
+			ratio += r
+		}
+		ratio /= float64(len(ratios))
+	default:
+		ratio = 1
+		for _, r := range ratios {
+			ratio = math.Min(ratio, r)
+		}
+	}
+	return int(math.Round(float64(st.Score) * ratio))
+}
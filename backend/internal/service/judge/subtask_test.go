@@ -0,0 +1,165 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+func TestRunSubtasks(t *testing.T) {
+	tests := make([]testFile, 5)
+	for i := range tests {
+		tests[i] = testFile{Input: fmt.Sprintf("/data/%d.in", i+1), Answer: fmt.Sprintf("/data/%d.out", i+1)}
+	}
+	// 测试点 3 部分正确，测试点 4 错误
+	verdicts := map[string]struct {
+		status string
+		ratio  float64
+	}{
+		"/data/1.in": {"AC", 1},
+		"/data/2.in": {"AC", 1},
+		"/data/3.in": {"PC", 0.5},
+		"/data/4.in": {"WA", 0},
+		"/data/5.in": {"AC", 1},
+	}
+	runs := map[string]int{}
+	run := func(t testFile) (queue.TestCase, float64, error) {
+		runs[t.Input]++
+		v := verdicts[t.Input]
+		return queue.TestCase{Status: v.status}, v.ratio, nil
+	}
+
+	subtasks := []queue.Subtask{
+		{ID: 1, Score: 10, Cases: []string{"1", "2"}},
+		{ID: 2, Score: 20, Policy: PolicySum, Cases: []string{"2", "3"}},
+		{ID: 3, Score: 30, Policy: PolicyMin, Cases: []string{"3"}},
+		{ID: 4, Score: 40, Policy: PolicyAll, Cases: []string{"1", "4"}, DependsOn: []int{1}},
+		{ID: 5, Score: 50, Cases: []string{"5"}, DependsOn: []int{1, 4}},
+	}
+
+	cases, results, err := runSubtasks(tests, subtasks, run, false)
+	if err != nil {
+		t.Fatalf("runSubtasks() error = %v", err)
+	}
+
+	want := []queue.SubtaskResult{
+		{ID: 1, Status: "AC", Score: 10, FullScore: 10},
+		{ID: 2, Status: "PC", Score: 15, FullScore: 20},
+		{ID: 3, Status: "PC", Score: 15, FullScore: 30},
+		{ID: 4, Status: "WA", Score: 0, FullScore: 40},
+		{ID: 5, Status: "SKIPPED", Score: 0, FullScore: 50},
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.ID != w.ID || got.Status != w.Status || got.Score != w.Score || got.FullScore != w.FullScore {
+			t.Errorf("subtask %d = %+v, want %+v", w.ID, got, w)
+		}
+	}
+
+	if cases[4].Status != "SKIPPED" || runs["/data/5.in"] != 0 {
+		t.Errorf("case 5 should be skipped, got %s (runs %d)", cases[4].Status, runs["/data/5.in"])
+	}
+	for _, in := range []string{"/data/1.in", "/data/2.in", "/data/3.in"} {
+		if runs[in] != 1 {
+			t.Errorf("%s ran %d times, want 1", in, runs[in])
+		}
+	}
+	for i, c := range cases {
+		if c.ID != i+1 {
+			t.Errorf("cases[%d].ID = %d, want %d", i, c.ID, i+1)
+		}
+	}
+}
+
+func TestParseSubtasks(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantLen int
+		wantErr bool
+	}{
+		{name: "empty", raw: ""},
+		{name: "null", raw: "null"},
+		{name: "valid", raw: `{"subtasks":[{"id":1,"score":40,"cases":["1"]},{"id":2,"score":60,"policy":"all","cases":["2","3"],"depends_on":[1]}]}`, wantLen: 2},
+		{name: "invalid json", raw: `{"subtasks":`, wantErr: true},
+		{name: "unknown policy", raw: `{"subtasks":[{"id":1,"score":40,"policy":"max","cases":["1"]}]}`, wantErr: true},
+		{name: "duplicate id", raw: `{"subtasks":[{"id":1,"cases":["1"]},{"id":1,"cases":["2"]}]}`, wantErr: true},
+		{name: "forward dependency", raw: `{"subtasks":[{"id":1,"cases":["1"],"depends_on":[2]},{"id":2,"cases":["2"]}]}`, wantErr: true},
+		{name: "no cases", raw: `{"subtasks":[{"id":1,"score":10}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subtasks, err := ParseSubtasks(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSubtasks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(subtasks) != tt.wantLen {
+				t.Errorf("len = %d, want %d", len(subtasks), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestRunSubtasksStopOnFail(t *testing.T) {
+	tests := make([]testFile, 4)
+	for i := range tests {
+		tests[i] = testFile{Input: fmt.Sprintf("/data/%d.in", i+1), Answer: fmt.Sprintf("/data/%d.out", i+1)}
+	}
+	runs := 0
+	run := func(t testFile) (queue.TestCase, float64, error) {
+		runs++
+		if t.Input == "/data/2.in" {
+			return queue.TestCase{Status: "WA"}, 0, nil
+		}
+		return queue.TestCase{Status: "AC"}, 1, nil
+	}
+
+	subtasks := []queue.Subtask{
+		{ID: 1, Score: 40, Policy: PolicySum, Cases: []string{"1", "2", "3"}},
+		{ID: 2, Score: 60, Cases: []string{"4"}},
+	}
+	cases, results, err := runSubtasks(tests, subtasks, run, true)
+	if err != nil {
+		t.Fatalf("runSubtasks() error = %v", err)
+	}
+	if runs != 2 {
+		t.Errorf("ran %d cases, want 2", runs)
+	}
+	if results[0].Status != "WA" || results[0].Score != 13 {
+		t.Errorf("subtask 1 = %+v, want WA with score 13", results[0])
+	}
+	if results[1].Status != "SKIPPED" {
+		t.Errorf("subtask 2 status = %s, want SKIPPED", results[1].Status)
+	}
+	for _, i := range []int{2, 3} {
+		if cases[i].Status != "SKIPPED" {
+			t.Errorf("case %d status = %s, want SKIPPED", i+1, cases[i].Status)
+		}
+	}
+}
+
+func TestRunSubtasksSandboxError(t *testing.T) {
+	tests := []testFile{{Input: "/data/1.in"}, {Input: "/data/2.in"}}
+	sandboxErr := errors.New("cgroup unavailable")
+	run := func(t testFile) (queue.TestCase, float64, error) {
+		if t.Input == "/data/2.in" {
+			return queue.TestCase{}, 0, sandboxErr
+		}
+		return queue.TestCase{Status: "AC"}, 1, nil
+	}
+
+	// 基础设施故障中止评测并保持可重试，不记为测试点 SE
+	_, _, err := runSubtasks(tests, []queue.Subtask{{ID: 1, Score: 100, Cases: []string{"1", "2"}}}, run, false)
+	if !errors.Is(err, sandboxErr) || IsTerminal(err) {
+		t.Fatalf("runSubtasks() error = %v, want retryable sandbox error", err)
+	}
+
+	// 子任务配置错误重试无用
+	_, _, err = runSubtasks(tests, []queue.Subtask{{ID: 1, Score: 100, Cases: []string{"3"}}}, run, false)
+	if !IsTerminal(err) {
+		t.Fatalf("runSubtasks() error = %v, want terminal", err)
+	}
+}
@@ -0,0 +1,265 @@
+package judge
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/oj/oj-backend/internal/queue"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	testDataFetchTimeout = 10 * time.Minute
+	maxTestDataFileSize  = 1 << 30 // 单个测试文件解压后上限
+)
+
+// TestDataCache 按内容哈希寻址的本地测试数据缓存。
+// 每个 TestDataHash 对应一个解压后的目录，总大小受 LRU 约束；
+// 同一哈希的并发请求只下载一次，题目换了新哈希后旧目录随即失效。
+type TestDataCache struct {
+	*diskLRU
+	minio  *minio.Client
+	bucket string
+
+	group singleflight.Group
+	// open 读取测试数据压缩包，默认从 MinIO 下载
+	open func(ctx context.Context, location string) (io.ReadCloser, error)
+
+	byProblem map[int64]string // 受 diskLRU.mu 保护
+}
+
+// NewTestDataCache 创建测试数据缓存，并接管目录中已有的缓存数据
+func NewTestDataCache(dir string, maxBytes int64, minioClient *minio.Client, bucket string) (*TestDataCache, error) {
+	lru, err := newDiskLRU(dir, maxBytes, "test data")
+	if err != nil {
+		return nil, err
+	}
+	c := &TestDataCache{
+		diskLRU:   lru,
+		minio:     minioClient,
+		bucket:    bucket,
+		byProblem: make(map[int64]string),
+	}
+	c.open = c.openObject
+	return c, nil
+}
+
+// Acquire 返回题目测试数据的本地目录。使用完毕必须调用 release，
+// 在此之前该目录不会被淘汰。
+func (c *TestDataCache) Acquire(p queue.Problem) (string, func(), error) {
+	hash := strings.ToLower(strings.TrimSpace(p.TestDataHash))
+	if !isSHA256(hash) {
+		return "", nil, terminal(fmt.Errorf("problem %d has no valid test data hash", p.ID))
+	}
+	if p.TestDataZip == "" {
+		return "", nil, terminal(fmt.Errorf("problem %d has no test data", p.ID))
+	}
+
+	// 下载完成到加锁之间条目可能被淘汰，最多重试一次
+	for attempt := 0; attempt < 2; attempt++ {
+		if dir, release, ok := c.acquireLocked(p.ID, hash); ok {
+			return dir, release, nil
+		}
+		_, err, _ := c.group.Do(hash, func() (interface{}, error) {
+			return nil, c.fetch(p.TestDataZip, hash)
+		})
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	if dir, release, ok := c.acquireLocked(p.ID, hash); ok {
+		return dir, release, nil
+	}
+	return "", nil, fmt.Errorf("test data %s evicted before use", hash)
+}
+
+func (c *TestDataCache) acquireLocked(problemID int64, hash string) (string, func(), bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.touch(hash)
+	if !ok {
+		return "", nil, false
+	}
+	c.bindProblem(problemID, hash)
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() { c.release(e) })
+	}
+	return e.dir, release, true
+}
+
+// bindProblem 记录题目当前使用的哈希，旧哈希的目录标记失效
+func (c *TestDataCache) bindProblem(problemID int64, hash string) {
+	old, ok := c.byProblem[problemID]
+	c.byProblem[problemID] = hash
+	if !ok || old == hash {
+		return
+	}
+	if e, ok := c.entries[old]; ok {
+		e.stale = true
+		if e.refs == 0 {
+			c.remove(e)
+		}
+	}
+}
+
+// openObject 从 MinIO 读取测试数据压缩包
+func (c *TestDataCache) openObject(ctx context.Context, location string) (io.ReadCloser, error) {
+	if c.minio == nil {
+		return nil, fmt.Errorf("minio not configured")
+	}
+	bucket, key := c.objectLocation(location)
+	return c.minio.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+}
+
+// fetch 下载测试数据压缩包，校验哈希后解压并加入缓存，超出上限时淘汰旧条目
+func (c *TestDataCache) fetch(location, hash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), testDataFetchTimeout)
+	defer cancel()
+
+	obj, err := c.open(ctx, location)
+	if err != nil {
+		return fmt.Errorf("failed to get test data %s: %w", location, err)
+	}
+	defer obj.Close()
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), obj); err != nil {
+		// 对象不存在说明题目配置的位置有误，重试无用
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return terminal(fmt.Errorf("test data %s not found: %w", location, err))
+		}
+		return fmt.Errorf("failed to download test data %s: %w", location, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != hash {
+		return terminal(fmt.Errorf("test data hash mismatch for %s: expected %s, got %s", location, hash, got))
+	}
+
+	extractDir, err := os.MkdirTemp(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	size, err := unzipTo(tmp.Name(), extractDir)
+	if err != nil {
+		os.RemoveAll(extractDir)
+		return fmt.Errorf("failed to unpack test data %s: %w", location, err)
+	}
+	// 校验器、SPJ 等会以沙箱用户身份读取
+	if err := os.Chmod(extractDir, 0755); err != nil {
+		os.RemoveAll(extractDir)
+		return err
+	}
+
+	if err := c.add(hash, extractDir, size); err != nil {
+		return err
+	}
+	log.Printf("Test data %s cached (%d bytes)", hash, size)
+	return nil
+}
+
+// objectLocation 解析 minio://bucket/key，不带前缀时视为默认 bucket 中的 key
+func (c *TestDataCache) objectLocation(location string) (string, string) {
+	if rest, ok := strings.CutPrefix(location, "minio://"); ok {
+		if bucket, key, ok := strings.Cut(rest, "/"); ok {
+			return bucket, key
+		}
+	}
+	return c.bucket, strings.TrimPrefix(location, "/")
+}
+
+// unzipTo 解压到目录，拒绝越界路径，返回解压总字节数
+func unzipTo(zipPath, dir string) (int64, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	var total int64
+	for _, f := range r.File {
+		name := filepath.Clean(f.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return 0, fmt.Errorf("invalid path in zip: %s", f.Name)
+		}
+		path := filepath.Join(dir, name)
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return 0, err
+		}
+
+		n, err := extractFile(f, path)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func extractFile(f *zip.File, path string) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	n, err := io.Copy(out, io.LimitReader(rc, maxTestDataFileSize+1))
+	if err != nil {
+		return 0, err
+	}
+	if n > maxTestDataFileSize {
+		return 0, fmt.Errorf("%s exceeds %d bytes", f.Name, maxTestDataFileSize)
+	}
+	return n, nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func isSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,195 @@
+package judge
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+func makeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fakeStore 以 location 为键返回压缩包，并统计下载次数
+type fakeStore struct {
+	mu    sync.Mutex
+	zips  map[string][]byte
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (s *fakeStore) put(t *testing.T, location string, files map[string]string) queue.Problem {
+	data := makeZip(t, files)
+	s.mu.Lock()
+	s.zips[location] = data
+	s.mu.Unlock()
+	sum := sha256.Sum256(data)
+	return queue.Problem{TestDataZip: location, TestDataHash: hex.EncodeToString(sum[:])}
+}
+
+func (s *fakeStore) open(ctx context.Context, location string) (io.ReadCloser, error) {
+	s.calls.Add(1)
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return io.NopCloser(bytes.NewReader(s.zips[location])), nil
+}
+
+func newTestCache(t *testing.T, maxBytes int64) (*TestDataCache, *fakeStore) {
+	t.Helper()
+	c, err := NewTestDataCache(t.TempDir(), maxBytes, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeStore{zips: make(map[string][]byte)}
+	c.open = store.open
+	return c, store
+}
+
+func TestTestDataCacheEvict(t *testing.T) {
+	c, store := newTestCache(t, 150)
+	a := store.put(t, "a.zip", map[string]string{"1.in": string(bytes.Repeat([]byte("a"), 100))})
+	a.ID = 1
+	b := store.put(t, "b.zip", map[string]string{"1.in": string(bytes.Repeat([]byte("b"), 100))})
+	b.ID = 2
+
+	dirA, releaseA, err := c.Acquire(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseA()
+
+	// 下载 b 后总大小超限，未被使用的 a 应立即淘汰，而不是等到 b 释放
+	dirB, releaseB, err := c.Acquire(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseB()
+
+	c.mu.Lock()
+	_, hasA := c.entries[a.TestDataHash]
+	size := c.size
+	c.mu.Unlock()
+	if hasA {
+		t.Fatal("unused entry not evicted after fetch")
+	}
+	if size > c.maxBytes {
+		t.Fatalf("cache size %d exceeds limit %d", size, c.maxBytes)
+	}
+	if _, err := os.Stat(dirA); !os.IsNotExist(err) {
+		t.Fatalf("evicted dir still present: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dirB, "1.in")); err != nil || len(data) != 100 {
+		t.Fatalf("read b: %v", err)
+	}
+}
+
+func TestTestDataCacheSingleDownload(t *testing.T) {
+	c, store := newTestCache(t, 1<<20)
+	store.delay = 50 * time.Millisecond
+	p := store.put(t, "p.zip", map[string]string{"1.in": "1 2\n", "1.out": "3\n"})
+	p.ID = 1
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, release, err := c.Acquire(p)
+			if err != nil {
+				errs <- err
+				return
+			}
+			release()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if n := store.calls.Load(); n != 1 {
+		t.Fatalf("downloads = %d, want 1", n)
+	}
+}
+
+func TestTestDataCacheInvalidate(t *testing.T) {
+	c, store := newTestCache(t, 1<<20)
+	old := store.put(t, "v1.zip", map[string]string{"1.in": "old"})
+	old.ID = 1
+	updated := store.put(t, "v2.zip", map[string]string{"1.in": "new"})
+	updated.ID = 1
+
+	oldDir, releaseOld, err := c.Acquire(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧目录仍在使用时只标记失效，释放后删除
+	newDir, releaseNew, err := c.Acquire(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseNew()
+	if data, err := os.ReadFile(filepath.Join(oldDir, "1.in")); err != nil || string(data) != "old" {
+		t.Fatalf("in-use dir changed: %q %v", data, err)
+	}
+	releaseOld()
+
+	c.mu.Lock()
+	_, hasOld := c.entries[old.TestDataHash]
+	c.mu.Unlock()
+	if hasOld {
+		t.Fatal("stale entry kept after release")
+	}
+	if data, err := os.ReadFile(filepath.Join(newDir, "1.in")); err != nil || string(data) != "new" {
+		t.Fatalf("new dir: %q %v", data, err)
+	}
+}
+
+func TestUnzipToRejectsZipSlip(t *testing.T) {
+	root := t.TempDir()
+	zipPath := filepath.Join(root, "evil.zip")
+	data := makeZip(t, map[string]string{"../evil": "x"})
+	if err := os.WriteFile(zipPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root, "out")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unzipTo(zipPath, dir); err == nil {
+		t.Fatal("expected error for path outside target dir")
+	}
+	if _, err := os.Stat(filepath.Join(root, "evil")); !os.IsNotExist(err) {
+		t.Fatalf("file written outside target dir: %v", err)
+	}
+}
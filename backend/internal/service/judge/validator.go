@@ -0,0 +1,122 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/sandbox"
+)
+
+// programOutputLimit 题目自带程序的输出上限，标程和生成器的输出就是测试数据
+const programOutputLimit = 256 << 20
+
+// runProgram 在沙箱中运行题目自带的程序（校验器、标程、生成器等），时间和内存限制与 SPJ 相同
+func (s *JudgeService) runProgram(prog *program, args []string, stdin io.Reader, stdout, stderr io.Writer) (*sandbox.Result, error) {
+	if s.runner == nil {
+		return nil, fmt.Errorf("sandbox not configured")
+	}
+	res, err := s.runner.Run(context.Background(), &sandbox.Config{
+		Args:   prog.withArgs(args...),
+		Dir:    sandboxWorkDir,
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+		Mounts: []sandbox.Mount{
+			{Source: prog.box, Target: sandboxWorkDir, ReadOnly: true},
+		},
+		TimeLimit:   spjTimeLimit,
+		MemoryLimit: spjMemoryLimit,
+		OutputLimit: programOutputLimit,
+		PidsLimit:   spjPidsLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+	return res, nil
+}
+
+// validateInput 用校验器检查输入文件。按 testlib 约定从标准输入读取，退出码 0 为合法，
+// 非 0 时返回校验器的输出作为原因；校验器自身超时或崩溃时返回错误。
+func (s *JudgeService) validateInput(validator *program, inputPath string) (bool, string, error) {
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return false, "", err
+	}
+	defer in.Close()
+
+	msg := &limitedBuffer{limit: spjMessageLimit}
+	res, err := s.runProgram(validator, nil, in, msg, msg)
+	if err != nil {
+		return false, "", fmt.Errorf("validator %w", err)
+	}
+	code, err := testlibExitCode(res)
+	if err != nil {
+		return false, "", fmt.Errorf("validator %w", err)
+	}
+	return code == 0, strings.ToValidUTF8(strings.TrimSpace(msg.String()), ""), nil
+}
+
+// generateAnswer 用标程对输入生成答案文件
+func (s *JudgeService) generateAnswer(std *program, inputPath, answerPath string) error {
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(answerPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	stderr := &limitedBuffer{limit: spjMessageLimit}
+	res, err := s.runProgram(std, nil, in, out, stderr)
+	if err != nil {
+		return fmt.Errorf("std %w", err)
+	}
+	if res.Status != sandbox.StatusOK {
+		return fmt.Errorf("std %s: %s", res.Status, strings.TrimSpace(stderr.String()))
+	}
+	// 答案文件会被 SPJ 以沙箱用户身份读取
+	return out.Chmod(0644)
+}
+
+// ValidateTestData 用校验器逐个检查题目测试数据的输入文件
+func (s *JudgeService) ValidateTestData(req *queue.ValidateRequest) *queue.ValidateReply {
+	p := &req.Problem
+	if p.Validator == nil {
+		return &queue.ValidateReply{Error: "problem has no validator"}
+	}
+	validator, cr, err := s.loadProgram(p.Validator)
+	if err != nil {
+		return &queue.ValidateReply{Error: err.Error()}
+	}
+	if cr != nil {
+		return &queue.ValidateReply{CompileInfo: truncateLog(cr.info(), compileInfoLimit)}
+	}
+
+	dataDir, release, err := s.testData.Acquire(*p)
+	if err != nil {
+		return &queue.ValidateReply{Error: err.Error()}
+	}
+	defer release()
+	tests, err := loadTestFiles(dataDir)
+	if err != nil {
+		return &queue.ValidateReply{Error: err.Error()}
+	}
+
+	reply := &queue.ValidateReply{Files: make([]queue.FileValidation, 0, len(tests))}
+	for _, t := range tests {
+		ok, msg, err := s.validateInput(validator, t.Input)
+		if err != nil {
+			return &queue.ValidateReply{Error: fmt.Sprintf("%s: %v", filepath.Base(t.Input), err)}
+		}
+		reply.Files = append(reply.Files, queue.FileValidation{File: filepath.Base(t.Input), Valid: ok, Message: msg})
+	}
+	return reply
+}
@@ -0,0 +1,64 @@
+package judge
+
+import (
+	"time"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// verdictPriority 多个测试点失败时最终结论的优先级，数值越大越优先：
+// 评测系统故障最优先，其次是资源超限和运行错误，再到答案错误，部分得分最低
+var verdictPriority = map[string]int{
+	queue.VerdictSE:      9,
+	queue.VerdictCE:      8,
+	queue.VerdictTLE:     7,
+	queue.VerdictMLE:     6,
+	queue.VerdictOLE:     5,
+	queue.VerdictRE:      4,
+	queue.VerdictWA:      3,
+	queue.VerdictPE:      2,
+	queue.VerdictPartial: 1,
+}
+
+// caseVerdict 测试点状态对应的结论，SKIPPED 不参与
+func caseVerdict(status string) (string, bool) {
+	switch status {
+	case "AC", "SKIPPED":
+		return "", false
+	case "PC":
+		return queue.VerdictPartial, true
+	}
+	if _, ok := verdictPriority[status]; ok {
+		return status, true
+	}
+	return queue.VerdictSE, true
+}
+
+// finalVerdict 由测试点结果计算最终结论和第一个未通过的测试点 ID（全部通过时为 0）
+func finalVerdict(cases []queue.TestCase) (string, int) {
+	verdict := queue.VerdictAC
+	firstFailed := 0
+	for _, c := range cases {
+		v, failed := caseVerdict(c.Status)
+		if !failed {
+			continue
+		}
+		if firstFailed == 0 || c.ID < firstFailed {
+			firstFailed = c.ID
+		}
+		if verdictPriority[v] > verdictPriority[verdict] {
+			verdict = v
+		}
+	}
+	return verdict, firstFailed
+}
+
+// systemErrorResult 评测系统故障的结果
+func systemErrorResult(msg string) *queue.JudgeResult {
+	return &queue.JudgeResult{
+		Status:     queue.StatusDone,
+		Verdict:    queue.VerdictSE,
+		Error:      msg,
+		FinishTime: timePtr(time.Now()),
+	}
+}
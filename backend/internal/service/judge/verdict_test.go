@@ -0,0 +1,35 @@
+package judge
+
+import (
+	"testing"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+func TestFinalVerdict(t *testing.T) {
+	tests := []struct {
+		name        string
+		statuses    []string
+		wantVerdict string
+		wantFirst   int
+	}{
+		{name: "all accepted", statuses: []string{"AC", "AC"}, wantVerdict: queue.VerdictAC},
+		{name: "skipped ignored", statuses: []string{"AC", "SKIPPED"}, wantVerdict: queue.VerdictAC},
+		{name: "partial", statuses: []string{"AC", "PC"}, wantVerdict: queue.VerdictPartial, wantFirst: 2},
+		{name: "priority", statuses: []string{"AC", "WA", "TLE", "RE"}, wantVerdict: queue.VerdictTLE, wantFirst: 2},
+		{name: "unknown is system error", statuses: []string{"WA", "??"}, wantVerdict: queue.VerdictSE, wantFirst: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cases := make([]queue.TestCase, len(tt.statuses))
+			for i, s := range tt.statuses {
+				cases[i] = queue.TestCase{ID: i + 1, Status: s}
+			}
+			verdict, first := finalVerdict(cases)
+			if verdict != tt.wantVerdict || first != tt.wantFirst {
+				t.Errorf("finalVerdict() = %s, %d, want %s, %d", verdict, first, tt.wantVerdict, tt.wantFirst)
+			}
+		})
+	}
+}
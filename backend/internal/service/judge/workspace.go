@@ -0,0 +1,115 @@
+package judge
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// workspacePrefix 工作目录名前缀，完整目录名为 oj-{submit_id}.{pid}
+const workspacePrefix = "oj-"
+
+// SetWorkspaceDir 设置评测工作目录的父目录和单个任务的磁盘配额（MB，0 为不限），
+// 并清理崩溃的 worker 遗留的工作目录。配额是 dir 所在磁盘上的 loop 文件系统，不占内存。
+func (s *JudgeService) SetWorkspaceDir(dir string, diskQuotaMB int) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create workspace dir: %w", err)
+	}
+	s.workspaceDir = dir
+	s.workspaceDiskQuota = int64(diskQuotaMB) << 20
+	if n := reapWorkspaces(dir); n > 0 {
+		log.Printf("Removed %d orphan workspaces in %s", n, dir)
+	}
+	return nil
+}
+
+// 工作目录结构：
+//
+//	{workspaceDir}/oj-{submit_id}.{pid}/   配置了配额时是独立的 loop 文件系统
+//	├── box/   源码和编译产物，只读挂载到沙箱 /app
+//	└── out/   每个测试点的选手输出，不对沙箱可见
+//
+// 工作目录本身只有 root 可访问，box 交给沙箱用户以便编译器写入产物。
+func (s *JudgeService) createWorkspace(id string, lang queue.Language, code string) (string, error) {
+	name := fmt.Sprintf("%s%s.%d", workspacePrefix, filepath.Base(id), os.Getpid())
+	workspace := filepath.Join(s.workspaceDir, name)
+	if err := os.Mkdir(workspace, 0700); err != nil {
+		return "", fmt.Errorf("failed to create workspace: %w", err)
+	}
+	if s.workspaceDiskQuota > 0 {
+		if err := mountQuota(workspace, s.workspaceDiskQuota); err != nil {
+			os.Remove(workspace)
+			return "", fmt.Errorf("failed to mount workspace quota: %w", err)
+		}
+	}
+
+	if err := os.Mkdir(filepath.Join(workspace, "out"), 0700); err != nil {
+		s.cleanup(workspace)
+		return "", fmt.Errorf("failed to create workspace: %w", err)
+	}
+	if err := s.prepareBox(workspace, lang, code); err != nil {
+		s.cleanup(workspace)
+		return "", err
+	}
+	return workspace, nil
+}
+
+// cleanup 卸载配额并删除工作目录
+func (s *JudgeService) cleanup(workspace string) {
+	if err := removeWorkspace(workspace); err != nil {
+		log.Printf("Failed to cleanup workspace %s: %v", workspace, err)
+	}
+}
+
+func removeWorkspace(workspace string) error {
+	if err := unmountQuota(workspace); err != nil {
+		return err
+	}
+	return os.RemoveAll(workspace)
+}
+
+// reapWorkspaces 删除所属进程已不存在的工作目录，返回删除的数量。
+// 同一台机器上的其他 worker 进程正在使用的目录会保留。
+func reapWorkspaces(dir string) int {
+	paths, _ := filepath.Glob(filepath.Join(dir, workspacePrefix+"*"))
+	n := 0
+	for _, path := range paths {
+		if fi, err := os.Lstat(path); err != nil || !fi.IsDir() {
+			continue
+		}
+		if pid := workspaceOwner(path); pid > 0 && pid != os.Getpid() && processAlive(pid) {
+			continue
+		}
+		if err := removeWorkspace(path); err != nil {
+			log.Printf("Failed to remove orphan workspace %s: %v", path, err)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// workspaceOwner 从目录名解析创建它的 worker 进程号，无法解析时返回 0
+func workspaceOwner(path string) int {
+	name := filepath.Base(path)
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return 0
+	}
+	pid, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
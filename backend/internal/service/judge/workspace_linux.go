@@ -0,0 +1,64 @@
+package judge
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// mountQuota 在工作目录上挂载限定大小的磁盘文件系统，写满后返回 ENOSPC。
+// 使用稀疏镜像文件 {dir}.img 格式化为 ext4 后经 loop 设备挂载，占用的是磁盘而不是内存。
+func mountQuota(dir string, size int64) error {
+	img := quotaImage(dir)
+	f, err := os.OpenFile(img, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(size)
+	f.Close()
+	if err != nil {
+		os.Remove(img)
+		return err
+	}
+	if err := runQuotaCmd("mkfs.ext4", "-q", "-F", "-m", "0", img); err != nil {
+		os.Remove(img)
+		return err
+	}
+	if err := runQuotaCmd("mount", "-o", "loop,nosuid,nodev", img, dir); err != nil {
+		os.Remove(img)
+		return err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		unmountQuota(dir)
+		return err
+	}
+	return nil
+}
+
+// unmountQuota 卸载工作目录上的文件系统并删除镜像文件，
+// 目录不是挂载点（非 root 时为 EPERM）时忽略
+func unmountQuota(dir string) error {
+	err := syscall.Unmount(dir, syscall.MNT_DETACH)
+	if err != nil && err != syscall.EINVAL && err != syscall.ENOENT && err != syscall.EPERM {
+		return fmt.Errorf("unmount %s: %w", dir, err)
+	}
+	// loop 设备以 autoclear 方式挂载，卸载后自动释放，镜像文件可直接删除
+	if err := os.Remove(quotaImage(dir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func quotaImage(dir string) string {
+	return dir + ".img"
+}
+
+func runQuotaCmd(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
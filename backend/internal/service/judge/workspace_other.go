@@ -0,0 +1,14 @@
+//go:build !linux
+
+package judge
+
+import "errors"
+
+// mountQuota 非 Linux 平台不支持工作目录配额
+func mountQuota(dir string, size int64) error {
+	return errors.New("workspace quota is not supported on this platform")
+}
+
+func unmountQuota(dir string) error {
+	return nil
+}
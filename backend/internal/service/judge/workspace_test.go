@@ -0,0 +1,35 @@
+package judge
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestReapWorkspaces(t *testing.T) {
+	dir := t.TempDir()
+	live := filepath.Join(dir, "oj-a1b2-c3d4."+strconv.Itoa(os.Getppid()))
+	dead := filepath.Join(dir, "oj-e5f6-0708.999999999")
+	unnamed := filepath.Join(dir, "oj-legacy")
+	other := filepath.Join(dir, ".oj-sandbox")
+	for _, d := range []string{live, dead, unnamed, other} {
+		if err := os.MkdirAll(filepath.Join(d, "box"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := reapWorkspaces(dir); n != 2 {
+		t.Errorf("reapWorkspaces() = %d, want 2", n)
+	}
+	for _, d := range []string{live, other} {
+		if _, err := os.Stat(d); err != nil {
+			t.Errorf("%s should be kept: %v", d, err)
+		}
+	}
+	for _, d := range []string{dead, unnamed} {
+		if _, err := os.Stat(d); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", d)
+		}
+	}
+}
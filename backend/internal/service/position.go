@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// throughputWindow 统计通道评测吞吐的时间窗口
+const throughputWindow = 10 * time.Minute
+
+// QueuePosition 排队中的提交在所在通道的位置和预计开始时间
+type QueuePosition struct {
+	QueueClass       string     `json:"queue_class"`
+	Lane             string     `json:"lane"`
+	Position         int64      `json:"position"`           // 1 表示下一个评测
+	Throughput       float64    `json:"throughput"`         // 该通道最近 10 分钟平均每分钟完成的评测数
+	EstimatedStartAt *time.Time `json:"estimated_start_at"` // 最近没有完成的评测时无法估计，为空
+}
+
+// SubmissionDetail 提交详情，排队中时附带排队位置
+type SubmissionDetail struct {
+	*model.Submission
+	Status string         `json:"status"`
+	Queue  *QueuePosition `json:"queue,omitempty"`
+}
+
+// GetDetail 获取提交详情，排队位置计算失败时只记录日志
+func (s *SubmitService) GetDetail(submitID string) (*SubmissionDetail, error) {
+	submission, err := s.repo.GetBySubmitID(submitID)
+	if err != nil {
+		return nil, err
+	}
+	detail := &SubmissionDetail{Submission: submission, Status: submissionStatus(submission)}
+	detail.Queue, err = s.QueuePosition(submission)
+	if err != nil {
+		log.Printf("Failed to get queue position of %s: %v", submitID, err)
+	}
+	return detail, nil
+}
+
+// QueuePosition 提交在所在通道的排队位置，不在排队时返回 nil
+func (s *SubmitService) QueuePosition(submission *model.Submission) (*QueuePosition, error) {
+	if submission.QueuedAt == nil || submissionStatus(submission) != queue.StatusPending {
+		return nil, nil
+	}
+	class, lane, userID, ok := queue.ParseTaskSubject(submission.QueueSubject)
+	if !ok {
+		return nil, nil
+	}
+	lanePrefix := submission.QueueSubject[:strings.LastIndex(submission.QueueSubject, ".")+1]
+
+	mine, err := s.repo.CountPendingAhead(submission.QueueSubject, *submission.QueuedAt)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.repo.CountPendingBySubject(lanePrefix)
+	if err != nil {
+		return nil, err
+	}
+	last, lastKnown, err := s.repo.LastStartedUser(lanePrefix)
+	if err != nil {
+		return nil, err
+	}
+	var before, after []int64
+	for subject, n := range pending {
+		_, _, other, ok := queue.ParseTaskSubject(subject)
+		if !ok || other == userID {
+			continue
+		}
+		// 不知道轮询位置时按先于本人轮到估计上限
+		if !lastKnown || servedBefore(other, userID, last) {
+			before = append(before, n)
+		} else {
+			after = append(after, n)
+		}
+	}
+	ahead := queueAhead(mine, before, after)
+
+	now := time.Now()
+	finished, err := s.repo.CountFinishedSince(lanePrefix, now.Add(-throughputWindow))
+	if err != nil {
+		return nil, err
+	}
+	return &QueuePosition{
+		QueueClass:       class,
+		Lane:             lane,
+		Position:         ahead + 1,
+		Throughput:       float64(finished) / throughputWindow.Minutes(),
+		EstimatedStartAt: estimateStart(now, ahead, finished, throughputWindow),
+	}, nil
+}
+
+// queueAhead 排在前面的任务数。本人先入队的都在前面；worker 在有任务的用户间轮询，
+// 轮到本人的第 mine+1 条之前，本轮先于本人轮到的用户（before）每人最多取走 mine+1 条，
+// 在本人之后轮到的用户（after）每人最多 mine 条
+func queueAhead(mine int64, before, after []int64) int64 {
+	ahead := mine
+	for _, n := range before {
+		ahead += min(n, mine+1)
+	}
+	for _, n := range after {
+		ahead += min(n, mine)
+	}
+	return ahead
+}
+
+// servedBefore 从上次取到的用户 last 之后开始轮询时，other 是否先于 user 轮到，
+// 顺序与 worker 的 userOrder 一致：ID 大于 last 的升序在前，其余升序在后
+func servedBefore(other, user, last int64) bool {
+	if (other > last) != (user > last) {
+		return other > last
+	}
+	return other < user
+}
+
+// estimateStart 按最近 window 内完成的评测数估计前面 ahead 个任务评完的时间
+func estimateStart(now time.Time, ahead, finished int64, window time.Duration) *time.Time {
+	if finished == 0 {
+		return nil
+	}
+	at := now.Add(time.Duration(ahead) * window / time.Duration(finished))
+	return &at
+}
+
+// submissionStatus 提交的生命周期状态
+func submissionStatus(submission *model.Submission) string {
+	var result struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(submission.JudgeResult), &result); err != nil {
+		return ""
+	}
+	return result.Status
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueueAhead(t *testing.T) {
+	// 本人前面 2 条，之后轮到的用户分别有 5、1、0 条
+	if got := queueAhead(2, nil, []int64{5, 1, 0}); got != 5 {
+		t.Fatalf("queueAhead = %d, want 5", got)
+	}
+	// 先于本人轮到的用户在本人第 mine+1 条之前多取一条
+	if got := queueAhead(2, []int64{5, 1, 0}, nil); got != 6 {
+		t.Fatalf("queueAhead = %d, want 6", got)
+	}
+	// 本人排第一时，只有先于本人轮到的用户各有一条在前面
+	if got := queueAhead(0, []int64{5}, []int64{3}); got != 1 {
+		t.Fatalf("queueAhead = %d, want 1", got)
+	}
+	if got := queueAhead(0, nil, []int64{5, 3}); got != 0 {
+		t.Fatalf("queueAhead = %d, want 0", got)
+	}
+}
+
+func TestServedBefore(t *testing.T) {
+	// 上次取到用户 5，轮询顺序为 7 9 2 5
+	tests := []struct {
+		other, user int64
+		want        bool
+	}{
+		{other: 7, user: 9, want: true},
+		{other: 9, user: 2, want: true},
+		{other: 2, user: 7, want: false},
+		{other: 5, user: 2, want: false},
+		{other: 2, user: 5, want: true},
+	}
+	for _, tt := range tests {
+		if got := servedBefore(tt.other, tt.user, 5); got != tt.want {
+			t.Errorf("servedBefore(%d, %d, 5) = %v, want %v", tt.other, tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateStart(t *testing.T) {
+	now := time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+	if got := estimateStart(now, 3, 0, 10*time.Minute); got != nil {
+		t.Fatalf("no throughput should give no estimate, got %v", got)
+	}
+	// 10 分钟完成 20 个，每个 30 秒
+	got := estimateStart(now, 4, 20, 10*time.Minute)
+	if got == nil || !got.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("estimateStart = %v", got)
+	}
+}
@@ -2,24 +2,68 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
 	"github.com/oj/oj-backend/internal/repository"
+	"github.com/oj/oj-backend/internal/service/judge"
 )
 
-var ErrProblemNotFound = errors.New("problem not found")
+var (
+	ErrProblemNotFound   = errors.New("problem not found")
+	ErrInvalidChecker    = errors.New("invalid checker")
+	ErrInvalidSPJ        = errors.New("invalid special judge")
+	ErrInvalidInteractor = errors.New("invalid interactor")
+	ErrInvalidSubtasks   = errors.New("invalid subtasks")
+	ErrInvalidJudgeMode  = errors.New("invalid judge mode")
+	ErrInvalidValidator  = errors.New("invalid validator")
+	ErrInvalidStd        = errors.New("invalid reference solution")
+	ErrInvalidGenerators = errors.New("invalid generators")
+)
+
+const (
+	// 等待 worker 试编译 SPJ 的超时
+	spjCompileTimeout = 30 * time.Second
+	// spj_compile_out 列宽
+	spjCompileOutLimit = 500
+)
 
 type ProblemService struct {
-	repo  *repository.ProblemRepo
-	minio *minio.Client
+	repo     *repository.ProblemRepo
+	langRepo *repository.LanguageRepo
+	minio    *minio.Client
+	nc       *nats.Conn
+	js       jetstream.JetStream
+
+	testDataBucket string
+	// requestValidate 请求 worker 校验测试数据，默认经 NATS 发送
+	requestValidate func(req *queue.ValidateRequest) (*queue.ValidateReply, error)
 }
 
-func NewProblemService(repo *repository.ProblemRepo, minioClient *minio.Client) *ProblemService {
-	return &ProblemService{
-		repo:  repo,
-		minio: minioClient,
+func NewProblemService(repo *repository.ProblemRepo, langRepo *repository.LanguageRepo, minioClient *minio.Client, nc *nats.Conn, js jetstream.JetStream) *ProblemService {
+	s := &ProblemService{
+		repo:     repo,
+		langRepo: langRepo,
+		minio:    minioClient,
+		nc:       nc,
+		js:       js,
+
+		testDataBucket: "oj-testdata",
+	}
+	s.requestValidate = func(req *queue.ValidateRequest) (*queue.ValidateReply, error) {
+		if s.nc == nil {
+			return nil, fmt.Errorf("nats not configured")
+		}
+		return queue.RequestValidate(s.nc, req, testDataValidateTimeout)
 	}
+	return s
 }
 
 func (s *ProblemService) GetByID(id int64) (*model.Problem, error) {
@@ -40,9 +84,32 @@ func (s *ProblemService) List(params repository.ListProblemParams) ([]model.Prob
 }
 
 func (s *ProblemService) Create(problem *model.Problem, userID int64) error {
+	if err := validateChecker(problem); err != nil {
+		return err
+	}
+	spjLang, err := s.spjLanguage(problem)
+	if err != nil {
+		return err
+	}
+	if err := s.validateInteractor(problem); err != nil {
+		return err
+	}
+	if _, err := judge.ParseSubtasks(problem.TestCases); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSubtasks, err)
+	}
+	if err := judge.ValidateJudgeMode(problem.JudgeMode); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJudgeMode, err)
+	}
+	if err := s.validatePrograms(problem); err != nil {
+		return err
+	}
 	problem.CreatedBy = &userID
 	problem.UpdatedBy = &userID
-	return s.repo.Create(problem)
+	if err := s.repo.Create(problem); err != nil {
+		return err
+	}
+	s.checkSPJ(problem, spjLang)
+	return nil
 }
 
 func (s *ProblemService) Update(id int64, problem *model.Problem, userID int64) error {
@@ -50,13 +117,173 @@ func (s *ProblemService) Update(id int64, problem *model.Problem, userID int64)
 	if err != nil {
 		return ErrProblemNotFound
 	}
-	_ = existing // suppress unused variable warning
+	// 测试数据和统计不由题目表单维护，保存时沿用
+	problem.TestDataZip = existing.TestDataZip
+	problem.TestDataHash = existing.TestDataHash
+	problem.TestDataCheck = existing.TestDataCheck
+	problem.TestDataGen = existing.TestDataGen
+	problem.SubmitCount = existing.SubmitCount
+	problem.AcceptCount = existing.AcceptCount
+	problem.AcceptRate = existing.AcceptRate
+	problem.CreatedBy = existing.CreatedBy
+	problem.CreatedAt = existing.CreatedAt
+
+	if err := validateChecker(problem); err != nil {
+		return err
+	}
+	spjLang, err := s.spjLanguage(problem)
+	if err != nil {
+		return err
+	}
+	if err := s.validateInteractor(problem); err != nil {
+		return err
+	}
+	if _, err := judge.ParseSubtasks(problem.TestCases); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSubtasks, err)
+	}
+	if err := judge.ValidateJudgeMode(problem.JudgeMode); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJudgeMode, err)
+	}
+	if err := s.validatePrograms(problem); err != nil {
+		return err
+	}
 
 	problem.ID = id
+	// 校验器变化后旧的校验结果作废；要公开而结果已过期时后台重新检查，通过后才能公开
+	validatorChanged := problem.ValidatorLang != existing.ValidatorLang || problem.ValidatorCode != existing.ValidatorCode
+	if validatorChanged {
+		problem.TestDataCheck = ""
+	}
+	recheck := validatorChanged || (problem.IsPublic && parseTestDataCheck(problem) == nil)
+	if err := checkPublishable(problem); err != nil {
+		if recheck && !validatorChanged {
+			s.startTestDataCheck(problem, false)
+		}
+		return err
+	}
 	problem.UpdatedBy = &userID
-	return s.repo.Update(problem)
+	if err := s.repo.Update(problem); err != nil {
+		return err
+	}
+	if recheck {
+		s.startTestDataCheck(problem, false)
+	}
+	s.checkSPJ(problem, spjLang)
+	return nil
 }
 
 func (s *ProblemService) Delete(id int64) error {
 	return s.repo.Delete(id)
 }
+
+// validateChecker 校验比对方式，空值按 token 处理
+func validateChecker(problem *model.Problem) error {
+	if problem.Checker == "" {
+		problem.Checker = judge.CheckerToken
+	}
+	if _, err := judge.NewChecker(problem.Checker, problem.CheckerEpsilon); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChecker, err)
+	}
+	return nil
+}
+
+// spjLanguage 校验 SPJ 配置并返回其语言，非 SPJ 题目返回 nil
+func (s *ProblemService) spjLanguage(problem *model.Problem) (*model.Language, error) {
+	if !problem.IsSPJ {
+		return nil, nil
+	}
+	if strings.TrimSpace(problem.SpjCode) == "" {
+		return nil, fmt.Errorf("%w: spj_code is required", ErrInvalidSPJ)
+	}
+	lang, err := s.langRepo.GetBySlug(problem.SpjLang)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown spj_lang %q", ErrInvalidSPJ, problem.SpjLang)
+	}
+	return lang, nil
+}
+
+// validateInteractor 交互题必须配置交互器，且不能同时是 SPJ 题
+func (s *ProblemService) validateInteractor(problem *model.Problem) error {
+	if !problem.IsInteractive {
+		return nil
+	}
+	if problem.IsSPJ {
+		return fmt.Errorf("%w: interactive problem cannot use spj", ErrInvalidInteractor)
+	}
+	if strings.TrimSpace(problem.InteractorCode) == "" {
+		return fmt.Errorf("%w: interactor_code is required", ErrInvalidInteractor)
+	}
+	if _, err := s.langRepo.GetBySlug(problem.InteractorLang); err != nil {
+		return fmt.Errorf("%w: unknown interactor_lang %q", ErrInvalidInteractor, problem.InteractorLang)
+	}
+	return nil
+}
+
+// validatePrograms 校验器和标程可选，填写了源码时语言必须存在
+func (s *ProblemService) validatePrograms(problem *model.Problem) error {
+	if strings.TrimSpace(problem.ValidatorCode) != "" {
+		if _, err := s.langRepo.GetBySlug(problem.ValidatorLang); err != nil {
+			return fmt.Errorf("%w: unknown validator_lang %q", ErrInvalidValidator, problem.ValidatorLang)
+		}
+	}
+	if strings.TrimSpace(problem.StdCode) != "" {
+		if _, err := s.langRepo.GetBySlug(problem.StdLang); err != nil {
+			return fmt.Errorf("%w: unknown std_lang %q", ErrInvalidStd, problem.StdLang)
+		}
+	}
+	return s.validateGenerators(problem)
+}
+
+// validateGenerators 生成器和脚本可选，配置了脚本时引用的生成器必须存在
+func (s *ProblemService) validateGenerators(problem *model.Problem) error {
+	gens, err := judge.ParseGenerators(problem.Generators)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidGenerators, err)
+	}
+	names := make(map[string]bool, len(gens))
+	for _, g := range gens {
+		if _, err := s.langRepo.GetBySlug(g.Lang); err != nil {
+			return fmt.Errorf("%w: generator %s: unknown lang %q", ErrInvalidGenerators, g.Name, g.Lang)
+		}
+		names[g.Name] = true
+	}
+	if strings.TrimSpace(problem.GenScript) == "" {
+		return nil
+	}
+	cmds, err := judge.ParseGenScript(problem.GenScript)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidGenerators, err)
+	}
+	for _, cmd := range cmds {
+		if !names[cmd.Generator] {
+			return fmt.Errorf("%w: test %d uses unknown generator %s", ErrInvalidGenerators, cmd.Test, cmd.Generator)
+		}
+	}
+	return nil
+}
+
+// checkSPJ 请求 worker 试编译 SPJ，并把编译信息写回 SpjCompileOut，
+// 出题人保存后即可看到编译错误。没有 worker 响应时只记日志，不影响保存。
+func (s *ProblemService) checkSPJ(problem *model.Problem, lang *model.Language) {
+	if lang == nil || s.nc == nil {
+		return
+	}
+
+	reply, err := queue.RequestSPJCompile(s.nc, &queue.SPJCompileRequest{
+		ProblemID: problem.ID,
+		SPJ:       queue.SPJ{Language: toQueueLanguage(lang), Code: problem.SpjCode},
+	}, spjCompileTimeout)
+	if err != nil {
+		log.Printf("SPJ compile check for problem %d failed: %v", problem.ID, err)
+		return
+	}
+
+	out := reply.CompileInfo
+	if len(out) > spjCompileOutLimit {
+		out = strings.ToValidUTF8(out[:spjCompileOutLimit], "")
+	}
+	problem.SpjCompileOut = out
+	if err := s.repo.UpdateSPJCompileOut(problem.ID, out); err != nil {
+		log.Printf("Failed to save spj compile output for problem %d: %v", problem.ID, err)
+	}
+}
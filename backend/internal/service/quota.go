@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQuotaExceeded 评测时长配额已用完
+var ErrQuotaExceeded = errors.New("judge quota exceeded")
+
+// JudgeQuota 每个用户的评测时长配额，按自然小时和自然日统计。评测时长为提交从开始评测
+// 到结束的时间，尚未评测完的提交按 PendingCharge 预先计入；Hourly/Daily 为 0 表示不限制。
+type JudgeQuota struct {
+	Hourly        time.Duration
+	Daily         time.Duration
+	ContestBoost  int // 比赛进行中参赛者提交比赛题目时的配额倍数
+	PendingCharge time.Duration
+}
+
+// QuotaWindow 一个统计窗口的配额用量
+type QuotaWindow struct {
+	LimitSec int64     `json:"limit_sec"` // 0 表示不限制
+	UsedSec  int64     `json:"used_sec"`
+	Pending  int64     `json:"pending"` // 尚未评测完的提交数，已按预估计入 used_sec
+	ResetAt  time.Time `json:"reset_at"`
+}
+
+// exceeded 配额是否已用完
+func (w QuotaWindow) exceeded() bool {
+	return w.LimitSec > 0 && w.UsedSec >= w.LimitSec
+}
+
+// QuotaUsage 用户的评测配额用量
+type QuotaUsage struct {
+	Unlimited    bool        `json:"unlimited"`     // 管理员不受限制
+	ContestBoost int         `json:"contest_boost"` // 比赛中的配额倍数
+	Hourly       QuotaWindow `json:"hourly"`
+	Daily        QuotaWindow `json:"daily"`
+}
+
+// limit 实际限额，boosted 时按比赛倍数放大
+func (q JudgeQuota) limit(base time.Duration, boosted bool) time.Duration {
+	if boosted && q.ContestBoost > 1 {
+		return base * time.Duration(q.ContestBoost)
+	}
+	return base
+}
+
+// quotaWindows 当前自然小时和自然日的起点
+func quotaWindows(now time.Time) (hour, day time.Time) {
+	hour = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+	day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return hour, day
+}
+
+// SetQuota 设置评测时长配额，未设置时不限制
+func (s *SubmitService) SetQuota(quota JudgeQuota) {
+	s.quota = quota
+}
+
+// QuotaUsage 用户当前的评测配额用量，限额为不含比赛倍数的基础值
+func (s *SubmitService) QuotaUsage(userID int64, isAdmin bool) (*QuotaUsage, error) {
+	usage, err := s.quotaUsage(userID, false, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if isAdmin {
+		usage.Unlimited = true
+		usage.Hourly.LimitSec = 0
+		usage.Daily.LimitSec = 0
+	}
+	return usage, nil
+}
+
+func (s *SubmitService) quotaUsage(userID int64, boosted bool, now time.Time) (*QuotaUsage, error) {
+	hour, day := quotaWindows(now)
+	hourly, err := s.quotaWindow(userID, hour, hour.Add(time.Hour), s.quota.limit(s.quota.Hourly, boosted))
+	if err != nil {
+		return nil, err
+	}
+	daily, err := s.quotaWindow(userID, day, day.AddDate(0, 0, 1), s.quota.limit(s.quota.Daily, boosted))
+	if err != nil {
+		return nil, err
+	}
+	return &QuotaUsage{ContestBoost: s.quota.ContestBoost, Hourly: hourly, Daily: daily}, nil
+}
+
+// quotaWindow 统计 [start, end) 内创建的提交占用的评测时长
+func (s *SubmitService) quotaWindow(userID int64, start, end time.Time, limit time.Duration) (QuotaWindow, error) {
+	judged, pending, err := s.repo.JudgeTimeSince(userID, start)
+	if err != nil {
+		return QuotaWindow{}, fmt.Errorf("failed to count judge time: %w", err)
+	}
+	used := judged + time.Duration(pending)*s.quota.PendingCharge
+	return QuotaWindow{
+		LimitSec: int64(limit / time.Second),
+		UsedSec:  int64(used / time.Second),
+		Pending:  pending,
+		ResetAt:  end,
+	}, nil
+}
+
+// checkQuota 提交前检查评测配额。管理员不受限制；比赛进行中参赛者提交比赛题目时限额按倍数放大
+func (s *SubmitService) checkQuota(userID int64, params SubmitParams) error {
+	if params.IsAdmin || (s.quota.Hourly == 0 && s.quota.Daily == 0) {
+		return nil
+	}
+	now := time.Now()
+	boosted := params.ContestID != nil && s.inRunningContest(userID, *params.ContestID, now)
+	usage, err := s.quotaUsage(userID, boosted, now)
+	if err != nil {
+		return err
+	}
+	for _, w := range []struct {
+		name   string
+		window QuotaWindow
+	}{{"hourly", usage.Hourly}, {"daily", usage.Daily}} {
+		if w.window.exceeded() {
+			return fmt.Errorf("%w: %s quota of %ds used up, resets at %s",
+				ErrQuotaExceeded, w.name, w.window.LimitSec, w.window.ResetAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// inRunningContest 用户是否为进行中比赛的参赛者
+func (s *SubmitService) inRunningContest(userID, contestID int64, now time.Time) bool {
+	contest, err := s.contestRepo.GetByID(contestID)
+	if err != nil || now.Before(contest.StartTime) || now.After(contest.EndTime) {
+		return false
+	}
+	_, err = s.contestRepo.GetParticipant(contestID, userID)
+	return err == nil
+}
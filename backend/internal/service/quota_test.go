@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotaWindows(t *testing.T) {
+	now := time.Date(2024, 3, 5, 14, 37, 12, 0, time.UTC)
+	hour, day := quotaWindows(now)
+	if !hour.Equal(time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)) {
+		t.Fatalf("hour = %v", hour)
+	}
+	if !day.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("day = %v", day)
+	}
+}
+
+func TestQuotaLimit(t *testing.T) {
+	q := JudgeQuota{Hourly: 10 * time.Minute, ContestBoost: 4}
+	if got := q.limit(q.Hourly, false); got != 10*time.Minute {
+		t.Fatalf("limit = %v", got)
+	}
+	if got := q.limit(q.Hourly, true); got != 40*time.Minute {
+		t.Fatalf("boosted limit = %v", got)
+	}
+	// 不限制的窗口放大后仍不限制
+	if got := q.limit(q.Daily, true); got != 0 {
+		t.Fatalf("boosted unlimited = %v", got)
+	}
+
+	if (QuotaWindow{LimitSec: 0, UsedSec: 100}).exceeded() {
+		t.Fatal("unlimited window should never be exceeded")
+	}
+	if !(QuotaWindow{LimitSec: 600, UsedSec: 600}).exceeded() {
+		t.Fatal("window at limit should be exceeded")
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/repository"
+)
+
+var (
+	ErrInvalidRun     = errors.New("invalid run request")
+	ErrRunUnavailable = errors.New("no judge worker available")
+)
+
+const (
+	// 等待 worker 编译并运行的超时
+	runTimeout = 30 * time.Second
+
+	maxRunCodeSize  = 64 << 10
+	maxRunStdinSize = 1 << 20
+)
+
+// RunService 自定义输入运行，结果直接返回，不产生提交记录
+type RunService struct {
+	langRepo    *repository.LanguageRepo
+	problemRepo *repository.ProblemRepo
+	nc          *nats.Conn
+}
+
+func NewRunService(langRepo *repository.LanguageRepo, problemRepo *repository.ProblemRepo, nc *nats.Conn) *RunService {
+	return &RunService{
+		langRepo:    langRepo,
+		problemRepo: problemRepo,
+		nc:          nc,
+	}
+}
+
+type RunParams struct {
+	LanguageID int64  `json:"language_id" binding:"required"`
+	Code       string `json:"code" binding:"required"`
+	Stdin      string `json:"stdin"`
+	ProblemID  *int64 `json:"problem_id"` // 指定时使用题目的时间和内存限制
+}
+
+func (s *RunService) Run(userID int64, params RunParams) (*queue.RunReply, error) {
+	if len(params.Code) > maxRunCodeSize {
+		return nil, fmt.Errorf("%w: code exceeds %d bytes", ErrInvalidRun, maxRunCodeSize)
+	}
+	if len(params.Stdin) > maxRunStdinSize {
+		return nil, fmt.Errorf("%w: stdin exceeds %d bytes", ErrInvalidRun, maxRunStdinSize)
+	}
+	lang, err := s.langRepo.GetByID(params.LanguageID)
+	if err != nil || !lang.Enabled {
+		return nil, fmt.Errorf("%w: unknown language %d", ErrInvalidRun, params.LanguageID)
+	}
+
+	req := &queue.RunRequest{
+		RunID:    uuid.New().String(),
+		UserID:   userID,
+		Language: toQueueLanguage(lang),
+		Code:     params.Code,
+		CodeHash: codeHash(params.Code),
+		Stdin:    params.Stdin,
+	}
+	if params.ProblemID != nil {
+		problem, err := s.problemRepo.GetByID(*params.ProblemID)
+		if err != nil {
+			return nil, ErrProblemNotFound
+		}
+		req.TimeLimit = problem.TimeLimit
+		req.MemoryLimit = problem.MemoryLimit
+	}
+
+	if s.nc == nil {
+		return nil, ErrRunUnavailable
+	}
+	reply, err := queue.RequestRun(s.nc, req, runTimeout)
+	if errors.Is(err, nats.ErrNoResponders) {
+		return nil, ErrRunUnavailable
+	}
+	return reply, err
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"github.com/minio/minio-go/v7"
+	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/oj/oj-backend/internal/repository"
 	"github.com/redis/go-redis/v9"
@@ -9,20 +10,32 @@ import (
 
 // Services 所有 Service 的集合
 type Services struct {
-	User    *UserService
-	Problem *ProblemService
-	Submit  *SubmitService
-	Contest *ContestService
-	Lang    *LanguageService
+	User     *UserService
+	Problem  *ProblemService
+	Submit   *SubmitService
+	Contest  *ContestService
+	Lang     *LanguageService
+	Run      *RunService
+	Hack     *HackService
+	Solution *SolutionService
+	Worker   *WorkerService
+	DLQ      *DeadLetterService
 }
 
 // NewServices 创建 Service 集合
-func NewServices(repos *repository.Repositories, rdb *redis.Client, js jetstream.JetStream, minioClient *minio.Client, jwtSecret string) *Services {
+func NewServices(repos *repository.Repositories, rdb *redis.Client, nc *nats.Conn, js jetstream.JetStream, minioClient *minio.Client, jwtSecret string) *Services {
+	problemService := NewProblemService(repos.Problem, repos.Lang, minioClient, nc, js)
+	submitService := NewSubmitService(repos.Submit, repos.Lang, repos.Problem, repos.Contest, js, minioClient, jwtSecret)
 	return &Services{
-		User:    NewUserService(repos.User, rdb, jwtSecret),
-		Problem: NewProblemService(repos.Problem, minioClient),
-		Submit:  NewSubmitService(repos.Submit, repos.Lang, js, minioClient, jwtSecret),
-		Contest: NewContestService(repos.Contest, repos.Submit),
-		Lang:    NewLanguageService(repos.Lang),
+		User:     NewUserService(repos.User, rdb, jwtSecret),
+		Problem:  problemService,
+		Submit:   submitService,
+		Contest:  NewContestService(repos.Contest, repos.Submit),
+		Lang:     NewLanguageService(repos.Lang),
+		Run:      NewRunService(repos.Lang, repos.Problem, nc),
+		Hack:     NewHackService(repos.Hack, repos.Submit, repos.Contest, repos.Problem, repos.Lang, problemService, js),
+		Solution: NewSolutionService(repos.Solution, repos.Problem, repos.Lang, js),
+		Worker:   NewWorkerService(js),
+		DLQ:      NewDeadLetterService(js, repos.Submit, repos.DLQAudit, submitService),
 	}
 }
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/repository"
+)
+
+var (
+	ErrSolutionNotFound   = errors.New("solution not found")
+	ErrInvocationNotFound = errors.New("invocation not found")
+	ErrInvalidSolution    = errors.New("invalid solution")
+)
+
+const (
+	// solutionCodeLimit 参考解源码上限
+	solutionCodeLimit = 64 << 10
+	// invocationListLimit 运行记录列表返回的条数
+	invocationListLimit = 20
+)
+
+// solutionVerdicts 参考解可以标注的预期结论
+var solutionVerdicts = map[string]bool{
+	queue.VerdictAC:      true,
+	queue.VerdictWA:      true,
+	queue.VerdictTLE:     true,
+	queue.VerdictMLE:     true,
+	queue.VerdictRE:      true,
+	queue.VerdictOLE:     true,
+	queue.VerdictPE:      true,
+	queue.VerdictPartial: true,
+}
+
+type SolutionService struct {
+	repo        *repository.SolutionRepo
+	problemRepo *repository.ProblemRepo
+	langRepo    *repository.LanguageRepo
+	js          jetstream.JetStream
+}
+
+func NewSolutionService(repo *repository.SolutionRepo, problemRepo *repository.ProblemRepo, langRepo *repository.LanguageRepo, js jetstream.JetStream) *SolutionService {
+	return &SolutionService{
+		repo:        repo,
+		problemRepo: problemRepo,
+		langRepo:    langRepo,
+		js:          js,
+	}
+}
+
+type SolutionParams struct {
+	Name     string   `json:"name" binding:"required"`
+	Lang     string   `json:"lang" binding:"required"`
+	Code     string   `json:"code" binding:"required"`
+	Expected []string `json:"expected" binding:"required"`
+}
+
+// Create 登记参考解
+func (s *SolutionService) Create(problemID, userID int64, params SolutionParams) (*model.ProblemSolution, error) {
+	if _, err := s.problemRepo.GetByID(problemID); err != nil {
+		return nil, ErrProblemNotFound
+	}
+	if len(params.Code) > solutionCodeLimit {
+		return nil, fmt.Errorf("%w: code exceeds %d bytes", ErrInvalidSolution, solutionCodeLimit)
+	}
+	if _, err := s.langRepo.GetBySlug(params.Lang); err != nil {
+		return nil, fmt.Errorf("%w: unknown lang %q", ErrInvalidSolution, params.Lang)
+	}
+	expected := make([]string, 0, len(params.Expected))
+	for _, v := range params.Expected {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if !solutionVerdicts[v] {
+			return nil, fmt.Errorf("%w: unknown expected verdict %q", ErrInvalidSolution, v)
+		}
+		expected = append(expected, v)
+	}
+	if len(expected) == 0 {
+		return nil, fmt.Errorf("%w: expected is required", ErrInvalidSolution)
+	}
+
+	solution := &model.ProblemSolution{
+		ProblemID: problemID,
+		Name:      params.Name,
+		Lang:      params.Lang,
+		Code:      params.Code,
+		Expected:  expected,
+		CreatedBy: &userID,
+	}
+	if err := s.repo.Create(solution); err != nil {
+		return nil, err
+	}
+	return solution, nil
+}
+
+func (s *SolutionService) List(problemID int64) ([]model.ProblemSolution, error) {
+	return s.repo.ListByProblem(problemID)
+}
+
+func (s *SolutionService) Delete(problemID, solutionID int64) error {
+	solution, err := s.repo.GetByID(solutionID)
+	if err != nil || solution.ProblemID != problemID {
+		return ErrSolutionNotFound
+	}
+	return s.repo.Delete(solutionID)
+}
+
+// Invoke 在题目当前的完整测试集上运行全部参考解，异步执行
+func (s *SolutionService) Invoke(problemID, userID int64) (*model.ProblemInvocation, error) {
+	problem, err := s.problemRepo.GetByID(problemID)
+	if err != nil {
+		return nil, ErrProblemNotFound
+	}
+	if problem.TestDataZip == "" {
+		return nil, ErrNoTestData
+	}
+	solutions, err := s.repo.ListByProblem(problemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(solutions) == 0 {
+		return nil, fmt.Errorf("%w: problem has no solutions", ErrInvalidSolution)
+	}
+	if s.js == nil {
+		return nil, fmt.Errorf("jetstream not configured")
+	}
+
+	qp, err := toQueueProblem(s.langRepo, problem)
+	if err != nil {
+		return nil, err
+	}
+	task := &queue.InvokeTask{Problem: *qp, CreatedAt: time.Now()}
+	for _, sol := range solutions {
+		lang, err := s.langRepo.GetBySlug(sol.Lang)
+		if err != nil {
+			return nil, fmt.Errorf("%w: solution %s: unknown lang %q", ErrInvalidSolution, sol.Name, sol.Lang)
+		}
+		task.Solutions = append(task.Solutions, queue.InvokeSolution{
+			ID:       sol.ID,
+			Name:     sol.Name,
+			Language: toQueueLanguage(lang),
+			Code:     sol.Code,
+			CodeHash: codeHash(sol.Code),
+			Expected: sol.Expected,
+		})
+	}
+
+	invocation := &model.ProblemInvocation{
+		ProblemID:    problemID,
+		TestDataHash: problem.TestDataHash,
+		Status:       queue.InvokeStatusPending,
+		Result:       `{"status":"PENDING"}`,
+		CreatedBy:    &userID,
+	}
+	if err := s.repo.CreateInvocation(invocation); err != nil {
+		return nil, err
+	}
+	task.InvocationID = invocation.ID
+	if err := queue.PublishInvoke(context.Background(), s.js, task); err != nil {
+		return nil, err
+	}
+	return invocation, nil
+}
+
+func (s *SolutionService) GetInvocation(problemID, invocationID int64) (*model.ProblemInvocation, error) {
+	invocation, err := s.repo.GetInvocation(invocationID)
+	if err != nil || invocation.ProblemID != problemID {
+		return nil, ErrInvocationNotFound
+	}
+	return invocation, nil
+}
+
+func (s *SolutionService) ListInvocations(problemID int64) ([]model.ProblemInvocation, error) {
+	return s.repo.ListInvocations(problemID, invocationListLimit)
+}
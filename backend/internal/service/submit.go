@@ -2,9 +2,13 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,23 +17,29 @@ import (
 	"github.com/oj/oj-backend/internal/model"
 	"github.com/oj/oj-backend/internal/queue"
 	"github.com/oj/oj-backend/internal/repository"
+	"github.com/oj/oj-backend/internal/service/judge"
 )
 
 type SubmitService struct {
-	repo       *repository.SubmitRepo
-	langRepo   *repository.LanguageRepo
-	js         jetstream.JetStream
-	minio      *minio.Client
-	codeBucket string
+	repo        *repository.SubmitRepo
+	langRepo    *repository.LanguageRepo
+	problemRepo *repository.ProblemRepo
+	contestRepo *repository.ContestRepo
+	js          jetstream.JetStream
+	minio       *minio.Client
+	codeBucket  string
+	quota       JudgeQuota
 }
 
-func NewSubmitService(repo *repository.SubmitRepo, langRepo *repository.LanguageRepo, js jetstream.JetStream, minioClient *minio.Client, codeBucket string) *SubmitService {
+func NewSubmitService(repo *repository.SubmitRepo, langRepo *repository.LanguageRepo, problemRepo *repository.ProblemRepo, contestRepo *repository.ContestRepo, js jetstream.JetStream, minioClient *minio.Client, codeBucket string) *SubmitService {
 	return &SubmitService{
-		repo:       repo,
-		langRepo:   langRepo,
-		js:         js,
-		minio:      minioClient,
-		codeBucket: codeBucket,
+		repo:        repo,
+		langRepo:    langRepo,
+		problemRepo: problemRepo,
+		contestRepo: contestRepo,
+		js:          js,
+		minio:       minioClient,
+		codeBucket:  codeBucket,
 	}
 }
 
@@ -39,6 +49,22 @@ type SubmitParams struct {
 	Code           string `json:"code" binding:"required"`
 	ContestID      *int64 `json:"contest_id"`
 	IdempotencyKey string `json:"idempotency_key"`
+	SampleOnly     bool   `json:"sample_only"` // 只评测题面样例
+	IsAdmin        bool   `json:"-"`           // 管理员不受评测配额限制
+}
+
+// ErrNoSamples 样例评测时题目没有可用的样例
+var ErrNoSamples = errors.New("problem has no samples")
+
+var (
+	ErrSubmissionNotFound = errors.New("submission not found")
+	ErrInvalidRejudge     = errors.New("invalid rejudge request")
+)
+
+// RejudgeParams 重判指定的提交，或题目下的全部提交
+type RejudgeParams struct {
+	SubmitIDs []string `json:"submit_ids"`
+	ProblemID *int64   `json:"problem_id"`
 }
 
 func (s *SubmitService) Create(userID int64, params SubmitParams) (*model.Submission, error) {
@@ -53,6 +79,20 @@ func (s *SubmitService) Create(userID int64, params SubmitParams) (*model.Submis
 		}
 	}
 
+	if err := s.checkQuota(userID, params); err != nil {
+		return nil, err
+	}
+
+	if params.SampleOnly {
+		problem, err := s.problemRepo.GetByID(params.ProblemID)
+		if err != nil {
+			return nil, ErrProblemNotFound
+		}
+		if samples, err := parseSamples(problem.SampleIO); err != nil || len(samples) == 0 {
+			return nil, ErrNoSamples
+		}
+	}
+
 	submitID := uuid.New().String()
 
 	submission := &model.Submission{
@@ -63,8 +103,10 @@ func (s *SubmitService) Create(userID int64, params SubmitParams) (*model.Submis
 		ContestID:   params.ContestID,
 		Code:        params.Code, // 直接存DB
 		CodeLength:  len(params.Code),
+		CodeHash:    codeHash(params.Code),
 		JudgeResult: `{"status":"PENDING"}`,
-		IsContest:   params.ContestID != nil,
+		IsContest:   params.ContestID != nil && !params.SampleOnly,
+		IsSample:    params.SampleOnly,
 		CreatedAt:   time.Now(),
 	}
 
@@ -83,7 +125,7 @@ func (s *SubmitService) Create(userID int64, params SubmitParams) (*model.Submis
 	}
 
 	// 发布评测任务
-	if err := s.publishJudgeTask(submission); err != nil {
+	if err := s.publishJudgeTask(submission, judgeLane(submission, false)); err != nil {
 		return nil, err
 	}
 
@@ -94,8 +136,8 @@ func (s *SubmitService) GetBySubmitID(submitID string) (*model.Submission, error
 	return s.repo.GetBySubmitID(submitID)
 }
 
-func (s *SubmitService) ListByUser(userID int64, problemID *int64, status *string, page, pageSize int) ([]model.Submission, int64, error) {
-	return s.repo.ListByUser(userID, problemID, status, page, pageSize)
+func (s *SubmitService) ListByUser(userID int64, problemID *int64, status, verdict *string, includeSample bool, page, pageSize int) ([]model.Submission, int64, error) {
+	return s.repo.ListByUser(userID, problemID, status, verdict, includeSample, page, pageSize)
 }
 
 // CreateContest 创建比赛提交
@@ -104,6 +146,53 @@ func (s *SubmitService) CreateContest(userID, contestID int64, params SubmitPara
 	return s.Create(userID, params)
 }
 
+// Rejudge 重新评测，任务进入 rejudge 通道，不与正常提交抢占 worker。
+// 样例评测不参与重判；指定的提交有一个不存在就整体拒绝。返回已重新入队的提交。
+func (s *SubmitService) Rejudge(params RejudgeParams) ([]string, error) {
+	var submitIDs []string
+	switch {
+	case len(params.SubmitIDs) > 0 && params.ProblemID != nil:
+		return nil, fmt.Errorf("%w: specify either submit_ids or problem_id", ErrInvalidRejudge)
+	case len(params.SubmitIDs) > 0:
+		submitIDs = params.SubmitIDs
+	case params.ProblemID != nil:
+		if _, err := s.problemRepo.GetByID(*params.ProblemID); err != nil {
+			return nil, ErrProblemNotFound
+		}
+		ids, err := s.repo.ListSubmitIDsByProblem(*params.ProblemID)
+		if err != nil {
+			return nil, err
+		}
+		submitIDs = ids
+	default:
+		return nil, fmt.Errorf("%w: submit_ids or problem_id is required", ErrInvalidRejudge)
+	}
+
+	submissions := make([]*model.Submission, 0, len(submitIDs))
+	for _, id := range submitIDs {
+		submission, err := s.repo.GetBySubmitID(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrSubmissionNotFound, id)
+		}
+		if !submission.IsSample {
+			submissions = append(submissions, submission)
+		}
+	}
+
+	rejudged := make([]string, 0, len(submissions))
+	for _, submission := range submissions {
+		// 先改回 PENDING 再发布，避免覆盖 worker 写入的 COMPILING
+		if err := s.repo.ResetPending(submission.SubmitID); err != nil {
+			return rejudged, fmt.Errorf("failed to reset submission %s: %w", submission.SubmitID, err)
+		}
+		if err := s.publishJudgeTask(submission, judgeLane(submission, true)); err != nil {
+			return rejudged, fmt.Errorf("failed to publish submission %s: %w", submission.SubmitID, err)
+		}
+		rejudged = append(rejudged, submission.SubmitID)
+	}
+	return rejudged, nil
+}
+
 // saveCode 保存代码到 MinIO (可选功能)
 func (s *SubmitService) saveCode(submitID, code string) error {
 	if s.minio == nil {
@@ -120,23 +209,61 @@ func (s *SubmitService) saveCode(submitID, code string) error {
 	return err
 }
 
-func (s *SubmitService) publishJudgeTask(submission *model.Submission) error {
+// publishJudgeTask 按题目、语言和比赛的当前配置生成评测任务，发布到用户在 lane 通道上的 subject
+func (s *SubmitService) publishJudgeTask(submission *model.Submission, lane string) error {
 	// 获取语言信息用于分流
 	lang, err := s.langRepo.GetByID(submission.LanguageID)
 	if err != nil {
 		return fmt.Errorf("failed to get language: %w", err)
 	}
 
-	// 构建轻量级任务消息 (只带 submit_id)
+	problem, err := s.problemRepo.GetByID(submission.ProblemID)
+	if err != nil {
+		return fmt.Errorf("failed to get problem: %w", err)
+	}
+
+	qp, err := toQueueProblem(s.langRepo, problem)
+	if err != nil {
+		return err
+	}
+
+	// 任务消息携带评测所需的全部题目限制和语言配置，worker 不再回查数据库
 	task := &queue.JudgeTask{
 		SubmitID:       submission.SubmitID,
 		IdempotencyKey: submission.IdempotencyKey,
-		Language: queue.Language{
-			ID:   lang.ID,
-			Slug: lang.Slug,
-		},
-		RetryCount: 0,
-		CreatedAt:  time.Now(),
+		Problem:        *qp,
+		Language:       toQueueLanguage(lang),
+		Code:           submission.Code,
+		CodeHash:       submission.CodeHash,
+		User:           queue.User{ID: submission.UserID},
+		RetryCount:     0,
+		CreatedAt:      time.Now(),
+	}
+
+	// 比赛赛制决定测试点是否遇错即停
+	if submission.ContestID != nil {
+		contest, err := s.contestRepo.GetByID(*submission.ContestID)
+		if err != nil {
+			return fmt.Errorf("failed to get contest: %w", err)
+		}
+		task.Contest = &queue.Contest{
+			ID:             contest.ID,
+			Type:           contest.Type,
+			RuleType:       contest.RuleType,
+			PenaltyMinutes: contest.PenaltyMinutes,
+			FrozenMinutes:  contest.FrozenMinutes,
+			IsVirtual:      contest.IsVirtual,
+		}
+	}
+
+	// 样例评测只运行题面样例，测试数据随任务下发
+	if submission.IsSample {
+		samples, err := parseSamples(problem.SampleIO)
+		if err != nil {
+			return fmt.Errorf("problem %d: %w", problem.ID, err)
+		}
+		task.SampleOnly = true
+		task.Samples = samples
 	}
 
 	data, err := json.Marshal(task)
@@ -144,24 +271,94 @@ func (s *SubmitService) publishJudgeTask(submission *model.Submission) error {
 		return err
 	}
 
-	// 根据语言分流
-	subject := getTaskSubject(lang.Slug)
+	// 按语言配置的队列类别和提交来源分流，同一通道内每个用户一个 subject
+	subject := queue.TaskSubject(lang.QueueClass, lane, submission.UserID)
+	if err := s.repo.MarkQueued(submission.SubmitID, subject, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark submission queued: %w", err)
+	}
 
 	_, err = s.js.Publish(context.Background(), subject, data)
 	return err
 }
 
-// getTaskSubject 根据语言 slug 返回对应的 NATS subject
-func getTaskSubject(langSlug string) string {
-	heavyLangs := map[string]bool{
-		"cpp17":  true,
-		"c11":    true,
-		"java17": true,
+// judgeLane 评测任务的优先级通道：重判进 rejudge，比赛正式提交进 contest，
+// 其余（练习和样例评测）进 practice
+func judgeLane(submission *model.Submission, rejudge bool) string {
+	switch {
+	case rejudge:
+		return queue.LaneRejudge
+	case submission.IsContest:
+		return queue.LaneContest
+	default:
+		return queue.LanePractice
+	}
+}
+
+// toQueueProblem 评测任务中的题目配置：限制、比对方式、子任务和 SPJ/交互器
+func toQueueProblem(langRepo *repository.LanguageRepo, problem *model.Problem) (*queue.Problem, error) {
+	qp := &queue.Problem{
+		ID:           problem.ID,
+		Title:        problem.Title,
+		TimeLimit:    problem.TimeLimit,
+		MemoryLimit:  problem.MemoryLimit,
+		StackLimit:   problem.StackLimit,
+		IsSPJ:        problem.IsSPJ,
+		TestDataZip:  problem.TestDataZip,
+		TestDataHash: problem.TestDataHash,
+
+		Checker:        problem.Checker,
+		CheckerEpsilon: problem.CheckerEpsilon,
+		JudgeMode:      problem.JudgeMode,
+	}
+
+	subtasks, err := judge.ParseSubtasks(problem.TestCases)
+	if err != nil {
+		return nil, fmt.Errorf("problem %d: %w", problem.ID, err)
+	}
+	qp.Subtasks = subtasks
+
+	if problem.IsSPJ {
+		qp.SPJ, err = problemProgram(langRepo, problem.SpjLang, problem.SpjCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get spj language: %w", err)
+		}
+	}
+	if problem.IsInteractive {
+		qp.IsInteractive = true
+		qp.Interactor, err = problemProgram(langRepo, problem.InteractorLang, problem.InteractorCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get interactor language: %w", err)
+		}
+	}
+	return qp, nil
+}
+
+// problemProgram 题目自带程序（SPJ、交互器、校验器、标程）的语言和源码
+func problemProgram(langRepo *repository.LanguageRepo, slug, code string) (*queue.SPJ, error) {
+	lang, err := langRepo.GetBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	return &queue.SPJ{Language: toQueueLanguage(lang), Code: code}, nil
+}
+
+// parseSamples 解析 Problem.SampleIO：[{"input": "...", "output": "..."}]
+func parseSamples(raw string) ([]queue.Sample, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return nil, nil
 	}
-	if heavyLangs[langSlug] {
-		return "judge.tasks.heavy"
+	var samples []queue.Sample
+	if err := json.Unmarshal([]byte(raw), &samples); err != nil {
+		return nil, fmt.Errorf("invalid sample_io: %w", err)
 	}
-	return "judge.tasks.light"
+	return samples, nil
+}
+
+// codeHash 代码的 sha256，用于查重和编译产物缓存
+func codeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
 }
 
 // stringReader 实现 io.Reader
@@ -178,3 +375,20 @@ func (r *stringReader) Read(p []byte) (n int, err error) {
 	r.pos += n
 	return n, nil
 }
+
+// toQueueLanguage 任务消息中的语言配置
+func toQueueLanguage(lang *model.Language) queue.Language {
+	return queue.Language{
+		ID:             lang.ID,
+		Slug:           lang.Slug,
+		SourceFilename: lang.SourceFilename,
+		CompileCmd:     lang.CompileCmd,
+		CompileTimeout: lang.CompileTimeout,
+		RunCmd:         lang.RunCmd,
+		RunTimeout:     lang.RunTimeout,
+		TimeFactor:     lang.TimeFactor,
+		MemoryFactor:   lang.MemoryFactor,
+		OutputLimit:    lang.OutputLimit,
+		PidsLimit:      lang.PidsLimit,
+	}
+}
@@ -0,0 +1,498 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/service/judge"
+)
+
+var (
+	ErrNoTestData      = errors.New("problem has no test data")
+	ErrInvalidTestData = errors.New("invalid test data")
+)
+
+const (
+	// testDataTimeout 下载和上传测试数据压缩包的超时
+	testDataTimeout = 5 * time.Minute
+	// testDataValidateTimeout 等待 worker 校验全部输入文件的超时
+	testDataValidateTimeout = 5 * time.Minute
+	// maxTestDataSize 上传的测试数据压缩包上限
+	maxTestDataSize = 1 << 30
+)
+
+// 测试数据校验状态，pending/running 表示后台校验尚未完成
+const (
+	TestDataPending = "pending"
+	TestDataRunning = "running"
+	TestDataPassed  = "passed"
+	TestDataFailed  = "failed"
+	TestDataError   = "error" // 校验器编译失败或 worker 无响应，结果未知
+)
+
+// TestDataCheck 校验器对测试数据的检查结果，存于 Problem.TestDataCheck，Hash 为被检查的压缩包。
+// Republish 表示题目因测试数据变化被撤下，校验通过后自动重新公开。
+type TestDataCheck struct {
+	Hash        string                 `json:"hash"`
+	Status      string                 `json:"status"`
+	StartedAt   time.Time              `json:"started_at"`
+	Republish   bool                   `json:"republish,omitempty"`
+	Invalid     int                    `json:"invalid"`
+	Files       []queue.FileValidation `json:"files,omitempty"`
+	CompileInfo string                 `json:"compile_info,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// TestDataUpload 上传测试数据的结果，题目未配置校验器时 Check 为 nil，
+// 否则为 pending 状态的校验记录，结果写入题目的 test_data_check；
+// 已公开的题目先撤下，Check.Republish 为 true
+type TestDataUpload struct {
+	URL   string         `json:"url"`
+	Hash  string         `json:"hash"`
+	Check *TestDataCheck `json:"check,omitempty"`
+}
+
+// UploadTestData 上传测试数据压缩包并更新题目，配置了校验器时在后台检查全部输入文件
+func (s *ProblemService) UploadTestData(problemID int64, r io.Reader) (*TestDataUpload, error) {
+	problem, err := s.repo.GetByID(problemID)
+	if err != nil {
+		return nil, ErrProblemNotFound
+	}
+	if s.minio == nil {
+		return nil, fmt.Errorf("minio not configured")
+	}
+
+	f, err := os.CreateTemp("", "oj-testdata-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(r, maxTestDataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive test data: %w", err)
+	}
+	if n > maxTestDataSize {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidTestData, maxTestDataSize)
+	}
+	if _, err := checkTestDataZip(f, n); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTestData, err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testDataTimeout)
+	defer cancel()
+	location, err := s.uploadTestData(ctx, problemID, hash, f)
+	if err != nil {
+		return nil, err
+	}
+	check, err := s.replaceTestData(problem, location, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &TestDataUpload{URL: location, Hash: hash, Check: check}, nil
+}
+
+// ApplyGeneratedTestData 换上 worker 生成的测试数据，与上传一样撤下已公开的题目并在后台校验
+func (s *ProblemService) ApplyGeneratedTestData(problemID int64, location, hash string) error {
+	problem, err := s.repo.GetByID(problemID)
+	if err != nil {
+		return ErrProblemNotFound
+	}
+	_, err = s.replaceTestData(problem, location, hash)
+	return err
+}
+
+// replaceTestData 更新题目的测试数据并在后台校验。配置了校验器的已公开题目先撤下再换数据，
+// 新数据在校验通过前不会出现在公开题库中，通过后自动重新公开；未通过时保持撤下，由管理员修正后再公开。
+func (s *ProblemService) replaceTestData(problem *model.Problem, location, hash string) (*TestDataCheck, error) {
+	republish := false
+	if strings.TrimSpace(problem.ValidatorCode) != "" {
+		republish = problem.IsPublic || pendingRepublish(problem)
+		if problem.IsPublic {
+			if err := s.repo.UpdatePublic(problem.ID, false); err != nil {
+				return nil, err
+			}
+			problem.IsPublic = false
+		}
+	}
+	if err := s.repo.UpdateTestData(problem.ID, location, hash); err != nil {
+		return nil, err
+	}
+	problem.TestDataZip = location
+	problem.TestDataHash = hash
+	return s.startTestDataCheck(problem, republish), nil
+}
+
+// pendingRepublish 题目是否因上一次测试数据变化被撤下、仍等待校验通过后重新公开
+func pendingRepublish(problem *model.Problem) bool {
+	if problem.IsPublic || problem.TestDataCheck == "" {
+		return false
+	}
+	var check TestDataCheck
+	if err := json.Unmarshal([]byte(problem.TestDataCheck), &check); err != nil {
+		return false
+	}
+	return check.Republish
+}
+
+// ValidateTestData 在后台重新用校验器检查题目当前的测试数据，返回 pending 状态的校验记录
+func (s *ProblemService) ValidateTestData(problemID int64) (*TestDataCheck, error) {
+	problem, err := s.repo.GetByID(problemID)
+	if err != nil {
+		return nil, ErrProblemNotFound
+	}
+	if problem.TestDataZip == "" {
+		return nil, ErrNoTestData
+	}
+	if strings.TrimSpace(problem.ValidatorCode) == "" {
+		return nil, fmt.Errorf("%w: problem has no validator", ErrInvalidValidator)
+	}
+	return s.startTestDataCheck(problem, pendingRepublish(problem)), nil
+}
+
+// GenerateTestData 提交测试数据生成任务：worker 按脚本运行生成器、用标程得到答案，
+// 打包上传后更新题目的测试数据，进度见 TestDataGen
+func (s *ProblemService) GenerateTestData(problemID int64) (*queue.GenerateResult, error) {
+	problem, err := s.repo.GetByID(problemID)
+	if err != nil {
+		return nil, ErrProblemNotFound
+	}
+	if strings.TrimSpace(problem.StdCode) == "" {
+		return nil, fmt.Errorf("%w: std_code is required", ErrInvalidStd)
+	}
+	if strings.TrimSpace(problem.GenScript) == "" {
+		return nil, fmt.Errorf("%w: gen_script is required", ErrInvalidGenerators)
+	}
+	if err := s.validateGenerators(problem); err != nil {
+		return nil, err
+	}
+	if s.js == nil {
+		return nil, fmt.Errorf("jetstream not configured")
+	}
+
+	task := &queue.GenerateTask{ProblemID: problem.ID, Script: problem.GenScript, CreatedAt: time.Now()}
+	gens, _ := judge.ParseGenerators(problem.Generators)
+	for _, g := range gens {
+		prog, err := problemProgram(s.langRepo, g.Lang, g.Code)
+		if err != nil {
+			return nil, fmt.Errorf("%w: generator %s: unknown lang %q", ErrInvalidGenerators, g.Name, g.Lang)
+		}
+		task.Generators = append(task.Generators, queue.Generator{Name: g.Name, SPJ: *prog})
+	}
+	std, err := problemProgram(s.langRepo, problem.StdLang, problem.StdCode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown std_lang %q", ErrInvalidStd, problem.StdLang)
+	}
+	task.Std = *std
+
+	result := &queue.GenerateResult{Status: queue.GenerateStatusPending}
+	data, _ := json.Marshal(result)
+	if err := s.repo.UpdateTestDataGen(problem.ID, string(data)); err != nil {
+		return nil, err
+	}
+	if err := queue.PublishGenerate(context.Background(), s.js, task); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// startTestDataCheck 记录 pending 状态后在后台请求 worker 校验全部输入文件，
+// 与测试数据生成一样通过题目字段查看进度：pending → running → passed/failed/error。
+// republish 时校验通过后重新公开题目。未配置校验器或测试数据时返回 nil。
+func (s *ProblemService) startTestDataCheck(problem *model.Problem, republish bool) *TestDataCheck {
+	if strings.TrimSpace(problem.ValidatorCode) == "" || problem.TestDataZip == "" {
+		return nil
+	}
+
+	check := &TestDataCheck{Hash: problem.TestDataHash, Status: TestDataPending, StartedAt: time.Now(), Republish: republish}
+	data, _ := json.Marshal(check)
+	problem.TestDataCheck = string(data)
+	if err := s.repo.UpdateTestDataCheck(problem.ID, problem.TestDataCheck); err != nil {
+		log.Printf("Failed to save test data check for problem %d: %v", problem.ID, err)
+		return check
+	}
+
+	snapshot := *problem
+	go s.runTestDataCheck(&snapshot, *check)
+	return check
+}
+
+// runTestDataCheck 执行一次后台校验。每次写入都以上一次写入的内容为前提，
+// 期间题目换了测试数据或开始了新的校验时放弃本次结果，也不会重新公开题目。
+func (s *ProblemService) runTestDataCheck(problem *model.Problem, check TestDataCheck) {
+	prev := problem.TestDataCheck
+	save := func(c *TestDataCheck) bool {
+		data, _ := json.Marshal(c)
+		ok, err := s.repo.SwapTestDataCheck(problem.ID, prev, string(data))
+		if err != nil {
+			log.Printf("Failed to save test data check for problem %d: %v", problem.ID, err)
+			return false
+		}
+		prev = string(data)
+		return ok
+	}
+
+	check.Status = TestDataRunning
+	if !save(&check) {
+		return
+	}
+	result := s.checkTestData(problem, check)
+	if !save(result) || !result.Republish || result.Status != TestDataPassed {
+		return
+	}
+	if err := s.repo.UpdatePublic(problem.ID, true); err != nil {
+		log.Printf("Failed to republish problem %d: %v", problem.ID, err)
+	}
+}
+
+// checkTestData 请求 worker 用校验器检查全部输入文件，worker 无响应时记为 error 状态
+func (s *ProblemService) checkTestData(problem *model.Problem, check TestDataCheck) *TestDataCheck {
+	check.Status = TestDataError
+	validator, err := problemProgram(s.langRepo, problem.ValidatorLang, problem.ValidatorCode)
+	switch {
+	case err != nil:
+		check.Error = fmt.Sprintf("unknown validator_lang %q", problem.ValidatorLang)
+	default:
+		reply, err := s.requestValidate(&queue.ValidateRequest{Problem: queue.Problem{
+			ID:           problem.ID,
+			TestDataZip:  problem.TestDataZip,
+			TestDataHash: problem.TestDataHash,
+			Validator:    validator,
+		}})
+		if err != nil {
+			check.Error = err.Error()
+			break
+		}
+		if reply.CompileInfo != "" {
+			check.CompileInfo = reply.CompileInfo
+			break
+		}
+		check.Files = reply.Files
+		check.Status = TestDataPassed
+		for _, f := range reply.Files {
+			if !f.Valid {
+				check.Invalid++
+				check.Status = TestDataFailed
+			}
+		}
+	}
+	return &check
+}
+
+// parseTestDataCheck 解析题目保存的校验结果，没有或已过期（与当前测试数据哈希不符）时返回 nil
+func parseTestDataCheck(problem *model.Problem) *TestDataCheck {
+	if problem.TestDataCheck == "" {
+		return nil
+	}
+	var check TestDataCheck
+	if err := json.Unmarshal([]byte(problem.TestDataCheck), &check); err != nil || check.Hash != problem.TestDataHash {
+		return nil
+	}
+	return &check
+}
+
+// checkPublishable 配置了校验器的题目，只有当前测试数据全部通过校验才能公开
+func checkPublishable(problem *model.Problem) error {
+	if !problem.IsPublic || strings.TrimSpace(problem.ValidatorCode) == "" || problem.TestDataZip == "" {
+		return nil
+	}
+	check := parseTestDataCheck(problem)
+	switch {
+	case check == nil || check.Status == TestDataError:
+		return fmt.Errorf("%w: test data has not been validated", ErrInvalidTestData)
+	case check.Status == TestDataPending || check.Status == TestDataRunning:
+		return fmt.Errorf("%w: test data validation is in progress", ErrInvalidTestData)
+	case check.Status == TestDataFailed:
+		return fmt.Errorf("%w: %d input files failed validation", ErrInvalidTestData, check.Invalid)
+	}
+	return nil
+}
+
+// checkTestDataZip 检查压缩包结构：顶层的 xxx.in 都有对应的 xxx.out 或 xxx.ans，返回测试点数
+func checkTestDataZip(r io.ReaderAt, size int64) (int, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return 0, fmt.Errorf("not a zip file: %v", err)
+	}
+
+	names := make(map[string]bool, len(zr.File))
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "/") || strings.Contains(f.Name, "..") {
+			return 0, fmt.Errorf("illegal path %s", f.Name)
+		}
+		names[f.Name] = true
+	}
+
+	count := 0
+	for name := range names {
+		base, ok := strings.CutSuffix(name, ".in")
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		if !names[base+".out"] && !names[base+".ans"] {
+			return 0, fmt.Errorf("missing answer for %s", name)
+		}
+		count++
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("no test cases found")
+	}
+	return count, nil
+}
+
+// SetTestDataBucket 设置测试数据所在的 bucket，与 worker 的 TESTDATA_BUCKET 一致
+func (s *ProblemService) SetTestDataBucket(bucket string) {
+	s.testDataBucket = bucket
+}
+
+// AppendTestCase 在题目测试数据末尾追加一组输入/答案，生成新的压缩包并更新题目的
+// TestDataZip/TestDataHash，与上传一样在后台校验。压缩包按哈希命名，worker 缓存以哈希为键，旧版本自然失效。
+func (s *ProblemService) AppendTestCase(problemID int64, input, answer string) error {
+	problem, err := s.repo.GetByID(problemID)
+	if err != nil {
+		return ErrProblemNotFound
+	}
+	if problem.TestDataZip == "" {
+		return ErrNoTestData
+	}
+	if s.minio == nil {
+		return fmt.Errorf("minio not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testDataTimeout)
+	defer cancel()
+
+	src, err := s.downloadTestData(ctx, problem.TestDataZip)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "oj-testdata-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	h := sha256.New()
+	if err := appendZipCase(src, io.MultiWriter(dst, h), input, answer); err != nil {
+		return fmt.Errorf("failed to rebuild test data: %w", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	location, err := s.uploadTestData(ctx, problemID, hash, dst)
+	if err != nil {
+		return err
+	}
+	_, err = s.replaceTestData(problem, location, hash)
+	return err
+}
+
+// downloadTestData 把测试数据压缩包下载到临时文件
+func (s *ProblemService) downloadTestData(ctx context.Context, location string) (*os.File, error) {
+	bucket, key := s.testDataObject(location)
+	obj, err := s.minio.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get test data %s: %w", location, err)
+	}
+	defer obj.Close()
+
+	f, err := os.CreateTemp("", "oj-testdata-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, obj); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to download test data %s: %w", location, err)
+	}
+	return f, nil
+}
+
+// uploadTestData 上传到 problems/{id}/{hash}.zip，返回 minio:// 形式的位置
+func (s *ProblemService) uploadTestData(ctx context.Context, problemID int64, hash string, f *os.File) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("problems/%d/%s.zip", problemID, hash)
+	_, err = s.minio.PutObject(ctx, s.testDataBucket, key, f, info.Size(), minio.PutObjectOptions{
+		ContentType: "application/zip",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload test data: %w", err)
+	}
+	return fmt.Sprintf("minio://%s/%s", s.testDataBucket, key), nil
+}
+
+// testDataObject 解析 minio://bucket/key，不带前缀时视为测试数据 bucket 中的 key
+func (s *ProblemService) testDataObject(location string) (string, string) {
+	if rest, ok := strings.CutPrefix(location, "minio://"); ok {
+		if bucket, key, ok := strings.Cut(rest, "/"); ok {
+			return bucket, key
+		}
+	}
+	return s.testDataBucket, strings.TrimPrefix(location, "/")
+}
+
+// appendZipCase 复制压缩包内容，并以下一个编号追加 {n}.in/{n}.out
+func appendZipCase(src *os.File, w io.Writer, input, answer string) error {
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	r, err := zip.NewReader(src, info.Size())
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	next := 1
+	for _, f := range r.File {
+		if base, ok := strings.CutSuffix(path.Base(f.Name), ".in"); ok {
+			if n, err := strconv.Atoi(base); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+		if err := zw.Copy(f); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range []struct{ name, content string }{
+		{fmt.Sprintf("%d.in", next), input},
+		{fmt.Sprintf("%d.out", next), answer},
+	} {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, file.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
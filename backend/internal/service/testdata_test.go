@@ -0,0 +1,236 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oj/oj-backend/internal/model"
+	"github.com/oj/oj-backend/internal/queue"
+	"github.com/oj/oj-backend/internal/repository"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func buildZip(t *testing.T, names ...string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestCheckTestDataZip(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     []string
+		wantCount int
+		wantErr   bool
+	}{
+		{name: "out and ans", files: []string{"1.in", "1.out", "2.in", "2.ans"}, wantCount: 2},
+		{name: "missing answer", files: []string{"1.in", "1.out", "2.in"}, wantErr: true},
+		{name: "nested ignored", files: []string{"1.in", "1.out", "extra/2.in"}, wantCount: 1},
+		{name: "empty", files: []string{"readme.txt"}, wantErr: true},
+		{name: "path traversal", files: []string{"../1.in", "1.in", "1.out"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := buildZip(t, tt.files...)
+			count, err := checkTestDataZip(r, r.Size())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkTestDataZip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestCheckPublishable(t *testing.T) {
+	problem := func(check string) *model.Problem {
+		return &model.Problem{
+			IsPublic:      true,
+			ValidatorCode: "int main() {}",
+			TestDataZip:   "minio://oj-testdata/problems/1/abc.zip",
+			TestDataHash:  "abc",
+			TestDataCheck: check,
+		}
+	}
+	tests := []struct {
+		name    string
+		problem *model.Problem
+		wantErr bool
+	}{
+		{name: "passed", problem: problem(`{"hash":"abc","status":"passed"}`)},
+		{name: "failed", problem: problem(`{"hash":"abc","status":"failed","invalid":2}`), wantErr: true},
+		{name: "stale", problem: problem(`{"hash":"old","status":"passed"}`), wantErr: true},
+		{name: "not validated", problem: problem(""), wantErr: true},
+		{name: "pending", problem: problem(`{"hash":"abc","status":"pending"}`), wantErr: true},
+		{name: "running", problem: problem(`{"hash":"abc","status":"running"}`), wantErr: true},
+		{name: "private", problem: &model.Problem{ValidatorCode: "x", TestDataZip: "z", TestDataCheck: `{"status":"failed"}`}},
+		{name: "no validator", problem: &model.Problem{IsPublic: true, TestDataZip: "z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPublishable(tt.problem)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkPublishable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidTestData) {
+				t.Errorf("error %v is not ErrInvalidTestData", err)
+			}
+		})
+	}
+}
+
+func TestPendingRepublish(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem *model.Problem
+		want    bool
+	}{
+		{name: "withdrawn", problem: &model.Problem{TestDataCheck: `{"hash":"abc","status":"failed","republish":true}`}, want: true},
+		{name: "public", problem: &model.Problem{IsPublic: true, TestDataCheck: `{"status":"passed","republish":true}`}},
+		{name: "private", problem: &model.Problem{TestDataCheck: `{"hash":"abc","status":"failed"}`}},
+		{name: "no check", problem: &model.Problem{}},
+	}
+	for _, tt := range tests {
+		if got := pendingRepublish(tt.problem); got != tt.want {
+			t.Errorf("%s: pendingRepublish = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// testDB 连接 TEST_DATABASE_URL 指定的 PostgreSQL，在独立 schema 中建表，未配置时跳过
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	cfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("oj_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	db, err := gorm.Open(postgres.Open(dsn+sep+"search_path="+schema), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Language{}, &model.Problem{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// waitCheck 等待后台校验写入最终结果
+func waitCheck(t *testing.T, repo *repository.ProblemRepo, id int64) *model.Problem {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		problem, err := repo.GetByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if check := parseTestDataCheck(problem); check != nil && check.Status != TestDataPending && check.Status != TestDataRunning {
+			return problem
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("test data check did not finish")
+	return nil
+}
+
+// 已公开的题目换上校验不通过的测试数据：立即撤下，校验失败后保持撤下；修正后校验通过再自动公开
+func TestReplaceTestDataPublicProblemFailingValidator(t *testing.T) {
+	db := testDB(t)
+	if err := db.Create(&model.Language{Name: "C++17", Slug: "cpp17", RunCmd: `["./main"]`}).Error; err != nil {
+		t.Fatal(err)
+	}
+	problem := &model.Problem{
+		Title:         "A+B",
+		IsPublic:      true,
+		ValidatorLang: "cpp17",
+		ValidatorCode: "int main() {}",
+		TestDataZip:   "minio://oj-testdata/problems/1/old.zip",
+		TestDataHash:  "old",
+		TestDataCheck: `{"hash":"old","status":"passed"}`,
+	}
+	if err := db.Create(problem).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repository.NewProblemRepo(db)
+	s := NewProblemService(repo, repository.NewLanguageRepo(db), nil, nil, nil)
+	release := make(chan struct{})
+	var valid atomic.Bool
+	s.requestValidate = func(req *queue.ValidateRequest) (*queue.ValidateReply, error) {
+		<-release
+		return &queue.ValidateReply{Files: []queue.FileValidation{{File: "1.in", Valid: valid.Load()}}}, nil
+	}
+
+	check, err := s.replaceTestData(problem, "minio://oj-testdata/problems/1/bad.zip", "bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check == nil || check.Status != TestDataPending || !check.Republish {
+		t.Fatalf("check = %+v, want pending with republish", check)
+	}
+	// 校验完成前新数据已生效，题目不再公开
+	saved, err := repo.GetByID(problem.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.IsPublic || saved.TestDataHash != "bad" {
+		t.Fatalf("during validation: public=%v hash=%s, want private with new data", saved.IsPublic, saved.TestDataHash)
+	}
+	close(release)
+
+	saved = waitCheck(t, repo, problem.ID)
+	if check := parseTestDataCheck(saved); check.Status != TestDataFailed {
+		t.Fatalf("check status = %s, want failed", check.Status)
+	}
+	if saved.IsPublic {
+		t.Fatal("problem republished with invalid test data")
+	}
+
+	// 换上合法数据后校验通过，自动恢复公开
+	valid.Store(true)
+	if _, err := s.replaceTestData(saved, "minio://oj-testdata/problems/1/good.zip", "good"); err != nil {
+		t.Fatal(err)
+	}
+	saved = waitCheck(t, repo, problem.ID)
+	if check := parseTestDataCheck(saved); check.Status != TestDataPassed {
+		t.Fatalf("check status = %s, want passed", check.Status)
+	}
+	if !saved.IsPublic {
+		t.Fatal("problem not republished after test data passed validation")
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/oj/oj-backend/internal/queue"
+)
+
+// workerQueryTimeout 读取心跳 KV 的超时
+const workerQueryTimeout = 5 * time.Second
+
+// WorkerService 评测机状态，数据来自 worker 上报到 NATS KV 的心跳
+type WorkerService struct {
+	js jetstream.JetStream
+}
+
+func NewWorkerService(js jetstream.JetStream) *WorkerService {
+	return &WorkerService{js: js}
+}
+
+// WorkerStatus 带失联标记的 worker 心跳
+type WorkerStatus struct {
+	queue.WorkerInfo
+	Stale       bool  `json:"stale"`
+	LastSeenSec int64 `json:"last_seen_sec"`
+}
+
+func newWorkerStatus(info queue.WorkerInfo, now time.Time) WorkerStatus {
+	return WorkerStatus{
+		WorkerInfo:  info,
+		Stale:       info.Stale(now),
+		LastSeenSec: int64(now.Sub(info.UpdatedAt).Seconds()),
+	}
+}
+
+// List 列出所有 worker，失联的 worker 在心跳过期前仍会列出并标记 stale
+func (s *WorkerService) List() ([]WorkerStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), workerQueryTimeout)
+	defer cancel()
+
+	kv, err := queue.WorkerBucket(ctx, s.js)
+	if err != nil {
+		return nil, err
+	}
+	workers, err := queue.ListWorkers(ctx, kv)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	statuses := make([]WorkerStatus, 0, len(workers))
+	for _, w := range workers {
+		statuses = append(statuses, newWorkerStatus(w, now))
+	}
+	return statuses, nil
+}
+
+// Get 获取单个 worker，包括各槽位正在评测的提交
+func (s *WorkerService) Get(id string) (*WorkerStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), workerQueryTimeout)
+	defer cancel()
+
+	kv, err := queue.WorkerBucket(ctx, s.js)
+	if err != nil {
+		return nil, err
+	}
+	info, err := queue.GetWorker(ctx, kv, id)
+	if err != nil {
+		return nil, err
+	}
+	status := newWorkerStatus(*info, time.Now())
+	return &status, nil
+}
+
+// QueueDepth 各队列类别各通道的积压，来自评测任务 stream 上各 consumer 的状态
+func (s *WorkerService) QueueDepth() ([]queue.LaneDepth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), workerQueryTimeout)
+	defer cancel()
+	return queue.LaneDepths(ctx, s.js)
+}
+
+// Remove 删除失联 worker 的心跳记录，在线的 worker 下次心跳会重新出现
+func (s *WorkerService) Remove(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), workerQueryTimeout)
+	defer cancel()
+
+	kv, err := queue.WorkerBucket(ctx, s.js)
+	if err != nil {
+		return err
+	}
+	return queue.RemoveWorker(ctx, kv, id)
+}
@@ -0,0 +1,3 @@
+-- 题目比对方式：exact / token / token_icase / float
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS checker VARCHAR(20) DEFAULT 'token';
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS checker_epsilon DOUBLE PRECISION DEFAULT 0;
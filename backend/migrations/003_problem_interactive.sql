@@ -0,0 +1,4 @@
+-- 交互题：选手程序与交互器通过管道通信
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS is_interactive BOOLEAN DEFAULT FALSE;
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS interactor_lang VARCHAR(30);
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS interactor_code TEXT;
@@ -0,0 +1,28 @@
+-- 评测结果区分生命周期状态（PENDING/COMPILING/RUNNING/DONE）与最终结论 verdict
+
+-- 旧的系统错误和死信记录结论为 SE
+UPDATE submissions SET judge_result = jsonb_set(judge_result, '{verdict}', '"SE"')
+WHERE judge_result->>'status' IN ('SYSTEM_ERROR', 'DLQ') AND judge_result->>'verdict' IS NULL;
+
+-- 旧的 FINISHED 记录按测试点结果补全结论，优先级与评测机 finalVerdict 一致
+UPDATE submissions SET judge_result = jsonb_set(judge_result, '{verdict}', to_jsonb(COALESCE((
+    SELECT v FROM (
+        SELECT CASE
+            WHEN c->>'status' = 'PC' THEN 'PARTIAL'
+            WHEN c->>'status' IN ('SE', 'CE', 'TLE', 'MLE', 'OLE', 'RE', 'WA', 'PE') THEN c->>'status'
+            ELSE 'SE'
+        END AS v
+        FROM jsonb_array_elements(CASE WHEN jsonb_typeof(judge_result->'cases') = 'array'
+                                       THEN judge_result->'cases' ELSE '[]'::jsonb END) AS c
+        WHERE COALESCE(c->>'status', '') NOT IN ('AC', 'SKIPPED')
+    ) failed
+    ORDER BY array_position(ARRAY['SE', 'CE', 'TLE', 'MLE', 'OLE', 'RE', 'WA', 'PE', 'PARTIAL'], v)
+    LIMIT 1
+), 'AC')))
+WHERE judge_result->>'status' = 'FINISHED' AND judge_result->>'verdict' IS NULL;
+
+UPDATE submissions SET judge_result = jsonb_set(judge_result, '{status}', '"DONE"')
+WHERE judge_result->>'status' IN ('FINISHED', 'SYSTEM_ERROR', 'DLQ');
+
+CREATE INDEX IF NOT EXISTS idx_submissions_judge_status ON submissions ((judge_result->>'status'));
+CREATE INDEX IF NOT EXISTS idx_submissions_judge_verdict ON submissions ((judge_result->>'verdict'));
@@ -0,0 +1,2 @@
+-- 测试点执行策略：acm 遇错即停，ioi 全部运行；为空时跟随比赛赛制
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS judge_mode VARCHAR(10);
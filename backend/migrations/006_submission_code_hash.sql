@@ -0,0 +1,3 @@
+-- 回填代码哈希，重测历史提交时也能命中编译产物缓存
+UPDATE submissions SET code_hash = encode(sha256(convert_to(code, 'UTF8')), 'hex')
+WHERE code_hash IS NULL OR code_hash = '';
@@ -0,0 +1,3 @@
+-- 样例评测：只运行题面样例，不计入统计、罚时和默认的提交列表
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS is_sample BOOLEAN DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS idx_submissions_is_sample ON submissions (is_sample);
@@ -0,0 +1,33 @@
+-- hack：参赛者构造输入挑战他人已通过的提交，需要题目提供校验器和标程
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS validator_lang VARCHAR(30);
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS validator_code TEXT;
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS std_lang VARCHAR(30);
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS std_code TEXT;
+ALTER TABLE contests ADD COLUMN IF NOT EXISTS allow_hack BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS hacks (
+    id BIGSERIAL PRIMARY KEY,
+    contest_id BIGINT NOT NULL,
+    problem_id BIGINT NOT NULL,
+    hacker_id BIGINT NOT NULL,
+    target_submit_id VARCHAR(36) NOT NULL,
+    target_user_id BIGINT NOT NULL,
+    input TEXT,
+    answer TEXT,
+    status VARCHAR(20) DEFAULT 'PENDING',
+    verdict VARCHAR(20),
+    target_verdict VARCHAR(20),
+    message VARCHAR(500),
+    points INT DEFAULT 0,
+    added_to_tests BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_hacks_contest_id ON hacks (contest_id);
+CREATE INDEX IF NOT EXISTS idx_hacks_problem_id ON hacks (problem_id);
+CREATE INDEX IF NOT EXISTS idx_hacks_hacker_id ON hacks (hacker_id);
+CREATE INDEX IF NOT EXISTS idx_hacks_target_submit_id ON hacks (target_submit_id);
+CREATE INDEX IF NOT EXISTS idx_hacks_target_user_id ON hacks (target_user_id);
+CREATE INDEX IF NOT EXISTS idx_hacks_status ON hacks (status);
+CREATE INDEX IF NOT EXISTS idx_hacks_deleted_at ON hacks (deleted_at);
@@ -0,0 +1,2 @@
+-- 测试数据上传后由校验器逐个检查输入文件，结果对应 test_data_hash；存在不合法输入时题目不能公开
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS test_data_check TEXT;
@@ -0,0 +1,4 @@
+-- 由生成器和标程生产测试数据：生成器列表、生成脚本和最近一次生成的结果
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS generators TEXT;
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS gen_script TEXT;
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS test_data_gen TEXT;
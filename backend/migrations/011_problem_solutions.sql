@@ -0,0 +1,29 @@
+-- 参考解及其预期结论；invoke 在完整测试集上运行全部参考解，给出结论矩阵和建议的时间限制
+CREATE TABLE IF NOT EXISTS problem_solutions (
+    id BIGSERIAL PRIMARY KEY,
+    problem_id BIGINT NOT NULL,
+    name VARCHAR(100),
+    lang VARCHAR(30),
+    code TEXT,
+    expected TEXT,
+    created_by BIGINT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_problem_solutions_problem_id ON problem_solutions (problem_id);
+CREATE INDEX IF NOT EXISTS idx_problem_solutions_deleted_at ON problem_solutions (deleted_at);
+
+CREATE TABLE IF NOT EXISTS problem_invocations (
+    id BIGSERIAL PRIMARY KEY,
+    problem_id BIGINT NOT NULL,
+    test_data_hash VARCHAR(64),
+    status VARCHAR(20) DEFAULT 'PENDING',
+    result JSONB,
+    mismatches INT DEFAULT 0,
+    suggested_time_limit INT DEFAULT 0,
+    created_by BIGINT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_problem_invocations_problem_id ON problem_invocations (problem_id);
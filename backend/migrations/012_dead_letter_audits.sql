@@ -0,0 +1,12 @@
+-- 死信队列的重放/删除/清空记录
+CREATE TABLE IF NOT EXISTS dead_letter_audits (
+    id BIGSERIAL PRIMARY KEY,
+    admin_id BIGINT,
+    action VARCHAR(20),
+    seq BIGINT DEFAULT 0,
+    submit_id VARCHAR(36),
+    detail VARCHAR(500),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_dead_letter_audits_admin_id ON dead_letter_audits (admin_id);
+CREATE INDEX IF NOT EXISTS idx_dead_letter_audits_submit_id ON dead_letter_audits (submit_id);
@@ -0,0 +1,2 @@
+-- 评测失败记录（基础设施错误重试时追加），进入死信队列时随任务一并保存
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS judge_errors TEXT;
@@ -0,0 +1,4 @@
+-- 语言的评测队列类别和所需能力，替代代码中写死的 heavy/light 划分
+ALTER TABLE languages ADD COLUMN IF NOT EXISTS queue_class VARCHAR(20) DEFAULT 'light';
+ALTER TABLE languages ADD COLUMN IF NOT EXISTS capabilities TEXT;
+UPDATE languages SET queue_class = 'heavy' WHERE slug IN ('cpp17', 'c11', 'java17');
@@ -0,0 +1,4 @@
+-- 评测任务最近一次发布的 subject 和入队时间，用于计算排队位置和预计开始时间
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS queue_subject VARCHAR(100);
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS queued_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_submissions_queue_subject ON submissions (queue_subject);
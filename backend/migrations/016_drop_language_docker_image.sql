@@ -0,0 +1,2 @@
+-- 评测改为 worker 内的 namespace/cgroup 沙箱，直接使用 worker 镜像中的工具链，语言不再对应 docker 镜像
+ALTER TABLE languages DROP COLUMN IF EXISTS docker_image;
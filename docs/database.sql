@@ -67,9 +67,6 @@ CREATE TABLE languages (
     run_cmd         TEXT NOT NULL,                  -- ["./main"]
     run_timeout     INTEGER DEFAULT 2,               -- 秒 (基础超时)
 
-    -- Docker 配置
-    docker_image    VARCHAR(200) NOT NULL,          -- oj-runner-cpp17
-
     -- 资源因子 (Java/Python 需放大)
     time_factor     DECIMAL(3,2) DEFAULT 1.0,        -- 运行时 × time_factor
     memory_factor   DECIMAL(3,2) DEFAULT 1.0,        -- 内存 MB × memory_factor
@@ -156,7 +156,6 @@ export interface Language {
   name: string
   slug: string
   source_filename: string
-  docker_image: string
   enabled: boolean
 }
 
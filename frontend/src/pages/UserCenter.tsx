@@ -22,7 +22,11 @@ export default function UserCenter() {
   }
 
   const getStatusColor = (status: string) => {
-    const colors: any = { PENDING: 'orange', RUNNING: 'blue', FINISHED: 'green', CE: 'red', WA: 'red' }
+    const colors: any = {
+      PENDING: 'orange', COMPILING: 'blue', RUNNING: 'blue',
+      AC: 'green', PARTIAL: 'gold', WA: 'red', PE: 'red', CE: 'red',
+      TLE: 'purple', MLE: 'purple', OLE: 'purple', RE: 'magenta', SE: 'default',
+    }
     return colors[status] || 'default'
   }
 
@@ -49,7 +53,9 @@ export default function UserCenter() {
               <Space>
                 <Tag>#{item.submit_id?.slice(0, 8)}</Tag>
                 <Tag>题目 {item.problem_id}</Tag>
-                <Tag color={getStatusColor(item.judge_result?.status)}>{item.judge_result?.status || 'PENDING'}</Tag>
+                <Tag color={getStatusColor(item.judge_result?.verdict || item.judge_result?.status)}>
+                  {item.judge_result?.verdict || item.judge_result?.status || 'PENDING'}
+                </Tag>
                 <span>{item.judge_result?.score || 0} 分</span>
               </Space>
             </List.Item>